@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sqlparser/pkg/diff"
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+)
+
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	keys := fs.String("key", "", "Key columns for tables without a primary key, as col1+col2, or per table as table=col1+col2,...")
+	includeTables := fs.String("tables", "", "Comma-separated tables to compare: names, globs or /regexps/ (default: all)")
+	excludeTables := fs.String("exclude-tables", "", "Comma-separated tables to leave out")
+	format := fs.String("format", "jsonl", "Output format (jsonl, sql)")
+	output := fs.String("output", "", "Output file (default: stdout)")
+	workers := fs.Int("workers", getWorkerCount(), "Number of worker threads")
+	logs := addLogFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sqlparser diff [-key=...] [-tables=...] [-format=jsonl|sql] <old.sql> <new.sql>\n")
+		fmt.Fprintf(os.Stderr, "Matches the rows of two dumps by primary key and prints those that were inserted, updated or deleted.\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	logs.setup()
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts := diff.Options{Workers: *workers}
+	var err error
+	if opts.Keys, err = parseKeys(*keys); err != nil {
+		fatal("invalid -key", "error", err)
+	}
+	filter, err := parser.ParseTableFilter(*includeTables, *excludeTables)
+	if err != nil {
+		fatal("invalid table selection", "error", err)
+	}
+	if !filter.IsEmpty() {
+		opts.Tables = func(db, name string) bool { return filter.Match(parser.TableInfo{Name: name, Database: db}) }
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fatal("error creating output file", "error", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	w := bufio.NewWriter(out)
+	var emit func(diff.Change) error
+	switch *format {
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		emit = func(c diff.Change) error { return enc.Encode(c) }
+	case "sql":
+		emit = func(c diff.Change) error { return writeChangeSQL(w, c) }
+	default:
+		fatal("invalid -format", "format", *format)
+	}
+
+	summaries, err := diff.Compare(context.Background(), fs.Arg(0), fs.Arg(1), opts, emit)
+	if err != nil {
+		fatal("error comparing dumps", "error", err)
+	}
+	if err := w.Flush(); err != nil {
+		fatal("error writing output", "error", err)
+	}
+	for _, s := range summaries {
+		if s.Skipped {
+			continue
+		}
+		slog.Info("diff summary", "table", s.Table, "inserted", s.Inserted, "updated", s.Updated,
+			"deleted", s.Deleted, "unchanged", s.Unchanged)
+	}
+}
+
+// parseKeys parses the -key flag: entries are either table=col1+col2, or
+// col1+col2 for every table without an entry.
+func parseKeys(s string) (map[string][]string, error) {
+	keys := make(map[string][]string)
+	for _, entry := range splitList(s) {
+		table, columns, found := strings.Cut(entry, "=")
+		if !found {
+			table, columns = "", entry
+		}
+		var key []string
+		for _, col := range strings.Split(columns, "+") {
+			if col = strings.TrimSpace(col); col == "" {
+				return nil, fmt.Errorf("empty column in %q", entry)
+			}
+			key = append(key, col)
+		}
+		if _, ok := keys[table]; ok {
+			return nil, fmt.Errorf("more than one key for table %q", table)
+		}
+		keys[table] = key
+	}
+	return keys, nil
+}
+
+// writeChangeSQL writes a change as the statement that applies it to the old
+// dump's data.
+func writeChangeSQL(w io.Writer, c diff.Change) error {
+	var err error
+	switch c.Op {
+	case diff.OpInsert:
+		columns := sortedColumns(c.Schema, c.After)
+		names := make([]string, len(columns))
+		values := make([]string, len(columns))
+		for i, col := range columns {
+			names[i] = quoteIdentifier(col)
+			values[i] = sqlLiteral(c.Schema, col, c.After[col])
+		}
+		_, err = fmt.Fprintf(w, "INSERT INTO %s (%s) VALUES (%s);\n",
+			quoteIdentifier(c.Table), strings.Join(names, ", "), strings.Join(values, ", "))
+	case diff.OpUpdate:
+		set := make([]string, 0, len(c.Changed))
+		for _, col := range c.Changed {
+			if value, ok := c.After[col]; ok {
+				set = append(set, quoteIdentifier(col)+" = "+sqlLiteral(c.Schema, col, value))
+			}
+		}
+		if len(set) == 0 {
+			// Only columns that the new dump no longer has changed
+			return nil
+		}
+		_, err = fmt.Fprintf(w, "UPDATE %s SET %s WHERE %s;\n",
+			quoteIdentifier(c.Table), strings.Join(set, ", "), whereKey(c))
+	case diff.OpDelete:
+		_, err = fmt.Fprintf(w, "DELETE FROM %s WHERE %s;\n", quoteIdentifier(c.Table), whereKey(c))
+	}
+	return err
+}
+
+func whereKey(c diff.Change) string {
+	columns := sortedColumns(c.Schema, c.Key)
+	conds := make([]string, len(columns))
+	for i, col := range columns {
+		if c.Key[col] == nil {
+			conds[i] = quoteIdentifier(col) + " IS NULL"
+		} else {
+			conds[i] = quoteIdentifier(col) + " = " + sqlLiteral(c.Schema, col, c.Key[col])
+		}
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// sortedColumns returns the columns of data in the order of schema, followed
+// by those that schema doesn't have in name order.
+func sortedColumns(schema *models.Schema, data map[string]interface{}) []string {
+	var columns []string
+	if schema != nil {
+		for _, col := range schema.Columns {
+			if _, ok := data[col.Name]; ok {
+				columns = append(columns, col.Name)
+			}
+		}
+	}
+	var rest []string
+	for col := range data {
+		if schema == nil || schema.Column(col) == nil {
+			rest = append(rest, col)
+		}
+	}
+	sort.Strings(rest)
+	return append(columns, rest...)
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// sqlEscaper escapes a string for a MySQL string literal, as mysqldump does.
+var sqlEscaper = strings.NewReplacer(`\`, `\\`, "'", `\'`, "\x00", `\0`, "\n", `\n`, "\r", `\r`, "\x1a", `\Z`)
+
+// sqlLiteral formats a value for a MySQL statement. Values of numeric columns
+// are written as numbers, everything else as escaped strings.
+func sqlLiteral(schema *models.Schema, column string, value interface{}) string {
+	if value == nil {
+		return "NULL"
+	}
+	s := fmt.Sprint(value)
+	if schema != nil {
+		if col := schema.Column(column); col != nil && isNumericType(col.Type) {
+			if _, err := strconv.ParseFloat(s, 64); err == nil {
+				return s
+			}
+		}
+	}
+	return "'" + sqlEscaper.Replace(s) + "'"
+}
+
+func isNumericType(t string) bool {
+	t = strings.ToLower(t)
+	for _, prefix := range []string{"int", "tinyint", "smallint", "mediumint", "bigint", "decimal", "numeric", "float", "double", "real"} {
+		if strings.HasPrefix(t, prefix) {
+			return true
+		}
+	}
+	return false
+}
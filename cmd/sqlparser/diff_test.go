@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sqlparser/pkg/diff"
+	"sqlparser/pkg/models"
+)
+
+const usersTable = "CREATE TABLE `users` (\n" +
+	"  `id` int(11) NOT NULL,\n" +
+	"  `name` varchar(50) DEFAULT NULL,\n" +
+	"  `score` decimal(5,2) DEFAULT NULL,\n" +
+	"  PRIMARY KEY (`id`)\n" +
+	");\n"
+
+const membersTable = "CREATE TABLE `members` (\n" +
+	"  `org` varchar(10) NOT NULL,\n" +
+	"  `user` int(11) NOT NULL,\n" +
+	"  `role` varchar(10) DEFAULT NULL,\n" +
+	"  PRIMARY KEY (`org`,`user`)\n" +
+	");\n"
+
+const logTable = "CREATE TABLE `log` (\n" +
+	"  `code` varchar(10) NOT NULL,\n" +
+	"  `message` varchar(50) DEFAULT NULL\n" +
+	");\n"
+
+func TestDiffSQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		keys     string
+		want     string
+		summary  string // of every table, as table:inserted/updated/deleted/unchanged
+	}{
+		{
+			name: "rows matched by primary key",
+			old: usersTable +
+				"INSERT INTO `users` VALUES (1,'ann',1.50),(2,'bob',2.00),(3,'cy',NULL);\n",
+			new: usersTable +
+				"INSERT INTO `users` VALUES (3,'cy',NULL),(1,'ann',1.75),(4,'dee',0.00);\n",
+			want: "UPDATE `users` SET `score` = 1.75 WHERE `id` = 1;\n" +
+				"INSERT INTO `users` (`id`, `name`, `score`) VALUES (4, 'dee', 0.00);\n" +
+				"DELETE FROM `users` WHERE `id` = 2;\n",
+			summary: "users:1/1/1/1",
+		},
+		{
+			name: "composite primary key",
+			old: membersTable +
+				"INSERT INTO `members` VALUES ('a',1,'owner'),('a',2,'dev'),('b',1,'dev');\n",
+			new: membersTable +
+				"INSERT INTO `members` VALUES ('a',1,'owner'),('b',1,'owner'),('b',2,'dev');\n",
+			want: "UPDATE `members` SET `role` = 'owner' WHERE `org` = 'b' AND `user` = 1;\n" +
+				"INSERT INTO `members` (`org`, `user`, `role`) VALUES ('b', 2, 'dev');\n" +
+				"DELETE FROM `members` WHERE `org` = 'a' AND `user` = 2;\n",
+			summary: "members:1/1/1/1",
+		},
+		{
+			name:    "table without primary key is skipped",
+			old:     logTable + "INSERT INTO `log` VALUES ('a','x');\n",
+			new:     logTable + "INSERT INTO `log` VALUES ('a','y');\n",
+			summary: "log:skipped",
+		},
+		{
+			name: "key given with -key",
+			old:  logTable + "INSERT INTO `log` VALUES ('a','x'),('b','y');\n",
+			new:  logTable + "INSERT INTO `log` VALUES ('a','z');\n",
+			keys: "log=code",
+			want: "UPDATE `log` SET `message` = 'z' WHERE `code` = 'a';\n" +
+				"DELETE FROM `log` WHERE `code` = 'b';\n",
+			summary: "log:0/1/1/0",
+		},
+		{
+			name:    "key with null values",
+			old:     logTable + "INSERT INTO `log` VALUES ('a',NULL),('b','y');\n",
+			new:     logTable + "INSERT INTO `log` VALUES ('b','y');\n",
+			keys:    "message",
+			want:    "DELETE FROM `log` WHERE `message` IS NULL;\n",
+			summary: "log:0/0/1/1",
+		},
+		{
+			name: "primary key before the default key",
+			old: usersTable + "INSERT INTO `users` VALUES (1,'ann',NULL);\n" +
+				logTable + "INSERT INTO `log` VALUES ('a','x');\n",
+			new: usersTable + "INSERT INTO `users` VALUES (1,'bob',NULL);\n" +
+				logTable + "INSERT INTO `log` VALUES ('a','y');\n",
+			keys: "code",
+			want: "UPDATE `users` SET `name` = 'bob' WHERE `id` = 1;\n" +
+				"UPDATE `log` SET `message` = 'y' WHERE `code` = 'a';\n",
+			summary: "users:0/1/0/0 log:0/1/0/0",
+		},
+		{
+			name: "table without the default key column is skipped",
+			old: logTable + "INSERT INTO `log` VALUES ('a','x');\n" +
+				usersTable + "INSERT INTO `users` VALUES (1,'ann',NULL);\n",
+			new: logTable + "INSERT INTO `log` VALUES ('a','y');\n" +
+				usersTable + "INSERT INTO `users` VALUES (1,'ann',NULL),(2,'bob',NULL);\n",
+			keys:    "score",
+			want:    "INSERT INTO `users` (`id`, `name`, `score`) VALUES (2, 'bob', NULL);\n",
+			summary: "log:skipped users:1/0/0/1",
+		},
+		{
+			name: "escapes are decoded and written again",
+			old: usersTable +
+				"INSERT INTO `users` VALUES (1,'it\\'s',NULL),(2,'a\\\\b',NULL);\n",
+			new: usersTable +
+				"INSERT INTO `users` VALUES (1,'it\\'s\\nnot',NULL),(2,'a\\\\b',NULL),(3,'O\\'Brien',NULL);\n",
+			want: "UPDATE `users` SET `name` = 'it\\'s\\nnot' WHERE `id` = 1;\n" +
+				"INSERT INTO `users` (`id`, `name`, `score`) VALUES (3, 'O\\'Brien', NULL);\n",
+			summary: "users:1/1/0/1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			oldFile, newFile := filepath.Join(dir, "old.sql"), filepath.Join(dir, "new.sql")
+			if err := os.WriteFile(oldFile, []byte(tt.old), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(newFile, []byte(tt.new), 0644); err != nil {
+				t.Fatal(err)
+			}
+			keys, err := parseKeys(tt.keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			summaries, err := diff.Compare(context.Background(), oldFile, newFile, diff.Options{Keys: keys, Workers: 1},
+				func(c diff.Change) error { return writeChangeSQL(&out, c) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), tt.want)
+			}
+			var got []string
+			for _, s := range summaries {
+				if s.Skipped {
+					got = append(got, s.Table+":skipped")
+				} else {
+					got = append(got, fmt.Sprintf("%s:%d/%d/%d/%d", s.Table, s.Inserted, s.Updated, s.Deleted, s.Unchanged))
+				}
+			}
+			if summary := strings.Join(got, " "); summary != tt.summary {
+				t.Errorf("summary = %s, want %s", summary, tt.summary)
+			}
+		})
+	}
+}
+
+func TestSQLLiteral(t *testing.T) {
+	schema := &models.Schema{Columns: []models.Column{
+		{Name: "n", Type: "int(11)"},
+		{Name: "d", Type: "DECIMAL(10,2)"},
+		{Name: "s", Type: "varchar(10)"},
+	}}
+	tests := []struct {
+		column string
+		value  interface{}
+		want   string
+	}{
+		{"n", nil, "NULL"},
+		{"n", "42", "42"},
+		{"d", "-1.50", "-1.50"},
+		// Not a number after all, so quoted
+		{"n", "0x1F", "'0x1F'"},
+		{"s", "42", "'42'"},
+		// Values are decoded, so they are escaped again
+		{"s", "it's", `'it\'s'`},
+		{"s", "a\\b\n", `'a\\b\n'`},
+		{"s", "\x00\x1a\r", `'\0\Z\r'`},
+		{"missing", "1", "'1'"},
+	}
+	for _, tt := range tests {
+		if got := sqlLiteral(schema, tt.column, tt.value); got != tt.want {
+			t.Errorf("sqlLiteral(%s, %q) = %s, want %s", tt.column, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		flag string
+		want map[string][]string
+		err  bool
+	}{
+		{flag: "", want: map[string][]string{}},
+		{flag: "id", want: map[string][]string{"": {"id"}}},
+		{flag: "a=x+y, b=z", want: map[string][]string{"a": {"x", "y"}, "b": {"z"}}},
+		{flag: "uid,log=code", want: map[string][]string{"": {"uid"}, "log": {"code"}}},
+		{flag: "a=x+", err: true},
+		{flag: "a=x,a=y", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseKeys(tt.flag)
+		if (err != nil) != tt.err {
+			t.Errorf("parseKeys(%q): error %v", tt.flag, err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseKeys(%q) = %v, want %v", tt.flag, got, tt.want)
+		}
+	}
+}
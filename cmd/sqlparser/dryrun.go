@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+	"sqlparser/pkg/progress"
+	"sqlparser/pkg/writer"
+)
+
+// dryRunSampleRows is how many rows of each table -dry-run parses and formats
+// to base its estimates on.
+const dryRunSampleRows = 100000
+
+// errSampleDone stops sampling a table once enough rows were read.
+var errSampleDone = errors.New("sample complete")
+
+// estimate is the projected result of exporting a table, scaled up from a
+// sample of its rows.
+type estimate struct {
+	table       string
+	inputBytes  int64
+	sampleRows  int
+	complete    bool // the sample covered the whole table, so the numbers are exact
+	rows        int64
+	outputBytes int64
+	duration    time.Duration
+}
+
+// dryRun estimates the rows, output size and time of exporting tables in
+// format, and prints the estimates instead of exporting anything. With more
+// than one input, the estimates are headed by the file name.
+func dryRun(ctx context.Context, filename string, tables []*parser.TableInfo, format models.OutputFormat, o exportOptions, multiple bool) error {
+	estimates := make([]estimate, 0, len(tables))
+	for _, table := range tables {
+		est, err := sampleTable(ctx, filename, table, format, o)
+		if err != nil {
+			return fmt.Errorf("error sampling table %s: %v", table.Name, err)
+		}
+		estimates = append(estimates, est)
+	}
+	if multiple {
+		fmt.Printf("%s:\n", filename)
+	}
+	return printEstimates(os.Stdout, estimates, format, o.workers)
+}
+
+// sampleTable parses and formats up to dryRunSampleRows rows of table,
+// discarding the output, and scales the measurements up to the size of the
+// table.
+func sampleTable(ctx context.Context, filename string, table *parser.TableInfo, format models.OutputFormat, o exportOptions) (estimate, error) {
+	est := estimate{table: table.Name, inputBytes: table.Bytes}
+
+	input, err := parser.OpenInputAt(filename, table.StartOffset)
+	if err != nil {
+		return est, err
+	}
+	defer input.Close()
+
+	counter := &countingWriter{}
+	w, err := writer.CreateWriter(format, counter)
+	if err != nil {
+		return est, err
+	}
+	if o.transform != nil {
+		w = o.transform(w)
+	}
+
+	// Nothing is quarantined, as no files are written
+	policy := o.policy
+	if policy == parser.ErrorPolicyQuarantine {
+		policy = parser.ErrorPolicySkip
+	}
+	cfg := parser.Config{
+		File:           filename,
+		StartLine:      table.StartLine,
+		StartOffset:    table.StartOffset,
+		StartStatement: table.StartStatement,
+		StartDatabase:  table.StartDatabase,
+		EndLine:        table.LineTo,
+		Workers:        o.workers,
+		Tables:         func(db, name string) bool { return db == table.Database && name == table.Name },
+		Errors:         parser.NewErrorHandler(policy, nil),
+	}
+	rows := 0
+	start := time.Now()
+	stats, err := parser.Stream(ctx, input, cfg, parser.HandlerFuncs{
+		OnSchema: func(schema *models.Schema) error {
+			if sw, ok := w.(writer.SchemaWriter); ok {
+				return sw.WriteSchema(schema)
+			}
+			return nil
+		},
+		OnTableStart: w.WriteTableStart,
+		OnRows: func(_ string, batch []models.Row) error {
+			if err := w.WriteRows(batch); err != nil {
+				return err
+			}
+			rows += len(batch)
+			if rows >= dryRunSampleRows {
+				return errSampleDone
+			}
+			return nil
+		},
+		OnTableEnd: func(string) error { return w.WriteTableEnd() },
+	})
+	est.complete = err == nil
+	if err == errSampleDone {
+		err = w.WriteTableEnd()
+	}
+	if err != nil {
+		return est, err
+	}
+	if err := w.Close(); err != nil {
+		return est, err
+	}
+	elapsed := time.Since(start)
+
+	est.sampleRows = stats.Rows
+	est.rows, est.outputBytes, est.duration = int64(stats.Rows), counter.n, elapsed
+	if !est.complete && stats.Bytes > 0 {
+		scale := float64(table.Bytes) / float64(stats.Bytes)
+		est.rows = int64(float64(stats.Rows) * scale)
+		est.outputBytes = int64(float64(counter.n) * scale)
+		est.duration = time.Duration(float64(elapsed) * scale)
+	}
+	return est, nil
+}
+
+func printEstimates(out io.Writer, estimates []estimate, format models.OutputFormat, workers int) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "TABLE\tINPUT\tROWS\tOUTPUT\tTIME\tSAMPLED\n")
+	var total estimate
+	total.complete = true
+	for _, est := range estimates {
+		approx := "~"
+		sampled := fmt.Sprintf("%d rows", est.sampleRows)
+		if est.complete {
+			approx = ""
+			sampled += " (all)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s%d\t%s%s\t%s%s\t%s\n", est.table, progress.FormatBytes(est.inputBytes),
+			approx, est.rows, approx, progress.FormatBytes(est.outputBytes), approx, formatDuration(est.duration), sampled)
+
+		total.inputBytes += est.inputBytes
+		total.rows += est.rows
+		total.outputBytes += est.outputBytes
+		total.duration += est.duration
+		total.complete = total.complete && est.complete
+	}
+	approx := "~"
+	if total.complete {
+		approx = ""
+	}
+	fmt.Fprintf(w, "TOTAL\t%s\t%s%d\t%s%s\t%s%s\t\n", progress.FormatBytes(total.inputBytes),
+		approx, total.rows, approx, progress.FormatBytes(total.outputBytes), approx, formatDuration(total.duration))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "\nEstimated from the first %d rows of each table for %s output with -workers=%d. Nothing was written.\n",
+		dryRunSampleRows, format, workers)
+	return err
+}
+
+// formatDuration rounds d to a precision that suits its length.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
+// countingWriter discards what is written to it, counting the bytes.
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
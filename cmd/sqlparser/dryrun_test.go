@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+	"sqlparser/pkg/writer"
+)
+
+// writeSampleDump writes a dump with a small table a, followed by a table big
+// with statements of 1000 rows each, and returns its tables.
+func writeSampleDump(t *testing.T, bigStatements int) (string, []parser.TableInfo) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	var b strings.Builder
+	b.WriteString("CREATE TABLE `a` (\n  `id` int,\n  `s` varchar(10)\n);\n")
+	b.WriteString("INSERT INTO `a` VALUES (1,'x'),(2,'y'),(3,NULL);\n")
+	b.WriteString("INSERT INTO `a` VALUES (4;\n")
+	b.WriteString("CREATE TABLE `big` (\n  `id` int\n);\n")
+	for i := 0; i < bigStatements; i++ {
+		b.WriteString("INSERT INTO `big` VALUES ")
+		for j := 0; j < 1000; j++ {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "(%d)", 1000*i+j)
+		}
+		b.WriteString(";\n")
+	}
+	filename := filepath.Join(t.TempDir(), "dump.sql")
+	if err := os.WriteFile(filename, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := parser.ScanTables(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filename, tables
+}
+
+func TestSampleTable(t *testing.T) {
+	filename, tables := writeSampleDump(t, 250)
+	o := exportOptions{workers: 2, policy: parser.ErrorPolicyQuarantine}
+
+	// a is sampled in full, so the numbers are exact: the CSV output of its
+	// rows, without the broken statement
+	a, err := sampleTable(context.Background(), filename, &tables[0], models.FormatCSV, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var csv bytes.Buffer
+	rows := []models.Row{
+		{TableName: "a", Data: map[string]interface{}{"id": "1", "s": "x"}},
+		{TableName: "a", Data: map[string]interface{}{"id": "2", "s": "y"}},
+		{TableName: "a", Data: map[string]interface{}{"id": "3", "s": nil}},
+	}
+	schema := &models.Schema{TableName: "a", Columns: []models.Column{{Name: "id", Type: "int"}, {Name: "s", Type: "varchar(10)"}}}
+	if err := writeRows(&csv, models.FormatCSV, schema, rows); err != nil {
+		t.Fatal(err)
+	}
+	if !a.complete || a.rows != 3 || a.sampleRows != 3 || a.outputBytes != int64(csv.Len()) {
+		t.Errorf("got %+v, want 3 rows and %d bytes sampled in full", a, csv.Len())
+	}
+
+	// big is scaled up from the first rows
+	big, err := sampleTable(context.Background(), filename, &tables[1], models.FormatCSV, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if big.complete || big.sampleRows < dryRunSampleRows || big.sampleRows >= 250000 {
+		t.Errorf("sampled %d rows, complete = %v", big.sampleRows, big.complete)
+	}
+	// Ids grow longer further into the table, so the first rows are
+	// shorter than the average
+	if big.rows < 250000 || big.rows > 275000 {
+		t.Errorf("estimated %d rows, want a little over 250000", big.rows)
+	}
+	if size := bigCSVSize(250000); big.outputBytes < size*9/10 || big.outputBytes > size*11/10 {
+		t.Errorf("estimated %d bytes of output, want about %d", big.outputBytes, size)
+	}
+}
+
+// bigCSVSize returns the size of the CSV output of big with n rows, which
+// has the row number and the id on each line.
+func bigCSVSize(n int) int64 {
+	size := int64(len("Table:,big\nRow,id\n\n"))
+	for i := 0; i < n; i++ {
+		size += int64(len(fmt.Sprint(i+1)) + len(fmt.Sprint(i)) + 2)
+	}
+	return size
+}
+
+func TestSampleTableTransform(t *testing.T) {
+	filename, tables := writeSampleDump(t, 0)
+	o := exportOptions{workers: 1, policy: parser.ErrorPolicySkip, transform: func(w writer.Writer) writer.Writer {
+		return dropRows{w}
+	}}
+	est, err := sampleTable(context.Background(), filename, &tables[0], models.FormatJSONL, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est.outputBytes != 0 {
+		t.Errorf("got %d bytes of output for no rows", est.outputBytes)
+	}
+}
+
+// dropRows is a transformation that leaves out every row.
+type dropRows struct {
+	writer.Writer
+}
+
+func (dropRows) WriteRows([]models.Row) error { return nil }
+
+func TestPrintEstimates(t *testing.T) {
+	estimates := []estimate{
+		{table: "a", inputBytes: 100, sampleRows: 3, complete: true, rows: 3, outputBytes: 40, duration: 1500 * time.Microsecond},
+		{table: "big", inputBytes: 3 << 20, sampleRows: 100000, rows: 250000, outputBytes: 2 << 20, duration: 90 * time.Second},
+	}
+	var out bytes.Buffer
+	if err := printEstimates(&out, estimates, models.FormatCSV, 4); err != nil {
+		t.Fatal(err)
+	}
+	want := "TABLE  INPUT   ROWS     OUTPUT   TIME    SAMPLED\n" +
+		"a      100 B   3        40 B     2ms     3 rows (all)\n" +
+		"big    3.0 MB  ~250000  ~2.0 MB  ~1m30s  100000 rows\n" +
+		"TOTAL  3.0 MB  ~250003  ~2.0 MB  ~1m30s  \n" +
+		"\nEstimated from the first 100000 rows of each table for csv output with -workers=4. Nothing was written.\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+	"sqlparser/pkg/writer"
+)
+
+// matchedColumnsKey is the field that holds the names of the matching columns
+// in rows written by grep in one of the export formats with -matched-columns.
+const matchedColumnsKey = "_matched_columns"
+
+// errEnoughMatches stops grep once -max matches were found.
+var errEnoughMatches = errors.New("enough matches")
+
+func runGrep(args []string) {
+	fs := flag.NewFlagSet("grep", flag.ExitOnError)
+	useRegex := fs.Bool("regex", false, "Treat PATTERN as a regular expression instead of a literal string")
+	ignoreCase := fs.Bool("i", false, "Match case-insensitively")
+	exact := fs.Bool("exact", false, "Match whole values only")
+	includeTables := fs.String("tables", "", "Comma-separated tables to search, in the same syntax as for exports (default: all)")
+	excludeTables := fs.String("exclude-tables", "", "Comma-separated tables to leave out")
+	columns := fs.String("columns", "", "Comma-separated columns to search, as names or globs (default: all)")
+	format := fs.String("format", "", "Output format (txt, csv, json, jsonl); by default one line per matching row")
+	output := fs.String("output", "", "Output file (default: stdout)")
+	matchedColumns := fs.Bool("matched-columns", false, "With -format, add the names of the matching columns to each row as a "+matchedColumnsKey+" field")
+	maxMatches := fs.Int("max", 0, "Stop after this many matching rows (0: no limit)")
+	workers := fs.Int("workers", getWorkerCount(), "Number of worker threads")
+	logs := addLogFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sqlparser grep [-regex] [-i] [-exact] [-tables=...] [-columns=...] [-format=txt|csv|json|jsonl] PATTERN <sqlfile>\n")
+		fmt.Fprintf(os.Stderr, "Searches the decoded values of a dump and prints the matching rows. Exits with status 1 if nothing matched.\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	logs.setup()
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	pattern, filename := fs.Arg(0), fs.Arg(1)
+
+	match, err := newValueMatcher(pattern, *useRegex, *ignoreCase, *exact)
+	if err != nil {
+		fatal("invalid pattern", "error", err)
+	}
+	columnPatterns := splitList(*columns)
+	for _, p := range columnPatterns {
+		if _, err := path.Match(p, ""); err != nil {
+			fatal("invalid -columns pattern", "pattern", p, "error", err)
+		}
+	}
+
+	tables := func(string, string) bool { return true }
+	filter, err := parser.ParseTableFilter(*includeTables, *excludeTables)
+	if err != nil {
+		fatal("invalid table selection", "error", err)
+	}
+	if !filter.IsEmpty() {
+		// Patterns may name databases, which only a scan of the dump tells
+		all, err := parser.ScanTables(filename)
+		if err != nil {
+			fatal("error scanning tables", "error", err)
+		}
+		selected, err := filter.Select(all)
+		if err != nil {
+			fatal("error selecting tables", "error", err)
+		}
+		names := make(map[[2]string]bool)
+		for _, t := range selected {
+			names[[2]string{t.Database, t.Name}] = true
+		}
+		tables = func(db, name string) bool { return names[[2]string{db, name}] }
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fatal("error creating output file", "error", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	var printer matchPrinter
+	if *format == "" {
+		printer = newLinePrinter(out)
+	} else {
+		w, err := writer.CreateWriter(models.OutputFormat(*format), out)
+		if err != nil {
+			fatal("error creating writer", "error", err)
+		}
+		printer = &writerPrinter{writer: w, addMatched: *matchedColumns}
+	}
+
+	g := &grep{
+		match:   match,
+		columns: columnPatterns,
+		max:     *maxMatches,
+		printer: printer,
+		schemas: make(map[string]*models.Schema),
+	}
+	cfg := parser.Config{File: filename, Workers: *workers, Tables: tables}
+	input, err := parser.OpenInput(filename)
+	if err != nil {
+		fatal("error opening file", "error", err)
+	}
+	_, err = parser.Stream(context.Background(), input, cfg, g)
+	input.Close()
+	if err != nil && err != errEnoughMatches {
+		fatal("error searching file", "error", err)
+	}
+	if err := printer.Close(); err != nil {
+		fatal("error writing output", "error", err)
+	}
+	if g.matches == 0 {
+		os.Exit(1)
+	}
+}
+
+// newValueMatcher returns a function that reports whether a value matches
+// pattern.
+func newValueMatcher(pattern string, useRegex, ignoreCase, exact bool) (func(string) bool, error) {
+	if !useRegex {
+		if !ignoreCase && !exact {
+			return func(value string) bool { return strings.Contains(value, pattern) }, nil
+		}
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if exact {
+		pattern = `^(?:` + pattern + `)$`
+	}
+	if ignoreCase {
+		pattern = `(?i)` + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString, nil
+}
+
+// grep is the stream handler that searches the rows.
+type grep struct {
+	match   func(string) bool
+	columns []string // column globs; empty searches all columns
+	max     int
+	printer matchPrinter
+	schemas map[string]*models.Schema
+	matches int
+	current string // table whose rows are being printed
+}
+
+func (g *grep) Schema(schema *models.Schema) error {
+	g.schemas[schema.TableName] = schema
+	return nil
+}
+
+func (g *grep) TableStart(table string) error {
+	return nil
+}
+
+func (g *grep) Rows(table string, rows []models.Row) error {
+	for _, row := range rows {
+		matched := g.matchRow(row)
+		if len(matched) == 0 {
+			continue
+		}
+		if g.current != table {
+			if err := g.endTable(); err != nil {
+				return err
+			}
+			if err := g.printer.TableStart(table); err != nil {
+				return err
+			}
+			g.current = table
+		}
+		g.matches++
+		if err := g.printer.Match(table, row, rowColumns(g.schemas[table], row), matched); err != nil {
+			return err
+		}
+		if g.max > 0 && g.matches >= g.max {
+			if err := g.endTable(); err != nil {
+				return err
+			}
+			return errEnoughMatches
+		}
+	}
+	return nil
+}
+
+func (g *grep) TableEnd(table string) error {
+	return g.endTable()
+}
+
+func (g *grep) endTable() error {
+	if g.current == "" {
+		return nil
+	}
+	g.current = ""
+	return g.printer.TableEnd()
+}
+
+// matchRow returns the searched columns of row whose value matches, in name
+// order.
+func (g *grep) matchRow(row models.Row) []string {
+	var matched []string
+	for col, value := range row.Data {
+		s, ok := value.(string)
+		if !ok || !g.searched(col) || !g.match(s) {
+			continue
+		}
+		matched = append(matched, col)
+	}
+	sort.Strings(matched)
+	return matched
+}
+
+func (g *grep) searched(column string) bool {
+	if len(g.columns) == 0 {
+		return true
+	}
+	for _, p := range g.columns {
+		if ok, _ := path.Match(p, column); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// rowColumns returns the columns of row in the order of the schema, or in
+// name order for tables without one.
+func rowColumns(schema *models.Schema, row models.Row) []string {
+	if schema != nil && len(schema.Columns) == len(row.Data) {
+		return schema.ColumnNames()
+	}
+	columns := make([]string, 0, len(row.Data))
+	for col := range row.Data {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// matchPrinter writes the rows found by grep.
+type matchPrinter interface {
+	TableStart(table string) error
+	Match(table string, row models.Row, columns, matched []string) error
+	TableEnd() error
+	Close() error
+}
+
+// linePrinter prints a line per matching row, like grep does:
+//
+//	users:3:email: id=3, email=c@x.com, country=NULL
+type linePrinter struct {
+	w *bufio.Writer
+}
+
+func newLinePrinter(out io.Writer) *linePrinter {
+	return &linePrinter{w: bufio.NewWriter(out)}
+}
+
+func (p *linePrinter) TableStart(table string) error { return nil }
+func (p *linePrinter) TableEnd() error               { return p.w.Flush() }
+func (p *linePrinter) Close() error                  { return p.w.Flush() }
+
+func (p *linePrinter) Match(table string, row models.Row, columns, matched []string) error {
+	fmt.Fprintf(p.w, "%s:%d:%s: ", table, row.RowNumber, strings.Join(matched, ","))
+	for i, col := range columns {
+		if i > 0 {
+			p.w.WriteString(", ")
+		}
+		value := "NULL"
+		if v := row.Data[col]; v != nil {
+			// Keep a value with line breaks on its line
+			value = cellEscaper.Replace(fmt.Sprint(v))
+		}
+		fmt.Fprintf(p.w, "%s=%s", col, value)
+	}
+	return p.w.WriteByte('\n')
+}
+
+// writerPrinter writes the matching rows with one of the export writers,
+// optionally adding the matching columns to each row.
+type writerPrinter struct {
+	writer     writer.Writer
+	addMatched bool
+}
+
+func (p *writerPrinter) TableStart(table string) error { return p.writer.WriteTableStart(table) }
+func (p *writerPrinter) TableEnd() error               { return p.writer.WriteTableEnd() }
+func (p *writerPrinter) Close() error                  { return p.writer.Close() }
+
+func (p *writerPrinter) Match(table string, row models.Row, columns, matched []string) error {
+	if p.addMatched {
+		data := make(map[string]interface{}, len(row.Data)+1)
+		for col, value := range row.Data {
+			data[col] = value
+		}
+		data[matchedColumnsKey] = strings.Join(matched, ",")
+		row.Data = data
+	}
+	row.TableName = table
+	return p.writer.WriteRows([]models.Row{row})
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+)
+
+const grepDump = "CREATE TABLE `users` (\n" +
+	"  `id` int(11) NOT NULL,\n" +
+	"  `email` varchar(50) DEFAULT NULL,\n" +
+	"  `note` text,\n" +
+	"  PRIMARY KEY (`id`)\n" +
+	");\n" +
+	"INSERT INTO `users` VALUES (1,'O\\'Brien@x.com','it''s'),(2,'b@x.com','two\\nlines'),(3,'c:\\\\tmp',NULL);\n"
+
+func TestGrep(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		regex   bool
+		exact   bool
+		columns []string
+		want    string
+	}{
+		{
+			name:    "escaped quote",
+			pattern: "O'Brien",
+			want:    "users:1:email: id=1, email=O'Brien@x.com, note=it's\n",
+		},
+		{
+			name:    "doubled quote",
+			pattern: "it's",
+			want:    "users:1:note: id=1, email=O'Brien@x.com, note=it's\n",
+		},
+		{
+			name:    "escaped backslash",
+			pattern: `c:\tmp`,
+			exact:   true,
+			want:    "users:3:email: id=3, email=c:\\tmp, note=NULL\n",
+		},
+		{
+			name:    "line break is printed escaped",
+			pattern: "o\nl",
+			want:    "users:2:note: id=2, email=b@x.com, note=two\\nlines\n",
+		},
+		{
+			name:    "regex over decoded values",
+			pattern: `^[a-z]+'s$`,
+			regex:   true,
+			want:    "users:1:note: id=1, email=O'Brien@x.com, note=it's\n",
+		},
+		{
+			name:    "columns",
+			pattern: "x.com",
+			columns: []string{"e*"},
+			want:    "users:1:email: id=1, email=O'Brien@x.com, note=it's\nusers:2:email: id=2, email=b@x.com, note=two\\nlines\n",
+		},
+		{
+			name:    "no match",
+			pattern: `O\'Brien`,
+		},
+	}
+	file := filepath.Join(t.TempDir(), "dump.sql")
+	if err := os.WriteFile(file, []byte(grepDump), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := newValueMatcher(tt.pattern, tt.regex, false, tt.exact)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			g := &grep{
+				match:   match,
+				columns: tt.columns,
+				printer: newLinePrinter(&out),
+				schemas: make(map[string]*models.Schema),
+			}
+			input, err := parser.OpenInput(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer input.Close()
+			if _, err := parser.Stream(context.Background(), input, parser.Config{File: file, Workers: 1}, g); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.printer.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
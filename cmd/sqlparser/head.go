@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+	"sqlparser/pkg/writer"
+)
+
+// errHeadDone stops head once enough rows were read.
+var errHeadDone = errors.New("enough rows")
+
+func runHead(args []string) {
+	fs := flag.NewFlagSet("head", flag.ExitOnError)
+	table := fs.String("table", "", "Table to print the rows of (required)")
+	n := fs.Int("n", 10, "Number of rows to print")
+	format := fs.String("format", "table", "Output format (table, txt, csv, json, jsonl)")
+	output := fs.String("output", "", "Output file (default: stdout)")
+	workers := fs.Int("workers", getWorkerCount(), "Number of worker threads")
+	logs := addLogFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sqlparser head -table=name [-n=10] [-format=table|txt|csv|json|jsonl] [-output=file] <sqlfile>\n")
+		fmt.Fprintf(os.Stderr, "Prints the first rows of a table, reading no further into the dump than needed.\n")
+		fs.PrintDefaults()
+	}
+	positional := parseInterspersed(fs, args)
+	logs.setup()
+	if len(positional) != 1 || *table == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	filename := positional[0]
+	if *n < 1 {
+		fatal("-n must be at least 1", "n", *n)
+	}
+	if *format != "table" {
+		if _, err := writer.CreateWriter(models.OutputFormat(*format), io.Discard); err != nil {
+			fatal("invalid -format", "format", *format, "error", err)
+		}
+	}
+
+	schema, rows, seen, err := headTable(filename, *table, *n, *workers)
+	if err != nil {
+		fatal("error reading table", "error", err)
+	}
+	if schema == nil {
+		names := make([]string, 0, len(seen))
+		for name := range seen {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fatal("table not found", "table", *table, "tables", orNone(strings.Join(names, ", ")))
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fatal("error creating output file", "error", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	if *format == "table" {
+		err = printRowTable(out, schema.ColumnNames(), rows)
+	} else {
+		err = writeRows(out, models.OutputFormat(*format), schema, rows)
+	}
+	if err != nil {
+		fatal("error writing rows", "error", err)
+	}
+}
+
+// headTable reads the first n rows of a table in a single pass that stops as
+// soon as they are in. If an earlier scan of the dump indexed the table,
+// reading starts where the table does; otherwise the statements of other
+// tables are skipped by their first line without being parsed. It returns the schema of
+// the table, or nil if the dump has no such table, together with the names
+// of the tables that were passed on the way.
+func headTable(filename, table string, n, workers int) (*models.Schema, []models.Row, map[string]bool, error) {
+	seen := make(map[string]bool)
+	// Without an index, the first table of that name is read, whatever its
+	// database
+	var database *string
+	cfg := parser.Config{
+		File:      filename,
+		Workers:   workers,
+		BatchSize: n,
+		Tables: func(db, name string) bool {
+			seen[name] = true
+			if name != table || database != nil && db != *database {
+				return false
+			}
+			database = &db
+			return true
+		},
+	}
+	if tables, ok := parser.LoadTableIndex(filename); ok {
+		for _, t := range tables {
+			if t.Name == table {
+				cfg.StartLine, cfg.StartOffset, cfg.StartStatement = t.StartLine, t.StartOffset, t.StartStatement
+				cfg.StartDatabase, cfg.EndLine = t.StartDatabase, t.LineTo
+				database = &t.Database
+				break
+			}
+		}
+	}
+
+	input, err := parser.OpenInputAt(filename, cfg.StartOffset)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer input.Close()
+
+	var schema *models.Schema
+	var rows []models.Row
+	_, err = parser.Stream(context.Background(), input, cfg, parser.HandlerFuncs{
+		OnSchema: func(s *models.Schema) error {
+			schema = s
+			return nil
+		},
+		OnRows: func(_ string, batch []models.Row) error {
+			rows = append(rows, batch...)
+			if len(rows) >= n {
+				return errHeadDone
+			}
+			return nil
+		},
+	})
+	if err != nil && err != errHeadDone {
+		return nil, nil, nil, err
+	}
+	if len(rows) > n {
+		rows = rows[:n]
+	}
+	return schema, rows, seen, nil
+}
+
+// printRowTable prints rows as a table with aligned columns, in the order of
+// columns.
+func printRowTable(out io.Writer, columns []string, rows []models.Row) error {
+	printer := &tablePrinter{w: tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)}
+	if err := printer.Start(columns); err != nil {
+		return err
+	}
+	values := make([]interface{}, len(columns))
+	for i, row := range rows {
+		for j, col := range columns {
+			values[j] = row.Data[col]
+		}
+		if err := printer.Row(i+1, values); err != nil {
+			return err
+		}
+	}
+	return printer.Close()
+}
+
+// writeRows writes the rows of a table with one of the export writers.
+func writeRows(out io.Writer, format models.OutputFormat, schema *models.Schema, rows []models.Row) error {
+	w, err := writer.CreateWriter(format, out)
+	if err != nil {
+		return err
+	}
+	if sw, ok := w.(writer.SchemaWriter); ok {
+		if err := sw.WriteSchema(schema); err != nil {
+			return err
+		}
+	}
+	if err := w.WriteTableStart(schema.TableName); err != nil {
+		return err
+	}
+	if len(rows) > 0 {
+		if err := w.WriteRows(rows); err != nil {
+			return err
+		}
+	}
+	if err := w.WriteTableEnd(); err != nil {
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+)
+
+// headDump has users in two databases; only the first is read by head.
+const headDump = "CREATE TABLE `log` (\n  `id` int\n);\n" +
+	"INSERT INTO `log` VALUES (1),(2);\n" +
+	"USE `a`;\n" +
+	"CREATE TABLE `users` (\n  `id` int,\n  `name` varchar(10)\n);\n" +
+	"INSERT INTO `users` VALUES (1,'ann'),(2,'bob');\n" +
+	"INSERT INTO `users` VALUES (3,NULL),(4,'dee');\n" +
+	"USE `b`;\n" +
+	"CREATE TABLE `users` (\n  `id` int,\n  `name` varchar(10)\n);\n" +
+	"INSERT INTO `users` VALUES (5,'eve');\n"
+
+func TestHeadTable(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		n     int
+		want  []string
+	}{
+		{name: "fewer rows than the table", table: "users", n: 3, want: []string{"1", "2", "3"}},
+		{name: "only the first database", table: "users", n: 10, want: []string{"1", "2", "3", "4"}},
+		{name: "first table", table: "log", n: 1, want: []string{"1"}},
+		{name: "missing table", table: "orders", n: 1},
+	}
+	for _, indexed := range []bool{false, true} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s indexed=%v", tt.name, indexed), func(t *testing.T) {
+				t.Setenv("XDG_CACHE_HOME", t.TempDir())
+				filename := filepath.Join(t.TempDir(), "dump.sql")
+				if err := os.WriteFile(filename, []byte(headDump), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if indexed {
+					if _, err := parser.ScanTables(filename); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				schema, rows, seen, err := headTable(filename, tt.table, tt.n, 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.want == nil {
+					if schema != nil {
+						t.Errorf("got schema %+v of a missing table", schema)
+					}
+					if !seen["users"] || !seen["log"] {
+						t.Errorf("tables seen: %v", seen)
+					}
+					return
+				}
+				if schema == nil || schema.TableName != tt.table {
+					t.Fatalf("got schema %+v", schema)
+				}
+				var got []string
+				for _, row := range rows {
+					got = append(got, row.Data["id"].(string))
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("got ids %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestPrintRowTable(t *testing.T) {
+	rows := []models.Row{
+		{Data: map[string]interface{}{"id": "1", "name": "ann"}},
+		{Data: map[string]interface{}{"id": "22", "name": nil}},
+	}
+	var out bytes.Buffer
+	if err := printRowTable(&out, []string{"name", "id"}, rows); err != nil {
+		t.Fatal(err)
+	}
+	if want := "name  id\nann   1\nNULL  22\n"; out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"sqlparser/pkg/parser"
+	"sqlparser/pkg/progress"
+)
+
+func runInspect(args []string) {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	format := fs.String("format", "table", "Output format (table, json)")
+	logs := addLogFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sqlparser inspect [-format=table|json] <sqlfile>\n")
+		fmt.Fprintf(os.Stderr, "Summarizes the dialect, header, databases and tables of a dump.\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	logs.setup()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	info, err := parser.Inspect(fs.Arg(0))
+	if err != nil {
+		fatal("error inspecting file", "error", err)
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(info)
+	case "table":
+		err = printDumpInfo(os.Stdout, info)
+	default:
+		fatal("invalid -format", "format", *format)
+	}
+	if err != nil {
+		fatal("error writing output", "error", err)
+	}
+}
+
+func printDumpInfo(out io.Writer, info *parser.DumpInfo) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	size := progress.FormatBytes(info.Size)
+	if info.Compressed {
+		size += ", gzip-compressed"
+	}
+	fmt.Fprintf(w, "File:\t%s (%s)\n", info.File, size)
+	fmt.Fprintf(w, "Dialect:\t%s\n", info.Dialect)
+	if h := info.Header; h.Tool != "" {
+		fmt.Fprintf(w, "Dumped by:\t%s\n", h.Tool)
+	}
+	if h := info.Header; h.ServerVersion != "" {
+		fmt.Fprintf(w, "Server version:\t%s\n", h.ServerVersion)
+	}
+	if h := info.Header; h.Host != "" {
+		fmt.Fprintf(w, "Host:\t%s\n", h.Host)
+	}
+	fmt.Fprintf(w, "Databases:\t%s\n", orNone(strings.Join(info.Databases, ", ")))
+	fmt.Fprintf(w, "Statements:\t%d\n", info.Statements)
+	fmt.Fprintf(w, "Tables:\t%d\n", len(info.Tables))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(info.Tables) == 0 {
+		return nil
+	}
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "TABLE\tDATABASE\tSTATEMENTS\tROWS\tSIZE\tLINES\n")
+	for _, t := range info.Tables {
+		lines := "-"
+		if t.Statements > 0 {
+			lines = fmt.Sprintf("%d-%d", t.LineFrom, t.LineTo)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\n", t.Name, orNone(t.Database), t.Statements, t.Rows, progress.FormatBytes(t.Bytes), lines)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, t := range info.Tables {
+		fmt.Fprintf(out, "\n%s:\n", t.Name)
+		if len(t.Columns) == 0 {
+			fmt.Fprintf(out, "  no column information\n")
+			continue
+		}
+		w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, col := range t.Columns {
+			var attrs []string
+			if !col.Nullable {
+				attrs = append(attrs, "NOT NULL")
+			}
+			for _, pk := range t.PrimaryKey {
+				if pk == col.Name {
+					attrs = append(attrs, "PRIMARY KEY")
+				}
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", col.Name, orNone(col.Type), strings.Join(attrs, " "))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logFlags are the flags that configure diagnostics, which the export and
+// every subcommand have.
+type logFlags struct {
+	level  *string
+	format *string
+	quiet  *bool
+}
+
+func addLogFlags(fs *flag.FlagSet) *logFlags {
+	return &logFlags{
+		level:  fs.String("log-level", "info", "Minimum level of diagnostics to log (debug, info, warn, error)"),
+		format: fs.String("log-format", "text", "Format of diagnostics on stderr (text, json)"),
+		quiet:  fs.Bool("quiet", false, "Only log errors and don't report progress"),
+	}
+}
+
+// setup makes the logger the flags describe the default one. It has to be
+// called once the flags are parsed and before anything is logged.
+func (f *logFlags) setup() {
+	logger, err := newLogger(*f.level, *f.format, *f.quiet)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+}
+
+// newLogger creates the logger for diagnostics, which always go to stderr so
+// that stdout only carries exported data.
+func newLogger(level, format string, quiet bool) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q (want debug, info, warn or error)", level)
+	}
+	if quiet {
+		lvl = slog.LevelError
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q (want text or json)", format)
+	}
+}
+
+// fatal logs msg as an error and exits with status 1.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	if report != nil {
+		report.Error = msg
+		for i := 0; i+1 < len(args); i += 2 {
+			if args[i] == "error" {
+				report.Error = fmt.Sprintf("%s: %v", msg, args[i+1])
+			}
+		}
+	}
+	exit(1)
+}
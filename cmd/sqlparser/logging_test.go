@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		args    []string
+		handler string
+		lowest  slog.Level // lowest level logged
+		err     bool
+	}{
+		{args: nil, handler: "*slog.TextHandler", lowest: slog.LevelInfo},
+		{args: []string{"-log-level=debug"}, handler: "*slog.TextHandler", lowest: slog.LevelDebug},
+		{args: []string{"-log-level=WARN", "-log-format=json"}, handler: "*slog.JSONHandler", lowest: slog.LevelWarn},
+		{args: []string{"-log-format=JSON"}, handler: "*slog.JSONHandler", lowest: slog.LevelInfo},
+		{args: []string{"-quiet", "-log-level=debug"}, handler: "*slog.TextHandler", lowest: slog.LevelError},
+		{args: []string{"-log-level=verbose"}, err: true},
+		{args: []string{"-log-format=xml"}, err: true},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		f := addLogFlags(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		logger, err := newLogger(*f.level, *f.format, *f.quiet)
+		if (err != nil) != tt.err {
+			t.Errorf("%v: error %v", tt.args, err)
+			continue
+		}
+		if tt.err {
+			continue
+		}
+		if got := fmt.Sprintf("%T", logger.Handler()); got != tt.handler {
+			t.Errorf("%v: handler is %s, want %s", tt.args, got, tt.handler)
+		}
+		for _, level := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError} {
+			if got, want := logger.Enabled(context.Background(), level), level >= tt.lowest; got != want {
+				t.Errorf("%v: %s enabled = %v, want %v", tt.args, level, got, want)
+			}
+		}
+	}
+}
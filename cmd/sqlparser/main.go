@@ -1,49 +1,338 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
 
+	"sqlparser/pkg/checkpoint"
+	"sqlparser/pkg/job"
 	"sqlparser/pkg/models"
 	"sqlparser/pkg/parser"
+	"sqlparser/pkg/progress"
+	"sqlparser/pkg/tui"
 	"sqlparser/pkg/writer"
+
+	"golang.org/x/term"
 )
 
+// commands are the subcommands, run as "sqlparser <command> [flags] <sqlfile>".
+// Without a subcommand, sqlparser exports tables.
+var commands = map[string]func(args []string){
+	"diff":     runDiff,
+	"grep":     runGrep,
+	"head":     runHead,
+	"inspect":  runInspect,
+	"profile":  runProfile,
+	"query":    runQuery,
+	"split":    runSplit,
+	"validate": runValidate,
+}
+
 func main() {
-	format := flag.String("format", "", "Output format (txt, csv, json, jsonl)")
-	output := flag.String("output", "", "Output file (for single table export)")
-	workers := flag.Int("workers", getWorkerCount(), "Number of worker threads")
-	exportAll := flag.Bool("all", false, "Export all tables (creates a directory named after the input file)")
+	if len(os.Args) > 1 {
+		if run, ok := commands[os.Args[1]]; ok {
+			run(os.Args[2:])
+			return
+		}
+	}
+
+	var o exportOptions
+	configPath := flag.String("config", "", "Job file (YAML) describing the export; flags given on the command line override it")
+	flag.StringVar(&o.format, "format", "", "Output format (txt, csv, json, jsonl, parquet, arrow, arrows, avro)")
+	flag.StringVar(&o.compression, "compression", "", "Compression of parquet output (snappy, zstd, gzip, none; default: snappy), arrow output (zstd, none; default: none) or avro output (deflate, snappy, zstd, none; default: none)")
+	flag.BoolVar(&o.avroSchema, "avro-schema", false, "Also write the schema of avro output to a .avsc file next to each output file")
+	flag.StringVar(&o.output, "output", "", "Output file (for single table export)")
+	flag.StringVar(&o.outputDir, "output-dir", "", "Directory for one file per table (default: named after the input file)")
+	flag.IntVar(&o.workers, "workers", getWorkerCount(), "Number of worker threads")
+	flag.IntVar(&o.batchSize, "batch-size", models.BatchSize, "Number of rows handed to the writer at a time")
+	flag.BoolVar(&o.exportAll, "all", false, "Export all tables (creates a directory named after the input file)")
+	flag.StringVar(&o.dialect, "dialect", "", "SQL dialect the dump is expected to be in (mysql, mariadb, postgresql, sqlite); a warning is logged if it looks different")
+	flag.StringVar(&o.onError, "on-error", string(parser.ErrorPolicySkip), "What to do with statements that fail to parse (fail, skip, quarantine)")
+	flag.StringVar(&o.rejectsPath, "rejects", "", "File that receives quarantined statements (default: <input>.rejects.sql)")
+	flag.BoolVar(&o.strict, "strict", false, "Exit with a non-zero status if any statement was rejected")
+	flag.StringVar(&o.reportPath, "report", "", "Write a JSON summary of the run to this file")
+	flag.BoolVar(&o.dryRun, "dry-run", false, "Estimate the rows, output size and time of the export from a sample of each table without writing anything")
+	showProgress := flag.Bool("progress", true, "Report progress on stderr while processing")
+	logs := addLogFlags(flag.CommandLine)
+	flag.StringVar(&o.checkpointPath, "checkpoint", "", "File that records progress when interrupted (default: <input>.checkpoint.json)")
+	flag.BoolVar(&o.resume, "resume", false, "Resume an interrupted export from its checkpoint")
+	flag.StringVar(&o.includeTables, "tables", "", "Comma-separated tables to export: names, globs, /regexps/, optionally as db.table")
+	flag.StringVar(&o.excludeTables, "exclude-tables", "", "Comma-separated tables to leave out, in the same syntax as -tables")
 	flag.Parse()
 
-	args := flag.Args()
-	if len(args) < 1 {
-		fmt.Printf("Usage: sqlparser [-format=txt|csv|json] [-output=filename] [-workers=N] [-all] <sqlfile>\n")
-		fmt.Printf("  -format: Output format (txt, csv, json, jsonl). If specified without -output, creates files in a directory\n")
-		fmt.Printf("  -output: Output file (optional, defaults to directory output if format is specified)\n")
-		fmt.Printf("  -workers: Number of worker threads (default: %d)\n", getWorkerCount())
-		fmt.Printf("  -all: Export all tables into separate files (default: false)\n")
+	inputs := flag.Args()
+	if *configPath != "" {
+		j, err := job.Load(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		o.applyJob(j)
+		if len(inputs) == 0 {
+			inputs = j.Inputs
+		}
+	}
+
+	if len(inputs) < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: sqlparser [-config=job.yaml] [-format=txt|csv|json|jsonl|parquet|arrow|arrows|avro] [-output=filename] [-workers=N] [-all] [-on-error=fail|skip|quarantine] <sqlfile>...\n")
+		fmt.Fprintf(os.Stderr, "  -config: Job file (YAML) with the settings of the export; flags given on the command line override it\n")
+		fmt.Fprintf(os.Stderr, "  -format: Output format (txt, csv, json, jsonl, parquet, arrow, arrows, avro). If specified without -output, creates files in a directory\n")
+		fmt.Fprintf(os.Stderr, "  -compression: Compression of parquet output: snappy, zstd, gzip or none (default: snappy); of arrow output: zstd or none (default: none); of avro output: deflate, snappy, zstd or none (default: none)\n")
+		fmt.Fprintf(os.Stderr, "  -avro-schema: Also write the schema of avro output to a .avsc file next to each output file (default: false)\n")
+		fmt.Fprintf(os.Stderr, "  -output: Output file (optional, defaults to directory output if format is specified)\n")
+		fmt.Fprintf(os.Stderr, "  -output-dir: Directory for one file per table (default: named after the input file)\n")
+		fmt.Fprintf(os.Stderr, "  -workers: Number of worker threads (default: %d)\n", getWorkerCount())
+		fmt.Fprintf(os.Stderr, "  -batch-size: Number of rows handed to the writer at a time (default: %d)\n", models.BatchSize)
+		fmt.Fprintf(os.Stderr, "  -all: Export all tables into separate files (default: false)\n")
+		fmt.Fprintf(os.Stderr, "  -dialect: SQL dialect the dump is expected to be in: mysql, mariadb, postgresql or sqlite\n")
+		fmt.Fprintf(os.Stderr, "  -on-error: Error policy for unparsable statements: fail, skip or quarantine (default: skip)\n")
+		fmt.Fprintf(os.Stderr, "  -rejects: File for quarantined statements (default: <input>.rejects.sql)\n")
+		fmt.Fprintf(os.Stderr, "  -strict: Exit with status %d if any statement was rejected (default: false)\n", exitRejected)
+		fmt.Fprintf(os.Stderr, "  -report: Write a JSON summary of the run with per-table counts, outputs and checksums to this file\n")
+		fmt.Fprintf(os.Stderr, "  -dry-run: Estimate rows, output size and time from a sample of each table without writing anything (default: false)\n")
+		fmt.Fprintf(os.Stderr, "  -progress: Report progress on stderr, as a progress bar on a terminal (default: true)\n")
+		fmt.Fprintf(os.Stderr, "  -log-level: Minimum level of diagnostics: debug, info, warn or error (default: info)\n")
+		fmt.Fprintf(os.Stderr, "  -log-format: Format of diagnostics on stderr: text or json (default: text)\n")
+		fmt.Fprintf(os.Stderr, "  -quiet: Only log errors and don't report progress (default: false)\n")
+		fmt.Fprintf(os.Stderr, "  -checkpoint: File that records progress when interrupted (default: <input>.checkpoint.json)\n")
+		fmt.Fprintf(os.Stderr, "  -resume: Resume an interrupted export from its checkpoint (default: false)\n")
+		fmt.Fprintf(os.Stderr, "  -tables: Tables to export as a comma-separated list of names, globs or /regexps/, optionally db.table\n")
+		fmt.Fprintf(os.Stderr, "  -exclude-tables: Tables to leave out, in the same syntax as -tables\n")
+		fmt.Fprintf(os.Stderr, "\nCommands (run with -h for their flags):\n")
+		fmt.Fprintf(os.Stderr, "  diff: Compare the rows of two dumps by primary key\n")
+		fmt.Fprintf(os.Stderr, "  grep: Search the values of a dump and print the matching rows\n")
+		fmt.Fprintf(os.Stderr, "  head: Print the first rows of a table without reading the rest of the dump\n")
+		fmt.Fprintf(os.Stderr, "  inspect: Summarize the dialect, header, databases and tables of a dump\n")
+		fmt.Fprintf(os.Stderr, "  profile: Compute per-column statistics of tables without exporting them\n")
+		fmt.Fprintf(os.Stderr, "  query: Run a SELECT query with joins, grouping and aggregates against a dump\n")
+		fmt.Fprintf(os.Stderr, "  split: Write every table to its own restorable SQL file\n")
+		fmt.Fprintf(os.Stderr, "  validate: Check a dump for syntax errors, truncation, column count mismatches and duplicate keys\n")
 		os.Exit(1)
 	}
 
-	filename := args[0]
+	logs.setup()
+	report = newRunReport(o.reportPath)
+	var err error
+
+	if len(inputs) > 1 && (o.output != "" || o.checkpointPath != "" || o.rejectsPath != "") {
+		fatal("-output, -checkpoint and -rejects name a single file and can't be used with more than one input")
+	}
+	if o.dryRun && o.resume {
+		fatal("-dry-run can't be used with -resume")
+	}
+	if o.batchSize < 1 {
+		fatal("invalid -batch-size", "batch_size", o.batchSize)
+	}
+	models.BatchSize = o.batchSize
+	format := models.OutputFormat(o.format)
+	if format == "" {
+		format = models.FormatText
+	}
+	if err := writer.CheckCompression(format, o.compression); err != nil {
+		fatal("invalid -compression", "error", err)
+	}
+	writer.Compression = o.compression
+	if o.avroSchema && format != models.FormatAvro {
+		fatal("-avro-schema only applies to -format=avro")
+	}
+	if o.dialect != "" && o.dialect != "auto" {
+		if o.dialect, err = parser.ParseDialect(o.dialect); err != nil {
+			fatal("invalid -dialect", "error", err)
+		}
+	}
+	if o.policy, err = parser.ParseErrorPolicy(o.onError); err != nil {
+		fatal("invalid -on-error", "error", err)
+	}
+	if o.filter, err = parser.ParseTableFilter(o.includeTables, o.excludeTables); err != nil {
+		fatal("invalid table selection", "error", err)
+	}
+	if *showProgress && !*logs.quiet {
+		o.progress = progress.New(os.Stderr)
+	}
+
+	// The first SIGINT or SIGTERM stops processing gracefully, so that output
+	// files are complete and a checkpoint is written; a second one kills the
+	// process as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+		slog.Warn("interrupted, finishing the current batch (interrupt again to exit immediately)")
+	}()
+
+	rejected := false
+	for _, filename := range inputs {
+		if exportFile(ctx, filename, o, len(inputs) > 1) {
+			rejected = true
+		}
+	}
+	if rejected && o.strict {
+		exit(exitRejected)
+	}
+	exit(0)
+}
+
+// exportOptions are the settings of an export, from the flags and the job
+// file.
+type exportOptions struct {
+	format         string
+	compression    string
+	avroSchema     bool
+	output         string
+	outputDir      string
+	workers        int
+	batchSize      int
+	exportAll      bool
+	dialect        string
+	onError        string
+	rejectsPath    string
+	strict         bool
+	reportPath     string
+	dryRun         bool
+	checkpointPath string
+	resume         bool
+	includeTables  string
+	excludeTables  string
+
+	policy    parser.ErrorPolicy
+	filter    *parser.TableFilter
+	progress  *progress.Reporter
+	transform func(writer.Writer) writer.Writer
+}
+
+// applyJob takes the settings of a job file for the flags that weren't given
+// on the command line.
+func (o *exportOptions) applyJob(j *job.Job) {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	str := func(name string, dst *string, value string) {
+		if !set[name] && value != "" {
+			*dst = value
+		}
+	}
+	num := func(name string, dst *int, value int) {
+		if !set[name] && value != 0 {
+			*dst = value
+		}
+	}
+	boolean := func(name string, dst *bool, value bool) {
+		if !set[name] && value {
+			*dst = value
+		}
+	}
+
+	str("format", &o.format, j.Output.Format)
+	str("compression", &o.compression, j.Output.Compression)
+	boolean("avro-schema", &o.avroSchema, j.Output.AvroSchema)
+	str("output", &o.output, j.Output.Path)
+	str("output-dir", &o.outputDir, j.Output.Dir)
+	str("dialect", &o.dialect, j.Dialect)
+	str("tables", &o.includeTables, strings.Join(j.Tables.Include, ","))
+	str("exclude-tables", &o.excludeTables, strings.Join(j.Tables.Exclude, ","))
+	boolean("all", &o.exportAll, j.Tables.All)
+	str("on-error", &o.onError, j.Errors.Policy)
+	str("rejects", &o.rejectsPath, j.Errors.Rejects)
+	boolean("strict", &o.strict, j.Errors.Strict)
+	str("report", &o.reportPath, j.Output.Report)
+	num("workers", &o.workers, j.Performance.Workers)
+	num("batch-size", &o.batchSize, j.Performance.BatchSize)
+	o.transform = j.Writer
+}
 
-	// Scan for tables
-	tables, err := parser.ScanTables(filename)
+// exportFile exports the selected tables of a dump, reporting whether
+// statements were rejected. With more than one input, every input gets its
+// own output directory.
+func exportFile(ctx context.Context, filename string, o exportOptions, multiple bool) bool {
+	checkpointPath := o.checkpointPath
+	if checkpointPath == "" {
+		checkpointPath = besideInput(filename, ".checkpoint.json")
+	}
+
+	if o.dialect != "" && o.dialect != "auto" {
+		detected, err := parser.DetectDialect(filename)
+		if err != nil {
+			fatal("error reading file", "error", err)
+		}
+		if detected != o.dialect && detected != parser.DialectUnknown {
+			slog.Warn("the dump looks like another dialect than expected", "file", filename, "expected", o.dialect, "detected", detected)
+		}
+	}
+
+	input := report.addInput(filename)
+	var rejects *os.File
+	rejectsPath := o.rejectsPath
+	if o.policy == parser.ErrorPolicyQuarantine && !o.dryRun {
+		if rejectsPath == "" {
+			rejectsPath = besideInput(filename, ".rejects.sql")
+		}
+		// A resumed export adds to the statements quarantined before it
+		// was interrupted
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if o.resume {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		var err error
+		rejects, err = os.OpenFile(rejectsPath, flags, 0644)
+		if err != nil {
+			fatal("error creating rejects file", "error", err)
+		}
+		defer rejects.Close()
+		input.RejectsFile = rejectsPath
+	}
+	errs := parser.NewErrorHandler(o.policy, rejects)
+	countRejects := func() {
+		input.RejectedStatements, input.RejectedRows = errs.RejectedStatements, errs.RejectedRows
+	}
+	filter := o.filter
+
+	// Scan for tables. The table browser shows row counts and columns, which
+	// take a slower scan to find
+	browse := !o.resume && !o.exportAll && filter.IsEmpty() && tui.Available()
+	var tables []parser.TableInfo
+	var err error
+	if browse {
+		slog.Info("scanning tables", "file", filename)
+		tables, err = parser.ScanTableDetails(filename)
+	} else {
+		tables, err = parser.ScanTables(filename)
+	}
 	if err != nil {
-		fmt.Printf("Error scanning tables: %v\n", err)
-		os.Exit(1)
+		fatal("error scanning tables", "error", err)
 	}
 
 	var selectedTables []*parser.TableInfo
-	if *exportAll {
-		selectedTables = make([]*parser.TableInfo, len(tables))
-		for i := range tables {
-			selectedTables[i] = &tables[i]
+	var cp *checkpoint.Checkpoint
+	if o.resume {
+		cp, err = checkpoint.Load(checkpointPath)
+		if err != nil {
+			fatal("error loading checkpoint", "error", err)
+		}
+		selectedTables = resumeTables(tables, cp)
+		slog.Info("resuming from checkpoint", "checkpoint", checkpointPath,
+			"completed_tables", len(cp.Completed), "table", cp.Table, "line", cp.Line)
+	} else if o.exportAll || !filter.IsEmpty() {
+		if selectedTables, err = filter.Select(tables); err != nil {
+			fatal("error selecting tables", "error", err)
+		}
+		slog.Info("selected tables", "tables", tableNames(selectedTables))
+	} else if browse {
+		if selectedTables, err = tui.SelectTables(filename, tables); err != nil {
+			fatal("error selecting tables", "error", err)
 		}
-		fmt.Printf("\nExporting all %d tables\n", len(tables))
+		slog.Info("selected tables", "tables", tableNames(selectedTables))
+	} else if !term.IsTerminal(int(os.Stdin.Fd())) {
+		// Don't wait for an answer that will never come in scripts and CI
+		fatal("no tables selected: use -tables, -exclude-tables or -all when stdin is not a terminal")
 	} else {
 		selectedTable, err := parser.PromptTableSelection(tables)
 		if err != nil {
@@ -53,59 +342,275 @@ func main() {
 				for i := range allSelected.Tables {
 					selectedTables[i] = &allSelected.Tables[i]
 				}
-				fmt.Printf("\nExporting all %d tables\n", len(tables))
+				slog.Info("exporting all tables", "tables", len(tables))
 			} else {
-				fmt.Printf("Error selecting table: %v\n", err)
-				os.Exit(1)
+				fatal("error selecting table", "error", err)
 			}
 		} else {
 			selectedTables = []*parser.TableInfo{selectedTable}
-			fmt.Printf("\nSelected table: %s\n", selectedTable.Name)
+			slog.Info("selected table", "table", selectedTable.Name)
 		}
 	}
 
 	// If format is specified but no output, use directory output by default
-	useDirectoryOutput := *exportAll || len(selectedTables) > 1 || (*format != "" && *output == "")
-	outputFormat := models.OutputFormat(*format)
-	if *format == "" {
+	useDirectoryOutput := o.exportAll || multiple || o.outputDir != "" || len(selectedTables) > 1 ||
+		cp != nil && len(cp.Tables) > 1 || (o.format != "" && o.output == "")
+	outputFormat := models.OutputFormat(o.format)
+	if o.format == "" {
 		outputFormat = models.FormatText // default to text if no format specified
 	}
+	if useDirectoryOutput {
+		// Files are named after tables, so tables of the same name in
+		// different databases would overwrite each other's
+		seen := make(map[string]bool)
+		for _, table := range selectedTables {
+			if seen[table.Name] {
+				fatal("tables of the same name in several databases can't be exported to one directory: select one with -tables=<database>.<table>", "table", table.Name)
+			}
+			seen[table.Name] = true
+		}
+	}
+
+	if o.dryRun {
+		if err := dryRun(ctx, filename, selectedTables, outputFormat, o, multiple); err != nil {
+			fatal("error estimating export", "error", err)
+		}
+		return false
+	}
+
+	// Whether the output of the interrupted table is continued rather than
+	// written again
+	continueOutput := false
+	if cp == nil {
+		if cp, err = checkpoint.New(filename, string(outputFormat), tableNames(selectedTables)); err != nil {
+			fatal("error creating checkpoint", "error", err)
+		}
+	} else {
+		if cp.Format != "" && cp.Format != string(outputFormat) {
+			fatal("the interrupted export was to another format", "format", cp.Format)
+		}
+		continueOutput = cp.Table != "" && cp.Output != "" && outputFormat.Appendable()
+		if !useDirectoryOutput && o.output != "" {
+			switch {
+			case !outputFormat.Appendable():
+				fatal("-resume can't add to a single " + o.format + " file: export to a directory, or without -resume")
+			case !continueOutput:
+				fatal("the checkpoint doesn't record the output of the interrupted export")
+			case filepath.Clean(cp.Output) != filepath.Clean(o.output):
+				fatal("the interrupted export was to another output file", "output", cp.Output)
+			}
+		}
+		if useDirectoryOutput && cp.Table != "" && !continueOutput {
+			// The file of the interrupted table can't be added to, so it is
+			// written again from the start of the table
+			for _, table := range selectedTables {
+				if table.Name == cp.Table {
+					table.ResumeLine, table.ResumeRows = 0, 0
+				}
+			}
+			slog.Info("exporting the interrupted table again", "table", cp.Table)
+		}
+	}
 
 	var w writer.Writer
+	var mw *writer.MultiWriter
+	var outFile *os.File
 	if useDirectoryOutput {
-		w, err = writer.CreateMultiWriter(outputFormat, filename)
+		switch {
+		case o.outputDir != "" && multiple:
+			mw, err = writer.CreateMultiWriterIn(outputFormat, filepath.Join(o.outputDir, inputBase(filename)))
+		case o.outputDir != "":
+			mw, err = writer.CreateMultiWriterIn(outputFormat, o.outputDir)
+		default:
+			mw, err = writer.CreateMultiWriter(outputFormat, filename)
+		}
+		if mw != nil {
+			if continueOutput {
+				// Keep the part of the interrupted table that was already exported
+				mw.Continue(cp.Table, cp.Output, cp.OutputSize)
+			}
+			mw.SchemaFiles = o.avroSchema
+			w = mw
+		}
 	} else {
-		if *output == "" {
+		if o.output == "" {
+			if o.avroSchema {
+				fatal("-avro-schema needs an output file or directory to write the schema next to")
+			}
 			w, err = writer.CreateWriter(outputFormat, os.Stdout)
 		} else {
-			out, err := os.Create(*output)
+			if continueOutput {
+				outFile, err = writer.OpenAppend(o.output, cp.OutputSize)
+			} else {
+				outFile, err = os.Create(o.output)
+			}
 			if err != nil {
-				fmt.Printf("Error creating output file: %v\n", err)
-				os.Exit(1)
+				fatal("error creating output file", "error", err)
 			}
-			defer out.Close()
-			w, err = writer.CreateWriter(outputFormat, out)
+			w, err = writer.CreateWriter(outputFormat, outFile)
 			if err != nil {
-				fmt.Printf("Error creating writer: %v\n", err)
-				os.Exit(1)
+				fatal("error creating writer", "error", err)
+			}
+			if cw, ok := w.(writer.ContinuingWriter); ok && continueOutput {
+				cw.ContinueTable(cp.Table)
+			}
+			if aw, ok := w.(*writer.AvroWriter); ok && o.avroSchema {
+				aw.SchemaPath = strings.TrimSuffix(o.output, filepath.Ext(o.output)) + ".avsc"
 			}
 		}
 	}
 
 	if err != nil {
-		fmt.Printf("Error creating writer: %v\n", err)
-		os.Exit(1)
+		fatal("error creating writer", "error", err)
+	}
+	if o.transform != nil {
+		w = o.transform(w)
 	}
-	defer w.Close()
 
 	// Process each selected table
-	fmt.Printf("Processing with %d workers...\n", *workers)
+	slog.Info("processing", "workers", o.workers)
 	for _, table := range selectedTables {
-		if err := parser.ProcessSQLFileInBatches(filename, w, *workers, table); err != nil {
-			fmt.Printf("Error processing table %s: %v\n", table.Name, err)
-			os.Exit(1)
+		tableReport := report.startTable(filename, table.Name)
+		statementsBefore, rowsBefore := errs.RejectedStatements, errs.RejectedRows
+		stats, err := parser.ProcessSQLFileInBatches(ctx, filename, w, o.workers, table, errs, o.progress)
+		endTable := func(status string) {
+			tableReport.end(status, stats, errs.RejectedStatements-statementsBefore, errs.RejectedRows-rowsBefore)
+			switch {
+			case mw != nil:
+				tableReport.Output = mw.Path(table.Name)
+			case o.output != "":
+				tableReport.Output = o.output
+			}
+			if tableReport.Output != "" {
+				report.addOutput(tableReport.Output)
+			}
+			countRejects()
+		}
+		if errors.Is(err, context.Canceled) && stats != nil {
+			// Everything up to the last handled statement has been written
+			line, rows := table.ResumeLine, table.ResumeRows+stats.Rows
+			if stats.LastLine > 0 {
+				line = stats.LastLine + 1
+			}
+			cp.Interrupt(table.Name, line, rows)
+			closeErr := w.Close()
+			flushErr := errs.Flush()
+			if closeErr != nil {
+				fatal("error closing output", "error", closeErr)
+			}
+			if flushErr != nil {
+				fatal("error writing rejects file", "error", flushErr)
+			}
+			output := o.output
+			if mw != nil {
+				output = mw.Path(table.Name)
+			}
+			if err := cp.Written(output); err != nil {
+				fatal("error writing checkpoint", "error", err)
+			}
+			if err := cp.Save(checkpointPath); err != nil {
+				fatal("error writing checkpoint", "error", err)
+			}
+			slog.Warn("export interrupted, run again with -resume to continue",
+				"table", table.Name, "line", line, "checkpoint", checkpointPath)
+			endTable("interrupted")
+			exit(exitInterrupted)
+		}
+		if err != nil {
+			var perr *parser.ParseError
+			if errors.As(err, &perr) {
+				slog.Error("error processing table", "table", table.Name, "error", perr)
+			} else {
+				slog.Error("error processing table", "table", table.Name, "error", err)
+			}
+			w.Close()
+			if err := errs.Flush(); err != nil {
+				slog.Error("error writing rejects file", "error", err)
+			}
+			endTable("failed")
+			report.Error = err.Error()
+			exit(1)
+		}
+		endTable("completed")
+		cp.Complete(table.Name)
+	}
+
+	// Formats with a footer only finish their files here
+	if err := w.Close(); err != nil {
+		fatal("error closing output", "error", err)
+	}
+	if outFile != nil {
+		if err := outFile.Close(); err != nil {
+			fatal("error closing output file", "error", err)
+		}
+	}
+
+	if err := os.Remove(checkpointPath); err != nil && !os.IsNotExist(err) {
+		slog.Warn("error removing checkpoint", "error", err)
+	}
+
+	if err := errs.Flush(); err != nil {
+		fatal("error writing rejects file", "error", err)
+	}
+
+	if errs.Rejected() {
+		if o.policy == parser.ErrorPolicyQuarantine {
+			slog.Warn("rejected input", "file", filename, "statements", errs.RejectedStatements, "rows", errs.RejectedRows, "rejects_file", rejectsPath)
+		} else {
+			slog.Warn("rejected input", "file", filename, "statements", errs.RejectedStatements, "rows", errs.RejectedRows)
+		}
+	}
+	return errs.Rejected()
+}
+
+// exitRejected is the exit status used by -strict when statements were rejected.
+const exitRejected = 2
+
+// exitInterrupted is the exit status after an export was stopped by a signal,
+// following the shell convention of 128 + SIGINT.
+const exitInterrupted = 130
+
+// besideInput returns the path of a file next to the input, named after it
+// with ext.
+func besideInput(filename, ext string) string {
+	return filepath.Join(filepath.Dir(filename), inputBase(filename)+ext)
+}
+
+// inputBase returns the file name of the input without directory and
+// extensions, used to name files derived from it.
+func inputBase(filename string) string {
+	base := strings.TrimSuffix(filepath.Base(filename), ".gz")
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+func tableNames(tables []*parser.TableInfo) []string {
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = table.Name
+	}
+	return names
+}
+
+// resumeTables returns the tables of a checkpointed export that remain to be
+// exported, with the interrupted one set to continue where it stopped.
+func resumeTables(tables []parser.TableInfo, cp *checkpoint.Checkpoint) []*parser.TableInfo {
+	byName := make(map[string]*parser.TableInfo, len(tables))
+	for i := range tables {
+		byName[tables[i].Name] = &tables[i]
+	}
+
+	var selected []*parser.TableInfo
+	for _, name := range cp.Tables {
+		table, ok := byName[name]
+		if !ok || cp.IsCompleted(name) {
+			continue
+		}
+		if name == cp.Table {
+			table.ResumeLine, table.ResumeRows = cp.Line, cp.Rows
 		}
+		selected = append(selected, table)
 	}
+	return selected
 }
 
 func getWorkerCount() int {
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+	"sqlparser/pkg/profile"
+)
+
+func runProfile(args []string) {
+	fs := flag.NewFlagSet("profile", flag.ExitOnError)
+	format := fs.String("format", "markdown", "Output format (markdown, json)")
+	includeTables := fs.String("tables", "", "Comma-separated tables to profile, in the same syntax as for exports (default: all)")
+	excludeTables := fs.String("exclude-tables", "", "Comma-separated tables to leave out")
+	top := fs.Int("top", 5, "Number of most frequent values reported per column")
+	workers := fs.Int("workers", getWorkerCount(), "Number of worker threads")
+	logs := addLogFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sqlparser profile [-format=markdown|json] [-tables=...] [-top=N] <sqlfile>\n")
+		fmt.Fprintf(os.Stderr, "Computes per-column statistics of tables without exporting their rows.\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	logs.setup()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *format != "markdown" && *format != "json" {
+		fatal("invalid -format", "format", *format)
+	}
+	if *top < 0 {
+		fatal("invalid -top", "top", *top)
+	}
+	filename := fs.Arg(0)
+
+	filter, err := parser.ParseTableFilter(*includeTables, *excludeTables)
+	if err != nil {
+		fatal("invalid table selection", "error", err)
+	}
+	tables, err := parser.ScanTables(filename)
+	if err != nil {
+		fatal("error scanning tables", "error", err)
+	}
+	selected, err := filter.Select(tables)
+	if err != nil {
+		fatal("error selecting tables", "error", err)
+	}
+
+	profiles, err := profileTables(filename, selected, *workers, *top)
+	if err != nil {
+		fatal("error profiling tables", "error", err)
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(profiles)
+	case "markdown":
+		err = printProfiles(os.Stdout, profiles)
+	}
+	if err != nil {
+		fatal("error writing output", "error", err)
+	}
+}
+
+// profileTables profiles the selected tables in one pass over the file.
+func profileTables(filename string, tables []*parser.TableInfo, workers, top int) ([]*profile.TableProfile, error) {
+	file, err := parser.OpenInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	selected := make(map[[2]string]bool)
+	for _, t := range tables {
+		selected[[2]string{t.Database, t.Name}] = true
+	}
+	profilers := make(map[string]*profile.Profiler)
+	schemas := make(map[string]*models.Schema)
+	profiler := func(table string) *profile.Profiler {
+		p, ok := profilers[table]
+		if !ok {
+			p = profile.New(table, schemas[table], top)
+			profilers[table] = p
+		}
+		return p
+	}
+
+	cfg := parser.Config{
+		File:    filename,
+		Workers: workers,
+		Tables:  func(db, name string) bool { return selected[[2]string{db, name}] },
+	}
+	_, err = parser.Stream(context.Background(), file, cfg, parser.HandlerFuncs{
+		OnSchema: func(schema *models.Schema) error {
+			schemas[schema.TableName] = schema
+			return nil
+		},
+		OnRows: func(table string, rows []models.Row) error {
+			profiler(table).Add(rows)
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Tables are reported by name, as ScanTables sorts them, including ones
+	// whose rows were all rejected
+	profiles := make([]*profile.TableProfile, 0, len(tables))
+	for _, t := range tables {
+		profiles = append(profiles, profiler(t.Name).Profile())
+	}
+	return profiles, nil
+}
+
+func printProfiles(out io.Writer, profiles []*profile.TableProfile) error {
+	w := bufio.NewWriter(out)
+	for i, p := range profiles {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "## %s\n\n%d rows\n\n", p.Table, p.Rows)
+		if len(p.Columns) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "| Column | Declared | Inferred | Nulls | Distinct | Min | Max | Length (min/mean/max) | Top values |\n")
+		fmt.Fprintf(w, "|---|---|---|---:|---:|---|---|---|---|\n")
+		for _, c := range p.Columns {
+			min, max := "-", "-"
+			if c.InferredType != profile.TypeNull {
+				min, max = markdownCell(c.Min), markdownCell(c.Max)
+			}
+			var topValues []string
+			for _, v := range c.TopValues {
+				topValues = append(topValues, fmt.Sprintf("%s (%d)", markdownCell(v.Value), v.Count))
+			}
+			fmt.Fprintf(w, "| %s | %s | %s | %d (%.1f%%) | %d | %s | %s | %d / %.1f / %d | %s |\n",
+				markdownCell(c.Name), orNone(c.DeclaredType), c.InferredType,
+				c.Nulls, c.NullFraction*100, c.Distinct,
+				min, max,
+				c.Length.Min, c.Length.Mean, c.Length.Max,
+				orNone(strings.Join(topValues, ", ")))
+		}
+	}
+	return w.Flush()
+}
+
+// markdownCell makes s fit in a Markdown table cell, shortening long values.
+func markdownCell(s string) string {
+	const maxLen = 40
+	if r := []rune(s); len(r) > maxLen {
+		s = string(r[:maxLen-1]) + "…"
+	}
+	s = strings.NewReplacer("|", `\|`, "\n", " ", "\r", "").Replace(s)
+	if s == "" {
+		return "`\"\"`"
+	}
+	return s
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+	_ "sqlparser/pkg/sqldump"
+	"sqlparser/pkg/writer"
+)
+
+func runQuery(args []string) {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	format := fs.String("format", "table", "Output format (table, txt, csv, json, jsonl)")
+	output := fs.String("output", "", "Output file (default: stdout)")
+	workers := fs.Int("workers", getWorkerCount(), "Number of worker threads")
+	onError := fs.String("on-error", string(parser.ErrorPolicySkip), "What to do with statements that fail to parse (fail, skip)")
+	logs := addLogFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sqlparser query [-format=table|txt|csv|json|jsonl] [-output=file] <sqlfile> \"SELECT ...\"\n")
+		fmt.Fprintf(os.Stderr, "Runs a SELECT query against the tables of a dump without loading it into a database.\n")
+		fmt.Fprintf(os.Stderr, "Queries support WHERE, JOIN and LEFT JOIN, GROUP BY with COUNT, SUM, AVG, MIN and MAX, HAVING,\n")
+		fmt.Fprintf(os.Stderr, "ORDER BY, DISTINCT, LIMIT and OFFSET.\n")
+		fs.PrintDefaults()
+	}
+	positional := parseInterspersed(fs, args)
+	logs.setup()
+	if len(positional) != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	filename, q := positional[0], positional[1]
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fatal("error creating output file", "error", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	var printer resultPrinter
+	if *format == "table" {
+		printer = &tablePrinter{w: tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)}
+	} else {
+		w, err := writer.CreateWriter(models.OutputFormat(*format), out)
+		if err != nil {
+			fatal("invalid -format", "format", *format, "error", err)
+		}
+		printer = &resultWriter{writer: w}
+	}
+
+	params := url.Values{}
+	params.Set("workers", strconv.Itoa(*workers))
+	params.Set("on_error", *onError)
+	db, err := sql.Open("sqldump", filename+"?"+params.Encode())
+	if err != nil {
+		fatal("error opening dump", "error", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(q)
+	if err != nil {
+		fatal("error running query", "error", err)
+	}
+	defer rows.Close()
+
+	if err := printResults(rows, printer); err != nil {
+		fatal("error running query", "error", err)
+	}
+}
+
+// resultPrinter writes the rows of a query result.
+type resultPrinter interface {
+	Start(columns []string) error
+	Row(n int, values []interface{}) error
+	Close() error
+}
+
+func printResults(rows *sql.Rows, printer resultPrinter) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	if err := printer.Start(columns); err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		n++
+		if err := printer.Row(n, values); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return printer.Close()
+}
+
+// tablePrinter prints the result as a table with aligned columns.
+type tablePrinter struct {
+	w *tabwriter.Writer
+}
+
+func (p *tablePrinter) Start(columns []string) error {
+	_, err := fmt.Fprintln(p.w, strings.Join(columns, "\t"))
+	return err
+}
+
+func (p *tablePrinter) Row(n int, values []interface{}) error {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		switch value := value.(type) {
+		case nil:
+			cells[i] = "NULL"
+		case float64:
+			cells[i] = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			cells[i] = cellEscaper.Replace(fmt.Sprint(value))
+		}
+	}
+	_, err := fmt.Fprintln(p.w, strings.Join(cells, "\t"))
+	return err
+}
+
+func (p *tablePrinter) Close() error {
+	return p.w.Flush()
+}
+
+var cellEscaper = strings.NewReplacer("\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// resultWriter writes the result with one of the export writers, as the rows
+// of a table called "query".
+type resultWriter struct {
+	writer  writer.Writer
+	columns []string
+}
+
+func (p *resultWriter) Start(columns []string) error {
+	p.columns = columns
+	if sw, ok := p.writer.(writer.SchemaWriter); ok {
+		schema := &models.Schema{TableName: "query"}
+		for _, col := range columns {
+			schema.Columns = append(schema.Columns, models.Column{Name: col, Nullable: true})
+		}
+		if err := sw.WriteSchema(schema); err != nil {
+			return err
+		}
+	}
+	return p.writer.WriteTableStart("query")
+}
+
+func (p *resultWriter) Row(n int, values []interface{}) error {
+	data := make(map[string]interface{}, len(values))
+	for i, value := range values {
+		data[p.columns[i]] = value
+	}
+	return p.writer.WriteRows([]models.Row{{TableName: "query", RowNumber: n, Data: data}})
+}
+
+func (p *resultWriter) Close() error {
+	if err := p.writer.WriteTableEnd(); err != nil {
+		return err
+	}
+	return p.writer.Close()
+}
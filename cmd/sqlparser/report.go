@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"sqlparser/pkg/parser"
+)
+
+// report collects the results of an export for -report. It is nil for the
+// subcommands.
+var report *runReport
+
+// runReport is the machine-readable summary of an export written by -report.
+type runReport struct {
+	Status          string         `json:"status"` // completed, rejected, interrupted or failed
+	ExitCode        int            `json:"exit_code"`
+	Error           string         `json:"error,omitempty"`
+	StartedAt       time.Time      `json:"started_at"`
+	FinishedAt      time.Time      `json:"finished_at"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	Inputs          []*inputReport `json:"inputs"`
+	Tables          []*tableReport `json:"tables"`
+	Outputs         []outputReport `json:"outputs"`
+	Totals          totalsReport   `json:"totals"`
+
+	path    string
+	outputs []string // output files in the order they were first written
+}
+
+type inputReport struct {
+	File               string `json:"file"`
+	Size               int64  `json:"size"` // on disk, compressed or not
+	RejectedStatements int    `json:"rejected_statements"`
+	RejectedRows       int    `json:"rejected_rows"`
+	RejectsFile        string `json:"rejects_file,omitempty"`
+}
+
+type tableReport struct {
+	File               string  `json:"file"`
+	Table              string  `json:"table"`
+	Status             string  `json:"status"` // completed, interrupted or failed
+	Rows               int     `json:"rows"`
+	Statements         int     `json:"statements"`
+	Bytes              int64   `json:"bytes"` // size of the INSERT statements read
+	RejectedStatements int     `json:"rejected_statements"`
+	RejectedRows       int     `json:"rejected_rows"`
+	DurationSeconds    float64 `json:"duration_seconds"`
+	Output             string  `json:"output,omitempty"` // "" for stdout
+
+	started time.Time
+}
+
+type outputReport struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type totalsReport struct {
+	Tables             int   `json:"tables"`
+	Rows               int   `json:"rows"`
+	Statements         int   `json:"statements"`
+	Bytes              int64 `json:"bytes"`
+	RejectedStatements int   `json:"rejected_statements"`
+	RejectedRows       int   `json:"rejected_rows"`
+}
+
+func newRunReport(path string) *runReport {
+	return &runReport{StartedAt: time.Now(), path: path, Inputs: []*inputReport{}, Tables: []*tableReport{}}
+}
+
+func (r *runReport) addInput(filename string) *inputReport {
+	input := &inputReport{File: filename}
+	if info, err := os.Stat(filename); err == nil {
+		input.Size = info.Size()
+	}
+	r.Inputs = append(r.Inputs, input)
+	return input
+}
+
+// startTable records that a table of filename is being exported. It counts
+// as failed until it ends.
+func (r *runReport) startTable(filename, table string) *tableReport {
+	t := &tableReport{File: filename, Table: table, Status: "failed", started: time.Now()}
+	r.Tables = append(r.Tables, t)
+	return t
+}
+
+// end records the outcome of exporting a table. rejectedStatements and
+// rejectedRows are the statements and rows of the table that were rejected.
+func (t *tableReport) end(status string, stats *parser.Stats, rejectedStatements, rejectedRows int) {
+	t.Status = status
+	t.DurationSeconds = time.Since(t.started).Seconds()
+	if stats != nil {
+		t.Rows, t.Statements, t.Bytes = stats.Rows, stats.Statements, stats.Bytes
+	}
+	t.RejectedStatements = rejectedStatements
+	t.RejectedRows = rejectedRows
+}
+
+// addOutput records an output file, once however many tables it holds.
+func (r *runReport) addOutput(path string) {
+	for _, p := range r.outputs {
+		if p == path {
+			return
+		}
+	}
+	r.outputs = append(r.outputs, path)
+}
+
+// write finishes the report for a run ending with exit status code and
+// writes it to its file. The output files have to be closed by then.
+func (r *runReport) write(code int) error {
+	r.ExitCode = code
+	switch code {
+	case 0:
+		r.Status = "completed"
+	case exitRejected:
+		r.Status = "rejected"
+	case exitInterrupted:
+		r.Status = "interrupted"
+	default:
+		r.Status = "failed"
+	}
+	r.FinishedAt = time.Now()
+	r.DurationSeconds = r.FinishedAt.Sub(r.StartedAt).Seconds()
+
+	r.Totals = totalsReport{Tables: len(r.Tables)}
+	for _, t := range r.Tables {
+		r.Totals.Rows += t.Rows
+		r.Totals.Statements += t.Statements
+		r.Totals.Bytes += t.Bytes
+	}
+	for _, input := range r.Inputs {
+		r.Totals.RejectedStatements += input.RejectedStatements
+		r.Totals.RejectedRows += input.RejectedRows
+	}
+
+	r.Outputs = make([]outputReport, 0, len(r.outputs))
+	for _, path := range r.outputs {
+		size, sum, err := checksumFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading output file: %v", err)
+		}
+		r.Outputs = append(r.Outputs, outputReport{Path: path, Size: size, SHA256: sum})
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(data, '\n'), 0644)
+}
+
+// checksumFile returns the size and SHA-256 checksum of a file.
+func checksumFile(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// exit writes the report, if one was asked for, and exits with status code.
+func exit(code int) {
+	if report != nil && report.path != "" {
+		if err := report.write(code); err != nil {
+			slog.Error("error writing report", "error", err)
+			if code == 0 {
+				code = 1
+			}
+		}
+	}
+	os.Exit(code)
+}
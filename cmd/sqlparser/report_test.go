@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sqlparser/pkg/parser"
+)
+
+func TestRunReport(t *testing.T) {
+	tests := []struct {
+		code   int
+		status string
+	}{
+		{0, "completed"},
+		{exitRejected, "rejected"},
+		{exitInterrupted, "interrupted"},
+		{1, "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, "dump.sql")
+			output := filepath.Join(dir, "out.csv")
+			if err := os.WriteFile(input, []byte("INSERT INTO `a` VALUES (1);\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(output, []byte("hello"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			r := newRunReport(filepath.Join(dir, "report.json"))
+			in := r.addInput(input)
+			in.RejectedStatements, in.RejectedRows = 1, 2
+			a := r.startTable(input, "a")
+			a.end("completed", &parser.Stats{Rows: 10, Statements: 2, Bytes: 300}, 1, 0)
+			b := r.startTable(input, "b")
+			b.end("interrupted", &parser.Stats{Rows: 5, Statements: 1, Bytes: 100}, 0, 2)
+			r.startTable(input, "c")
+			// Tables written to the same file count once
+			r.addOutput(output)
+			r.addOutput(output)
+			if err := r.write(tt.code); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(r.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got["status"] != tt.status || got["exit_code"] != float64(tt.code) {
+				t.Errorf("status %v, exit code %v", got["status"], got["exit_code"])
+			}
+			want := map[string]interface{}{
+				"tables": 3.0, "rows": 15.0, "statements": 3.0, "bytes": 400.0,
+				"rejected_statements": 1.0, "rejected_rows": 2.0,
+			}
+			if !reflect.DeepEqual(got["totals"], want) {
+				t.Errorf("totals = %v, want %v", got["totals"], want)
+			}
+			// sha256 of "hello"
+			outputs := []interface{}{map[string]interface{}{
+				"path": output, "size": 5.0, "sha256": "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+			}}
+			if !reflect.DeepEqual(got["outputs"], outputs) {
+				t.Errorf("outputs = %v, want %v", got["outputs"], outputs)
+			}
+			var statuses []string
+			for _, table := range got["tables"].([]interface{}) {
+				table := table.(map[string]interface{})
+				statuses = append(statuses, fmt.Sprintf("%s:%s", table["table"], table["status"]))
+			}
+			if want := []string{"a:completed", "b:interrupted", "c:failed"}; !reflect.DeepEqual(statuses, want) {
+				t.Errorf("tables %v, want %v", statuses, want)
+			}
+			inputs := got["inputs"].([]interface{})
+			if size := inputs[0].(map[string]interface{})["size"]; size != 28.0 {
+				t.Errorf("input size = %v, want 28", size)
+			}
+		})
+	}
+}
+
+func TestRunReportMissingOutput(t *testing.T) {
+	r := newRunReport(filepath.Join(t.TempDir(), "report.json"))
+	r.addOutput(filepath.Join(t.TempDir(), "missing.csv"))
+	if err := r.write(0); err == nil {
+		t.Error("no error for a missing output file")
+	}
+}
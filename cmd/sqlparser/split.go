@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"sqlparser/pkg/parser"
+	"sqlparser/pkg/progress"
+)
+
+func runSplit(args []string) {
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	out := fs.String("out", "", "Directory for the table files (default: named after the input file)")
+	includeTables := fs.String("tables", "", "Comma-separated tables to write, in the same syntax as for exports (default: all)")
+	excludeTables := fs.String("exclude-tables", "", "Comma-separated tables to leave out")
+	logs := addLogFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sqlparser split <sqlfile> [-out=dir] [-tables=...] [-exclude-tables=...]\n")
+		fmt.Fprintf(os.Stderr, "Writes the statements of every table to its own restorable .sql file.\n")
+		fs.PrintDefaults()
+	}
+	positional := parseInterspersed(fs, args)
+	logs.setup()
+	if len(positional) != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	filename := positional[0]
+
+	filter, err := parser.ParseTableFilter(*includeTables, *excludeTables)
+	if err != nil {
+		fatal("invalid table selection", "error", err)
+	}
+	dir := *out
+	if dir == "" {
+		dir = inputBase(filename)
+	}
+
+	files, err := parser.Split(filename, dir, filter)
+	if err != nil {
+		fatal("error splitting file", "error", err)
+	}
+	if len(files) == 0 {
+		fatal("no tables written", "file", filename)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "TABLE\tDATABASE\tSTATEMENTS\tSIZE\tFILE\n")
+	for _, f := range files {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", f.Table, orNone(f.Database), f.Statements, progress.FormatBytes(f.Bytes), f.Path)
+	}
+	if err := w.Flush(); err != nil {
+		fatal("error writing output", "error", err)
+	}
+}
+
+// parseInterspersed parses the flags in args, which may come before or after
+// the positional arguments, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...)
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"sqlparser/pkg/parser"
+)
+
+// exitInvalid is the exit status of validate for a dump with problems.
+const exitInvalid = 2
+
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	format := fs.String("format", "text", "Output format (text, json)")
+	checkKeys := fs.Bool("keys", true, "Check for duplicate primary keys (keeps a hash of every key in memory)")
+	maxProblems := fs.Int("max-problems", 100, "Number of problems of each kind to list; all are counted (0: no limit)")
+	workers := fs.Int("workers", getWorkerCount(), "Number of worker threads")
+	logs := addLogFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sqlparser validate [-format=text|json] [-keys=false] <sqlfile>\n")
+		fmt.Fprintf(os.Stderr, "Checks a dump for errors without producing output. Exits with status %d if problems were found.\n", exitInvalid)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	logs.setup()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *format != "text" && *format != "json" {
+		fatal("invalid -format", "format", *format)
+	}
+
+	opts := parser.ValidateOptions{Workers: *workers, CheckKeys: *checkKeys, MaxProblems: *maxProblems}
+	report, err := parser.Validate(context.Background(), fs.Arg(0), opts)
+	if err != nil {
+		fatal("error validating file", "error", err)
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = printValidationReport(os.Stdout, report)
+	}
+	if err != nil {
+		fatal("error writing output", "error", err)
+	}
+	if !report.Valid {
+		os.Exit(exitInvalid)
+	}
+}
+
+func printValidationReport(out io.Writer, report *parser.ValidationReport) error {
+	for _, p := range report.Problems {
+		fmt.Fprintf(out, "%s:", report.File)
+		if p.Line > 0 {
+			fmt.Fprintf(out, "%d:", p.Line)
+		}
+		fmt.Fprintf(out, " %s: ", p.Kind)
+		if p.Table != "" {
+			fmt.Fprintf(out, "table %s: ", p.Table)
+		}
+		if p.Row > 0 {
+			fmt.Fprintf(out, "row %d: ", p.Row)
+		}
+		fmt.Fprintln(out, p.Message)
+	}
+
+	status := "OK"
+	if !report.Valid {
+		status = "INVALID"
+	}
+	_, err := fmt.Fprintf(out, "%s: %s, %d INSERT statements, %d rows", report.File, status, report.Statements, report.Rows)
+	if err != nil {
+		return err
+	}
+	kinds := make([]string, 0, len(report.Counts))
+	for kind := range report.Counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(out, ", %d %s", report.Counts[kind], kind)
+	}
+	_, err = fmt.Fprintln(out)
+	return err
+}
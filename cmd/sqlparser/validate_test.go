@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"sqlparser/pkg/parser"
+)
+
+func TestPrintValidationReport(t *testing.T) {
+	tests := []struct {
+		name   string
+		report parser.ValidationReport
+		want   string
+	}{
+		{
+			name:   "valid",
+			report: parser.ValidationReport{File: "d.sql", Statements: 3, Rows: 40, Valid: true, Counts: map[string]int{}},
+			want:   "d.sql: OK, 3 INSERT statements, 40 rows\n",
+		},
+		{
+			name: "problems",
+			report: parser.ValidationReport{
+				File: "d.sql", Statements: 3, Rows: 40,
+				Counts: map[string]int{parser.ProblemTruncated: 1, parser.ProblemDuplicateKey: 5, parser.ProblemColumnCount: 1},
+				Problems: []parser.Problem{
+					{Kind: parser.ProblemColumnCount, Message: "3 values for 2 columns", Table: "users", Line: 12, Statement: 2, Row: 4},
+					{Kind: parser.ProblemDuplicateKey, Message: "duplicate primary key (id=1), first seen in row 1", Table: "users", Row: 7},
+					{Kind: parser.ProblemTruncated, Message: "the dump ends in the middle of a statement"},
+				},
+			},
+			want: "d.sql:12: column_count: table users: row 4: 3 values for 2 columns\n" +
+				"d.sql: duplicate_key: table users: row 7: duplicate primary key (id=1), first seen in row 1\n" +
+				"d.sql: truncated: the dump ends in the middle of a statement\n" +
+				"d.sql: INVALID, 3 INSERT statements, 40 rows, 1 column_count, 5 duplicate_key, 1 truncated\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := printValidationReport(&out, &tt.report); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
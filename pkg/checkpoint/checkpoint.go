@@ -0,0 +1,128 @@
+// Package checkpoint records how far an interrupted export got, so that a
+// later run can pick up where it stopped instead of starting over.
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Checkpoint is the state of an interrupted export of a dump file.
+type Checkpoint struct {
+	File    string    `json:"file"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+
+	Format    string   `json:"format"`           // output format
+	Tables    []string `json:"tables"`           // tables selected for export, in order
+	Completed []string `json:"completed_tables"` // tables that were fully exported
+
+	// The table that was interrupted: its INSERT statements before Line were
+	// exported and held Rows rows
+	Table string `json:"table,omitempty"`
+	Line  int    `json:"line,omitempty"`
+	Rows  int    `json:"rows,omitempty"`
+
+	// The file the interrupted table was written to and its size when the
+	// export stopped
+	Output     string `json:"output,omitempty"`
+	OutputSize int64  `json:"output_size,omitempty"`
+}
+
+// New returns an empty checkpoint for exporting tables from filename in
+// format.
+func New(filename, format string, tables []string) (*Checkpoint, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &Checkpoint{File: filename, Size: info.Size(), ModTime: info.ModTime(), Format: format, Tables: tables, Completed: []string{}}, nil
+}
+
+// Load reads a checkpoint and checks that the dump it belongs to hasn't
+// changed since.
+func Load(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %v", path, err)
+	}
+
+	info, err := os.Stat(c.File)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() != c.Size || !info.ModTime().Equal(c.ModTime) {
+		return nil, fmt.Errorf("%s has changed since checkpoint %s was written", c.File, path)
+	}
+	return &c, nil
+}
+
+// IsCompleted reports whether table was fully exported.
+func (c *Checkpoint) IsCompleted(table string) bool {
+	for _, name := range c.Completed {
+		if name == table {
+			return true
+		}
+	}
+	return false
+}
+
+// Complete records that table was fully exported.
+func (c *Checkpoint) Complete(table string) {
+	c.Completed = append(c.Completed, table)
+	if c.Table == table {
+		c.Table, c.Line, c.Rows = "", 0, 0
+		c.Output, c.OutputSize = "", 0
+	}
+}
+
+// Interrupt records that table was exported up to, but not including, the
+// statement starting on line, and that rows rows were exported.
+func (c *Checkpoint) Interrupt(table string, line, rows int) {
+	c.Table, c.Line, c.Rows = table, line, rows
+}
+
+// Written records the file the interrupted table was written to, which has
+// to be closed, so that a resumed export can continue it. Output written to
+// stdout isn't recorded.
+func (c *Checkpoint) Written(output string) error {
+	if output == "" {
+		return nil
+	}
+	info, err := os.Stat(output)
+	if err != nil {
+		return err
+	}
+	c.Output, c.OutputSize = output, info.Size()
+	return nil
+}
+
+// Save writes the checkpoint to path. The file is replaced atomically so that
+// a crash while saving doesn't leave a truncated checkpoint behind.
+func (c *Checkpoint) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,118 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestSaveLoad(t *testing.T) {
+	dump := writeFile(t, "dump.sql", "INSERT INTO `a` VALUES (1);\n")
+	output := writeFile(t, "b.csv", "id\n1\n2\n")
+	path := filepath.Join(t.TempDir(), "dump.checkpoint.json")
+
+	c, err := New(dump, "csv", []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Complete("a")
+	c.Interrupt("b", 42, 2)
+	if err := c.Written(output); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if matches, _ := filepath.Glob(path + ".tmp*"); len(matches) > 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Checkpoint{
+		File: dump, Size: c.Size, ModTime: got.ModTime, Format: "csv",
+		Tables: []string{"a", "b", "c"}, Completed: []string{"a"},
+		Table: "b", Line: 42, Rows: 2, Output: output, OutputSize: 7,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if !got.ModTime.Equal(c.ModTime) {
+		t.Errorf("mod time = %v, want %v", got.ModTime, c.ModTime)
+	}
+	if !got.IsCompleted("a") || got.IsCompleted("b") {
+		t.Errorf("completed tables are %v", got.Completed)
+	}
+
+	// Completing the interrupted table clears where it stopped
+	got.Complete("b")
+	if got.Table != "" || got.Line != 0 || got.Rows != 0 || got.Output != "" || got.OutputSize != 0 {
+		t.Errorf("interrupted table left after completing it: %+v", got)
+	}
+}
+
+func TestWrittenToStdout(t *testing.T) {
+	c := &Checkpoint{}
+	if err := c.Written(""); err != nil || c.Output != "" {
+		t.Errorf("Written(\"\") = %v, output %q", err, c.Output)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(dump string)
+		json   string // of the checkpoint instead of a saved one
+		err    string
+	}{
+		{
+			name:   "dump grew",
+			change: func(dump string) { os.WriteFile(dump, []byte("INSERT INTO `a` VALUES (1),(2);\n"), 0644) },
+			err:    "has changed since checkpoint",
+		},
+		{
+			name:   "dump touched",
+			change: func(dump string) { os.Chtimes(dump, time.Now(), time.Now().Add(time.Hour)) },
+			err:    "has changed since checkpoint",
+		},
+		{name: "dump removed", change: func(dump string) { os.Remove(dump) }, err: "no such file"},
+		{name: "invalid", json: "{", err: "invalid checkpoint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dump := writeFile(t, "dump.sql", "INSERT INTO `a` VALUES (1);\n")
+			path := filepath.Join(t.TempDir(), "checkpoint.json")
+			if tt.json != "" {
+				if err := os.WriteFile(path, []byte(tt.json), 0644); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				c, err := New(dump, "csv", []string{"a"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := c.Save(path); err != nil {
+					t.Fatal(err)
+				}
+				tt.change(dump)
+			}
+			if _, err := Load(path); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+// Package diff compares the rows of two dumps, matching them by primary key,
+// and reports the rows that were inserted, updated or deleted.
+package diff
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+)
+
+// Change operations.
+const (
+	OpInsert = "insert"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// Change is a row that differs between the dumps. Before is the row in the
+// old dump and After the row in the new one; each is nil if the row doesn't
+// exist there. Changed lists the columns an update changed.
+type Change struct {
+	Op      string                 `json:"op"`
+	Table   string                 `json:"table"`
+	Key     map[string]interface{} `json:"key"`
+	Changed []string               `json:"changed,omitempty"`
+	Before  map[string]interface{} `json:"before,omitempty"`
+	After   map[string]interface{} `json:"after,omitempty"`
+
+	// Schema is the table's schema from the dump the row comes from, or nil
+	Schema *models.Schema `json:"-"`
+}
+
+// Options controls Compare.
+type Options struct {
+	// Keys overrides the key columns of tables, by table name. Other tables
+	// are matched by the primary key of their CREATE TABLE statement, or else
+	// by the key columns of the table "". Tables that lack a key column are
+	// skipped.
+	Keys    map[string][]string
+	Tables  func(database, name string) bool // selects the tables to compare; nil selects all
+	Workers int
+	Errors  *parser.ErrorHandler
+}
+
+// TableSummary counts the changes of a table.
+type TableSummary struct {
+	Table     string
+	Inserted  int
+	Updated   int
+	Deleted   int
+	Unchanged int
+	Skipped   bool // the table has no key or lacks a key column, so it wasn't compared
+}
+
+// Compare compares the tables of the dumps oldFile and newFile and passes
+// each change to emit: inserts and updates in the order of the new dump,
+// followed by the deletes of each table in the order of the old dump.
+//
+// The rows of the old dump are held in memory while the new dump is read.
+func Compare(ctx context.Context, oldFile, newFile string, opts Options, emit func(Change) error) ([]TableSummary, error) {
+	c := &comparison{opts: opts, tables: make(map[string]*table), emit: emit}
+	if err := c.load(ctx, oldFile); err != nil {
+		return nil, err
+	}
+	if err := c.compare(ctx, newFile); err != nil {
+		return nil, err
+	}
+	if err := c.deletes(); err != nil {
+		return nil, err
+	}
+
+	summaries := make([]TableSummary, 0, len(c.order))
+	for _, name := range c.order {
+		summaries = append(summaries, c.tables[name].summary)
+	}
+	return summaries, nil
+}
+
+type comparison struct {
+	opts   Options
+	tables map[string]*table
+	order  []string // tables in order of appearance
+	emit   func(Change) error
+}
+
+type table struct {
+	key       []string // nil if the table has no key
+	oldSchema *models.Schema
+	newSchema *models.Schema
+	rows      map[string]oldRow // rows of the old dump by key
+	summary   TableSummary
+}
+
+type oldRow struct {
+	number int
+	data   map[string]interface{}
+}
+
+// table returns the table called name. The key of a table is chosen when it
+// is first seen, so that rows of both dumps are matched by the same columns.
+func (c *comparison) table(name string, schema *models.Schema) *table {
+	t, ok := c.tables[name]
+	if !ok {
+		t = &table{
+			key:     c.keyColumns(name, schema),
+			rows:    make(map[string]oldRow),
+			summary: TableSummary{Table: name},
+		}
+		c.tables[name] = t
+		c.order = append(c.order, name)
+	}
+	return t
+}
+
+func (c *comparison) keyColumns(name string, schema *models.Schema) []string {
+	if key, ok := c.opts.Keys[name]; ok {
+		return key
+	}
+	if schema != nil && len(schema.PrimaryKey) > 0 {
+		return schema.PrimaryKey
+	}
+	if key, ok := c.opts.Keys[""]; ok {
+		return key
+	}
+	return nil
+}
+
+// skip stops comparing a table whose rows lack a key column.
+func (c *comparison) skip(name string, t *table, err error) {
+	slog.Warn("table wasn't compared", "table", name, "error", err)
+	t.key, t.rows = nil, nil
+	t.summary.Skipped = true
+}
+
+func (c *comparison) stream(ctx context.Context, filename string, h parser.Handler) error {
+	file, err := parser.OpenInput(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	cfg := parser.Config{
+		File:    filename,
+		Workers: c.opts.Workers,
+		Tables:  c.opts.Tables,
+		Errors:  c.opts.Errors,
+	}
+	_, err = parser.Stream(ctx, file, cfg, h)
+	return err
+}
+
+// load reads the rows of the old dump.
+func (c *comparison) load(ctx context.Context, filename string) error {
+	schemas := make(map[string]*models.Schema)
+	duplicates := make(map[string]int)
+	err := c.stream(ctx, filename, parser.HandlerFuncs{
+		OnSchema: func(schema *models.Schema) error {
+			schemas[schema.TableName] = schema
+			return nil
+		},
+		OnRows: func(name string, rows []models.Row) error {
+			t := c.table(name, schemas[name])
+			t.oldSchema = schemas[name]
+			if t.key == nil {
+				return nil
+			}
+			for _, row := range rows {
+				key, err := rowKey(t.key, row)
+				if err != nil {
+					c.skip(name, t, err)
+					return nil
+				}
+				if _, ok := t.rows[key]; ok {
+					duplicates[name]++
+				}
+				t.rows[key] = oldRow{number: row.RowNumber, data: row.Data}
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", filename, err)
+	}
+	for name, n := range duplicates {
+		slog.Warn("duplicate keys in old dump, only the last row of each is compared", "table", name, "duplicates", n)
+	}
+	return nil
+}
+
+// compare reads the new dump and emits its inserts and updates.
+func (c *comparison) compare(ctx context.Context, filename string) error {
+	schemas := make(map[string]*models.Schema)
+	seen := make(map[string]map[string]bool)
+	err := c.stream(ctx, filename, parser.HandlerFuncs{
+		OnSchema: func(schema *models.Schema) error {
+			schemas[schema.TableName] = schema
+			return nil
+		},
+		OnRows: func(name string, rows []models.Row) error {
+			t := c.table(name, schemas[name])
+			t.newSchema = schemas[name]
+			if t.key == nil {
+				return nil
+			}
+			if seen[name] == nil {
+				seen[name] = make(map[string]bool)
+			}
+			for _, row := range rows {
+				key, err := rowKey(t.key, row)
+				if err != nil {
+					c.skip(name, t, err)
+					return nil
+				}
+				if seen[name][key] {
+					continue // duplicate key in the new dump
+				}
+				seen[name][key] = true
+				if err := c.compareRow(name, t, key, row); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", filename, err)
+	}
+	return nil
+}
+
+func (c *comparison) compareRow(name string, t *table, key string, row models.Row) error {
+	old, ok := t.rows[key]
+	if !ok {
+		t.summary.Inserted++
+		return c.emit(Change{
+			Op:     OpInsert,
+			Table:  name,
+			Key:    keyValues(t.key, row.Data),
+			After:  row.Data,
+			Schema: t.newSchema,
+		})
+	}
+	delete(t.rows, key)
+
+	changed := changedColumns(old.data, row.Data)
+	if len(changed) == 0 {
+		t.summary.Unchanged++
+		return nil
+	}
+	t.summary.Updated++
+	return c.emit(Change{
+		Op:      OpUpdate,
+		Table:   name,
+		Key:     keyValues(t.key, row.Data),
+		Changed: changed,
+		Before:  old.data,
+		After:   row.Data,
+		Schema:  t.newSchema,
+	})
+}
+
+// deletes emits the rows of the old dump that weren't in the new one.
+func (c *comparison) deletes() error {
+	for _, name := range c.order {
+		t := c.tables[name]
+		if t.key == nil {
+			if !t.summary.Skipped {
+				t.summary.Skipped = true
+				slog.Warn("table has no primary key and wasn't compared", "table", name)
+			}
+			continue
+		}
+		rows := make([]oldRow, 0, len(t.rows))
+		for _, row := range t.rows {
+			rows = append(rows, row)
+		}
+		sort.Slice(rows, func(i, j int) bool { return rows[i].number < rows[j].number })
+		for _, row := range rows {
+			t.summary.Deleted++
+			err := c.emit(Change{
+				Op:     OpDelete,
+				Table:  name,
+				Key:    keyValues(t.key, row.data),
+				Before: row.data,
+				Schema: t.oldSchema,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		t.rows = nil
+	}
+	return nil
+}
+
+// rowKey encodes the values of the key columns of row so that distinct keys
+// give distinct strings.
+func rowKey(columns []string, row models.Row) (string, error) {
+	var b strings.Builder
+	for _, col := range columns {
+		value, ok := row.Data[col]
+		if !ok {
+			return "", fmt.Errorf("table %s has no key column %q", row.TableName, col)
+		}
+		if value == nil {
+			b.WriteString("N;")
+			continue
+		}
+		s := fmt.Sprint(value)
+		b.WriteString(strconv.Itoa(len(s)))
+		b.WriteByte(':')
+		b.WriteString(s)
+	}
+	return b.String(), nil
+}
+
+func keyValues(columns []string, data map[string]interface{}) map[string]interface{} {
+	key := make(map[string]interface{}, len(columns))
+	for _, col := range columns {
+		key[col] = data[col]
+	}
+	return key
+}
+
+// changedColumns returns the columns whose values differ, in name order.
+// Columns that exist in only one of the rows count as changed.
+func changedColumns(before, after map[string]interface{}) []string {
+	var changed []string
+	for col, a := range after {
+		b, ok := before[col]
+		if !ok || !equal(a, b) {
+			changed = append(changed, col)
+		}
+	}
+	for col := range before {
+		if _, ok := after[col]; !ok {
+			changed = append(changed, col)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
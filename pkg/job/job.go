@@ -0,0 +1,203 @@
+// Package job loads job files, which describe an export declaratively: the
+// dumps to read, the tables to export and how to transform them, the output,
+// the error policy and performance settings.
+//
+//	input: dump.sql.gz
+//	tables:
+//	  include: [users, orders]
+//	transforms:
+//	  users:
+//	    drop: [password_hash]
+//	    where: active = 1
+//	output:
+//	  format: jsonl
+//	  dir: export
+//	errors:
+//	  policy: quarantine
+//	performance:
+//	  workers: 4
+package job
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+	"sqlparser/pkg/writer"
+)
+
+// Job is the contents of a job file. Fields left out of the file are zero.
+type Job struct {
+	Input       string               `yaml:"input"`
+	Inputs      []string             `yaml:"inputs"`
+	Dialect     string               `yaml:"dialect"` // auto, mysql, mariadb, postgresql or sqlite
+	Tables      Tables               `yaml:"tables"`
+	Transforms  map[string]Transform `yaml:"transforms"` // by table name
+	Output      Output               `yaml:"output"`
+	Errors      Errors               `yaml:"errors"`
+	Performance Performance          `yaml:"performance"`
+}
+
+// Tables selects the tables to export, like the -tables, -exclude-tables and
+// -all flags.
+type Tables struct {
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	All     bool     `yaml:"all"`
+}
+
+// Output is where and how tables are written. Path is a single output file;
+// Dir is a directory that gets a file per table. Report is a file that gets
+// a JSON summary of the run, like the -report flag. AvroSchema also writes
+// the schema of avro output to .avsc files, like the -avro-schema flag.
+type Output struct {
+	Format      string `yaml:"format"`
+	Compression string `yaml:"compression"` // for parquet, arrow and avro
+	Path        string `yaml:"path"`
+	Dir         string `yaml:"dir"`
+	Report      string `yaml:"report"`
+	AvroSchema  bool   `yaml:"avro_schema"`
+}
+
+// Errors is the error policy, like the -on-error, -rejects and -strict flags.
+type Errors struct {
+	Policy  string `yaml:"policy"`
+	Rejects string `yaml:"rejects"`
+	Strict  bool   `yaml:"strict"`
+}
+
+// Performance holds the settings also taken from the WORKER_COUNT and
+// BATCH_SIZE environment variables.
+type Performance struct {
+	Workers   int `yaml:"workers"`
+	BatchSize int `yaml:"batch_size"`
+}
+
+// Load reads and validates a job file. Relative paths in it are taken
+// relative to the directory of the file.
+func Load(path string) (*Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &Job{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(j); err != nil {
+		return nil, fmt.Errorf("error reading job file %s: %v", path, err)
+	}
+	if err := j.validate(); err != nil {
+		return nil, fmt.Errorf("invalid job file %s:%v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(p *string) {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	for i := range j.Inputs {
+		resolve(&j.Inputs[i])
+	}
+	resolve(&j.Output.Path)
+	resolve(&j.Output.Dir)
+	resolve(&j.Output.Report)
+	resolve(&j.Errors.Rejects)
+	return j, nil
+}
+
+// validate checks the settings of a job and compiles its transformations.
+// Input moves to Inputs.
+func (j *Job) validate() error {
+	var problems []string
+	problem := func(field, format string, args ...interface{}) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if j.Input != "" {
+		if len(j.Inputs) > 0 {
+			problem("input", "use either input or inputs")
+		}
+		j.Inputs = []string{j.Input}
+		j.Input = ""
+	}
+	for i, input := range j.Inputs {
+		if strings.TrimSpace(input) == "" {
+			problem(fmt.Sprintf("inputs[%d]", i), "empty file name")
+		}
+	}
+
+	if j.Dialect != "" && j.Dialect != "auto" {
+		if _, err := parser.ParseDialect(j.Dialect); err != nil {
+			problem("dialect", "%v", err)
+		}
+	}
+
+	if _, err := parser.ParseTableFilter(strings.Join(j.Tables.Include, ","), strings.Join(j.Tables.Exclude, ",")); err != nil {
+		problem("tables", "%v", err)
+	}
+
+	names := make([]string, 0, len(j.Transforms))
+	for name := range j.Transforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := j.Transforms[name]
+		for _, err := range t.compile() {
+			problem("transforms."+name, "%v", err)
+		}
+		j.Transforms[name] = t
+	}
+
+	switch format := models.OutputFormat(j.Output.Format); format {
+	case "", models.FormatText, models.FormatCSV, models.FormatJSON, models.FormatJSONL, models.FormatParquet,
+		models.FormatArrow, models.FormatArrowStream, models.FormatAvro:
+		if format == "" {
+			format = models.FormatText
+		}
+		if err := writer.CheckCompression(format, j.Output.Compression); err != nil {
+			problem("output.compression", "%v", err)
+		}
+	default:
+		problem("output.format", "unsupported format %q (want txt, csv, json, jsonl, parquet, arrow, arrows or avro)", j.Output.Format)
+	}
+	if j.Output.AvroSchema && j.Output.Format != string(models.FormatAvro) {
+		problem("output.avro_schema", "only applies to avro output")
+	}
+	if j.Output.Path != "" && j.Output.Dir != "" {
+		problem("output", "use either path or dir")
+	}
+	if j.Output.Path != "" && len(j.Inputs) > 1 {
+		problem("output.path", "can't write more than one input to a single file, use dir")
+	}
+
+	if j.Errors.Policy != "" {
+		if _, err := parser.ParseErrorPolicy(j.Errors.Policy); err != nil {
+			problem("errors.policy", "%v", err)
+		}
+	}
+	if j.Errors.Rejects != "" && j.Errors.Policy != string(parser.ErrorPolicyQuarantine) {
+		problem("errors.rejects", "only used with policy quarantine")
+	}
+
+	if j.Performance.Workers < 0 {
+		problem("performance.workers", "must be positive")
+	}
+	if j.Performance.BatchSize < 0 {
+		problem("performance.batch_size", "must be positive")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package job
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeJob(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "job.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeJob(t, `
+input: dump.sql.gz
+dialect: mysql
+tables:
+  include: [users, "shop.*"]
+  exclude: ["/^tmp_/"]
+transforms:
+  users:
+    drop: [password_hash]
+    where: active = 1
+output:
+  format: parquet
+  compression: zstd
+  dir: export
+  report: /var/log/report.json
+errors:
+  policy: quarantine
+  rejects: rejects.sql
+performance:
+  workers: 4
+  batch_size: 500
+`)
+	j, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Dir(path)
+
+	// Input moves to Inputs, and relative paths are relative to the job file
+	if j.Input != "" || !reflect.DeepEqual(j.Inputs, []string{filepath.Join(dir, "dump.sql.gz")}) {
+		t.Errorf("input %q, inputs %v", j.Input, j.Inputs)
+	}
+	want := Output{Format: "parquet", Compression: "zstd", Dir: filepath.Join(dir, "export"), Report: "/var/log/report.json"}
+	if j.Output != want {
+		t.Errorf("output = %+v, want %+v", j.Output, want)
+	}
+	if j.Errors.Rejects != filepath.Join(dir, "rejects.sql") {
+		t.Errorf("rejects = %s", j.Errors.Rejects)
+	}
+	if !reflect.DeepEqual(j.Tables, Tables{Include: []string{"users", "shop.*"}, Exclude: []string{"/^tmp_/"}}) {
+		t.Errorf("tables = %+v", j.Tables)
+	}
+	if j.Performance != (Performance{Workers: 4, BatchSize: 500}) {
+		t.Errorf("performance = %+v", j.Performance)
+	}
+	if users := j.Transforms["users"]; users.where == nil || !reflect.DeepEqual(users.Drop, []string{"password_hash"}) {
+		t.Errorf("users transform = %+v", users)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		errs []string // all of them are reported
+	}{
+		{name: "unknown field", yaml: "input: d.sql\nworkers: 4\n", errs: []string{"field workers not found"}},
+		{name: "not yaml", yaml: "input: [d.sql\n", errs: []string{"error reading job file"}},
+		{name: "input and inputs", yaml: "input: a.sql\ninputs: [b.sql]\n", errs: []string{"input: use either input or inputs"}},
+		{name: "empty input", yaml: "inputs: [b.sql, ' ']\n", errs: []string{"inputs[1]: empty file name"}},
+		{name: "dialect", yaml: "dialect: oracle\n", errs: []string{"dialect: "}},
+		{name: "table pattern", yaml: "tables:\n  include: ['/(/']\n", errs: []string{"tables: invalid table pattern /(/"}},
+		{
+			name: "transforms",
+			yaml: "transforms:\n  users:\n    where: active =\n    rename: {a: x, b: x, c: ''}\n",
+			errs: []string{
+				"transforms.users: where: ",
+				`transforms.users: rename: columns "a" and "b" are both renamed to "x"`,
+				`transforms.users: rename: empty new name for column "c"`,
+			},
+		},
+		{
+			name: "output",
+			yaml: "inputs: [a.sql, b.sql]\noutput:\n  format: xml\n  path: out\n  dir: out\n  avro_schema: true\n",
+			errs: []string{
+				`output.format: unsupported format "xml"`,
+				"output.avro_schema: only applies to avro output",
+				"output: use either path or dir",
+				"output.path: can't write more than one input to a single file",
+			},
+		},
+		{name: "compression", yaml: "output:\n  format: csv\n  compression: gzip\n", errs: []string{"output.compression: csv output isn't compressed"}},
+		{
+			name: "errors",
+			yaml: "errors:\n  policy: retry\n  rejects: r.sql\n",
+			errs: []string{"errors.policy: ", "errors.rejects: only used with policy quarantine"},
+		},
+		{
+			name: "performance",
+			yaml: "performance:\n  workers: -1\n  batch_size: -1\n",
+			errs: []string{"performance.workers: must be positive", "performance.batch_size: must be positive"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeJob(t, tt.yaml))
+			if err == nil {
+				t.Fatal("no error")
+			}
+			for _, want := range tt.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q lacks %q", err, want)
+				}
+			}
+		})
+	}
+}
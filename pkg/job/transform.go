@@ -0,0 +1,148 @@
+package job
+
+import (
+	"fmt"
+	"sort"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/query"
+	"sqlparser/pkg/writer"
+)
+
+// Transform changes the rows of a table before they are written. Rows that
+// don't match Where are left out; then only Columns are kept if it is set,
+// Drop columns are removed, and columns are renamed by Rename (old: new).
+type Transform struct {
+	Columns []string          `yaml:"columns"`
+	Drop    []string          `yaml:"drop"`
+	Rename  map[string]string `yaml:"rename"`
+	Where   string            `yaml:"where"` // a condition as in a WHERE clause
+
+	where *query.Condition
+	keep  map[string]bool
+}
+
+// compile parses the condition of a transformation and checks its settings.
+func (t *Transform) compile() []error {
+	var errs []error
+	if t.Where != "" {
+		var err error
+		if t.where, err = query.ParseCondition(t.Where); err != nil {
+			errs = append(errs, fmt.Errorf("where: %v", err))
+		}
+	}
+	if len(t.Columns) > 0 {
+		t.keep = make(map[string]bool, len(t.Columns))
+		for _, col := range t.Columns {
+			t.keep[col] = true
+		}
+	}
+	froms := make([]string, 0, len(t.Rename))
+	for from := range t.Rename {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	targets := make(map[string]string)
+	for _, from := range froms {
+		to := t.Rename[from]
+		if to == "" {
+			errs = append(errs, fmt.Errorf("rename: empty new name for column %q", from))
+		} else if other, ok := targets[to]; ok {
+			errs = append(errs, fmt.Errorf("rename: columns %q and %q are both renamed to %q", other, from, to))
+		}
+		targets[to] = from
+	}
+	return errs
+}
+
+// apply transforms a row, reporting false if it is left out.
+func (t *Transform) apply(row *models.Row) bool {
+	if t.where != nil && !t.where.Match(row.Data) {
+		return false
+	}
+	if t.keep != nil {
+		for col := range row.Data {
+			if !t.keep[col] {
+				delete(row.Data, col)
+			}
+		}
+	}
+	for _, col := range t.Drop {
+		delete(row.Data, col)
+	}
+	if len(t.Rename) > 0 {
+		renamed := make(map[string]interface{}, len(t.Rename))
+		for from, to := range t.Rename {
+			if value, ok := row.Data[from]; ok {
+				renamed[to] = value
+				delete(row.Data, from)
+			}
+		}
+		for col, value := range renamed {
+			row.Data[col] = value
+		}
+	}
+	return true
+}
+
+// Writer returns a writer that applies the job's transformations to the rows
+// of each table before passing them on to w.
+func (j *Job) Writer(w writer.Writer) writer.Writer {
+	if len(j.Transforms) == 0 {
+		return w
+	}
+	return &transformWriter{Writer: w, transforms: j.Transforms}
+}
+
+type transformWriter struct {
+	writer.Writer
+	transforms map[string]Transform
+}
+
+// WriteSchema passes the schema on with the columns the transformation
+// leaves, under their new names.
+func (w *transformWriter) WriteSchema(schema *models.Schema) error {
+	sw, ok := w.Writer.(writer.SchemaWriter)
+	if !ok {
+		return nil
+	}
+	t, ok := w.transforms[schema.TableName]
+	if !ok {
+		return sw.WriteSchema(schema)
+	}
+	dropped := make(map[string]bool, len(t.Drop))
+	for _, col := range t.Drop {
+		dropped[col] = true
+	}
+	transformed := &models.Schema{TableName: schema.TableName}
+	for _, col := range schema.Columns {
+		if t.keep != nil && !t.keep[col.Name] || dropped[col.Name] {
+			continue
+		}
+		if to, ok := t.Rename[col.Name]; ok {
+			col.Name = to
+		}
+		transformed.Columns = append(transformed.Columns, col)
+	}
+	return sw.WriteSchema(transformed)
+}
+
+func (w *transformWriter) WriteRows(rows []models.Row) error {
+	if len(rows) == 0 {
+		return w.Writer.WriteRows(rows)
+	}
+	t, ok := w.transforms[rows[0].TableName]
+	if !ok {
+		return w.Writer.WriteRows(rows)
+	}
+	kept := rows[:0]
+	for _, row := range rows {
+		if t.apply(&row) {
+			kept = append(kept, row)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return w.Writer.WriteRows(kept)
+}
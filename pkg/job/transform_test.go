@@ -0,0 +1,111 @@
+package job
+
+import (
+	"reflect"
+	"testing"
+
+	"sqlparser/pkg/models"
+)
+
+// recordingWriter is a writer.Writer that keeps what it is given.
+type recordingWriter struct {
+	schema *models.Schema
+	rows   []map[string]interface{}
+}
+
+func (w *recordingWriter) WriteTableStart(string) error { return nil }
+func (w *recordingWriter) WriteTableEnd() error         { return nil }
+func (w *recordingWriter) Close() error                 { return nil }
+func (w *recordingWriter) Type() models.OutputFormat    { return models.FormatJSONL }
+
+func (w *recordingWriter) WriteSchema(schema *models.Schema) error {
+	w.schema = schema
+	return nil
+}
+
+func (w *recordingWriter) WriteRows(rows []models.Row) error {
+	for _, row := range rows {
+		w.rows = append(w.rows, row.Data)
+	}
+	return nil
+}
+
+func TestTransform(t *testing.T) {
+	schema := &models.Schema{TableName: "users", Columns: []models.Column{
+		{Name: "id", Type: "int"}, {Name: "name", Type: "varchar(10)"}, {Name: "hash", Type: "char(64)"}, {Name: "active", Type: "tinyint"},
+	}}
+	rows := func() []models.Row {
+		return []models.Row{
+			{TableName: "users", Data: map[string]interface{}{"id": "1", "name": "ann", "hash": "x", "active": "1"}},
+			{TableName: "users", Data: map[string]interface{}{"id": "2", "name": "bob", "hash": "y", "active": "0"}},
+			{TableName: "users", Data: map[string]interface{}{"id": "3", "name": nil, "hash": "z", "active": "1"}},
+		}
+	}
+	tests := []struct {
+		name      string
+		transform Transform
+		columns   []string
+		want      []map[string]interface{}
+	}{
+		{
+			name:      "where and drop",
+			transform: Transform{Where: "active = 1", Drop: []string{"hash", "active"}},
+			columns:   []string{"id", "name"},
+			want:      []map[string]interface{}{{"id": "1", "name": "ann"}, {"id": "3", "name": nil}},
+		},
+		{
+			name:      "columns and rename",
+			transform: Transform{Columns: []string{"name", "id"}, Rename: map[string]string{"id": "user_id", "name": "id"}},
+			columns:   []string{"user_id", "id"},
+			want: []map[string]interface{}{
+				{"user_id": "1", "id": "ann"}, {"user_id": "2", "id": "bob"}, {"user_id": "3", "id": nil},
+			},
+		},
+		{
+			name:      "every row left out",
+			transform: Transform{Where: "id > 10"},
+			columns:   []string{"id", "name", "hash", "active"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := tt.transform.compile(); len(errs) > 0 {
+				t.Fatal(errs)
+			}
+			out := &recordingWriter{}
+			j := &Job{Transforms: map[string]Transform{"users": tt.transform}}
+			w := j.Writer(out)
+			if err := w.(*transformWriter).WriteSchema(schema); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.WriteRows(rows()); err != nil {
+				t.Fatal(err)
+			}
+			var columns []string
+			for _, col := range out.schema.Columns {
+				columns = append(columns, col.Name)
+			}
+			if !reflect.DeepEqual(columns, tt.columns) {
+				t.Errorf("schema columns %v, want %v", columns, tt.columns)
+			}
+			if !reflect.DeepEqual(out.rows, tt.want) {
+				t.Errorf("got rows %v, want %v", out.rows, tt.want)
+			}
+		})
+	}
+
+	t.Run("other tables pass through", func(t *testing.T) {
+		out := &recordingWriter{}
+		j := &Job{Transforms: map[string]Transform{"orders": {Drop: []string{"id"}}}}
+		w := j.Writer(out)
+		w.(*transformWriter).WriteSchema(schema)
+		w.WriteRows(rows()[:1])
+		if out.schema != schema || len(out.rows[0]) != 4 {
+			t.Errorf("got schema %v and rows %v", out.schema, out.rows)
+		}
+	})
+
+	if out := (&recordingWriter{}); (&Job{}).Writer(out) != out {
+		t.Error("a job without transformations wraps the writer")
+	}
+}
@@ -10,10 +10,14 @@ var (
 )
 
 const (
-	FormatText  OutputFormat = "txt"
-	FormatCSV   OutputFormat = "csv"
-	FormatJSON  OutputFormat = "json"
-	FormatJSONL OutputFormat = "jsonl"
+	FormatText        OutputFormat = "txt"
+	FormatCSV         OutputFormat = "csv"
+	FormatJSON        OutputFormat = "json"
+	FormatJSONL       OutputFormat = "jsonl"
+	FormatParquet     OutputFormat = "parquet"
+	FormatArrow       OutputFormat = "arrow"  // Arrow IPC file format (Feather v2)
+	FormatArrowStream OutputFormat = "arrows" // Arrow IPC stream format
+	FormatAvro        OutputFormat = "avro"
 )
 
 func getBatchSize() int {
@@ -31,6 +35,13 @@ func (f OutputFormat) Extension() string {
 	return string(f)
 }
 
+// Appendable reports whether rows can be added to the end of a file in the
+// format, so that an interrupted export can continue its output. The other
+// formats close their files with a footer or closing bracket.
+func (f OutputFormat) Appendable() bool {
+	return f == FormatText || f == FormatCSV || f == FormatJSONL
+}
+
 type Row struct {
 	TableName string                 `json:"table_name,omitempty"`
 	RowNumber int                    `json:"row_number"`
@@ -42,3 +53,35 @@ type TableData struct {
 	RowCount  int    `json:"row_count"`
 	Rows      []Row  `json:"rows"`
 }
+
+// Column describes a table column as declared in CREATE TABLE.
+type Column struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+}
+
+// Schema is the structure of a table as declared in CREATE TABLE.
+type Schema struct {
+	TableName  string   `json:"table_name"`
+	Columns    []Column `json:"columns"`
+	PrimaryKey []string `json:"primary_key,omitempty"`
+}
+
+func (s *Schema) ColumnNames() []string {
+	names := make([]string, len(s.Columns))
+	for i, col := range s.Columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// Column returns the column called name, or nil if there is none.
+func (s *Schema) Column(name string) *Column {
+	for i := range s.Columns {
+		if s.Columns[i].Name == name {
+			return &s.Columns[i]
+		}
+	}
+	return nil
+}
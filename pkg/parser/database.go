@@ -1,55 +1,158 @@
 package parser
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"sort"
-	"strings"
+
+	"sqlparser/pkg/models"
 )
 
 type TableInfo struct {
 	Name     string
-	LineFrom int
-	LineTo   int
+	Database string // from the INSERT statement or a preceding USE statement, if any
+	LineFrom int    // line of the first INSERT statement
+	LineTo   int    // line of the last INSERT statement
+	Bytes    int64  // size of the INSERT statements, uncompressed, with comments between them
+
+	// Where to start reading to get the table's CREATE TABLE statement, if
+	// it precedes the INSERT statements, and all of its rows, and the
+	// ordinal of the statement and the database selected by USE there
+	StartLine      int
+	StartOffset    int64
+	StartStatement int
+	StartDatabase  string
+
+	// Set when resuming from a checkpoint: INSERT statements before
+	// ResumeLine were already processed and held ResumeRows rows
+	ResumeLine int
+	ResumeRows int
+
+	// Only filled in by ScanTableDetails
+	Rows    int      // number of rows in the INSERT statements
+	Columns []string // from CREATE TABLE, or the first INSERT statement's column list
+}
+
+// position is a line and byte offset in the input, the ordinal of the
+// statement starting there and the database selected by USE at that point.
+type position struct {
+	line      int
+	offset    int64
+	statement int
+	database  string
 }
 
+// tableKey identifies a table in a dump that may hold several databases.
+type tableKey struct {
+	database, name string
+}
+
+// ScanTables finds the tables that have INSERT statements in a dump and
+// where they are. The result is also kept for LoadTableIndex.
 func ScanTables(filename string) ([]TableInfo, error) {
-	file, err := os.Open(filename)
+	return scanTables(filename, false)
+}
+
+// ScanTableDetails is like ScanTables but also counts the rows of each table
+// and looks up its columns. This needs every statement to be read in full,
+// which makes it considerably slower.
+func ScanTableDetails(filename string) ([]TableInfo, error) {
+	return scanTables(filename, true)
+}
+
+func scanTables(filename string, details bool) ([]TableInfo, error) {
+	info, err := os.Stat(filename)
 	if err != nil {
 		return nil, fmt.Errorf("error opening file: %v", err)
 	}
+	file, err := OpenInput(filename)
+	if err != nil {
+		return nil, err
+	}
 	defer file.Close()
 
-	// Use a larger buffer for scanning
-	buf := make([]byte, 64*1024)
-	scanner := bufio.NewScanner(file)
-	scanner.Buffer(buf, 10*1024*1024) // 10MB max line length
-
 	// Use a map to track unique tables
-	tableMap := make(map[string]*TableInfo)
+	tableMap := make(map[tableKey]*TableInfo)
+	creates := make(map[tableKey]position)
+	database := ""
 
-	lineNum := 0
-	for scanner.Scan() {
-		lineNum++
-		line := strings.TrimSpace(scanner.Text())
+	// key qualifies a table name with the database selected by USE, unless
+	// the statement names one
+	key := func(db, name string) tableKey {
+		if db == "" {
+			db = database
+		}
+		return tableKey{db, name}
+	}
 
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "--") {
-			continue
+	// An INSERT statement extends to the start of the next statement, which
+	// is where its size is added to its table
+	var last *TableInfo
+	var lastStart int64
+
+	// Only the first line of each statement is needed, so the filter records
+	// the table and rejects the statement unless details are wanted
+	scanner := NewStatementScanner(file)
+	scanner.SetFilter(func(firstLine string) bool {
+		if last != nil {
+			last.Bytes += scanner.lineStart - lastStart
+			last = nil
+		}
+
+		if db := useDatabase(firstLine); db != "" {
+			database = db
+			return false
+		}
+
+		if db, tableName := createTable(firstLine); tableName != "" {
+			creates[key(db, tableName)] = position{line: scanner.line, offset: scanner.lineStart, statement: scanner.ordinal, database: database}
+			return details
 		}
 
 		// Check for INSERT INTO statements
-		if strings.HasPrefix(strings.ToUpper(line), "INSERT INTO") {
-			parts := strings.Split(line, "`")
-			if len(parts) >= 2 {
-				tableName := parts[1]
-				if _, exists := tableMap[tableName]; !exists {
-					tableMap[tableName] = &TableInfo{
-						Name:     tableName,
-						LineFrom: lineNum,
-					}
+		if db, tableName := insertTable(firstLine); tableName != "" {
+			k := key(db, tableName)
+			table, exists := tableMap[k]
+			if !exists {
+				table = &TableInfo{
+					Name:           tableName,
+					Database:       k.database,
+					LineFrom:       scanner.line,
+					StartLine:      scanner.line,
+					StartOffset:    scanner.lineStart,
+					StartStatement: scanner.ordinal,
+					StartDatabase:  database,
 				}
+				if create, ok := creates[k]; ok {
+					table.StartLine, table.StartOffset, table.StartStatement = create.line, create.offset, create.statement
+					table.StartDatabase = create.database
+				}
+				tableMap[k] = table
+			}
+			table.LineTo = scanner.line
+			last, lastStart = table, scanner.lineStart
+			return details
+		}
+		return false
+	})
+
+	// The filter has just seen the first line of each statement returned, so
+	// database is the one selected for it
+	columns := make(map[tableKey][]string)
+	for scanner.Scan() {
+		text := scanner.Statement().Text
+		if db, tableName := createTable(text); tableName != "" {
+			if schema, err := parseCreateTable(text); err == nil {
+				columns[key(db, tableName)] = schema.ColumnNames()
+			}
+			continue
+		}
+		if table := tableMap[key(insertTable(text))]; table != nil {
+			table.Rows += countTuples(text)
+			if table.Columns == nil {
+				table.Columns = insertColumns(text)
 			}
 		}
 	}
@@ -57,18 +160,28 @@ func ScanTables(filename string) ([]TableInfo, error) {
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("error scanning file: %v", err)
 	}
+	if last != nil {
+		last.Bytes += scanner.consumed - lastStart
+	}
 
 	// Convert map to slice
 	var tables []TableInfo
 	for _, table := range tableMap {
+		if cols, ok := columns[tableKey{table.Database, table.Name}]; ok {
+			table.Columns = cols
+		}
 		tables = append(tables, *table)
 	}
 
 	// Sort tables by name for consistent display
 	sort.Slice(tables, func(i, j int) bool {
-		return tables[i].Name < tables[j].Name
+		if tables[i].Name != tables[j].Name {
+			return tables[i].Name < tables[j].Name
+		}
+		return tables[i].Database < tables[j].Database
 	})
 
+	saveTableIndex(filename, info, tables)
 	return tables, nil
 }
 
@@ -77,14 +190,15 @@ func PromptTableSelection(tables []TableInfo) (*TableInfo, error) {
 		return nil, fmt.Errorf("no tables found in the file")
 	}
 
-	fmt.Println("\nFound the following tables with INSERT statements:")
-	fmt.Printf("0. Export all tables\n")
+	// The menu goes to stderr so that it doesn't end up in exported data
+	fmt.Fprintln(os.Stderr, "\nFound the following tables with INSERT statements:")
+	fmt.Fprintf(os.Stderr, "0. Export all tables\n")
 	for i, table := range tables {
-		fmt.Printf("%d. %s\n", i+1, table.Name)
+		fmt.Fprintf(os.Stderr, "%d. %s\n", i+1, table.Name)
 	}
 
 	var choice int
-	fmt.Print("\nEnter the number of the table you want to parse (0-" + fmt.Sprint(len(tables)) + "): ")
+	fmt.Fprint(os.Stderr, "\nEnter the number of the table you want to parse (0-"+fmt.Sprint(len(tables))+"): ")
 	_, err := fmt.Scanf("%d", &choice)
 	if err != nil || choice < 0 || choice > len(tables) {
 		return nil, fmt.Errorf("invalid selection")
@@ -104,3 +218,44 @@ type AllTablesSelected struct {
 func (e *AllTablesSelected) Error() string {
 	return "all tables selected"
 }
+
+// errPreviewDone stops PreviewTable's Stream once enough rows were seen.
+var errPreviewDone = errors.New("preview done")
+
+// PreviewTable returns the first n rows of table, parsing no more of the
+// file than needed.
+func PreviewTable(filename string, table TableInfo, n int) ([]models.Row, error) {
+	file, err := OpenInputAt(filename, table.StartOffset)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rows []models.Row
+	cfg := Config{
+		File:           filename,
+		StartLine:      table.StartLine,
+		StartOffset:    table.StartOffset,
+		StartStatement: table.StartStatement,
+		StartDatabase:  table.StartDatabase,
+		EndLine:        table.LineTo,
+		BatchSize:      n,
+		Tables:         func(db, name string) bool { return db == table.Database && name == table.Name },
+	}
+	_, err = Stream(context.Background(), file, cfg, HandlerFuncs{
+		OnRows: func(_ string, batch []models.Row) error {
+			rows = append(rows, batch...)
+			if len(rows) >= n {
+				return errPreviewDone
+			}
+			return nil
+		},
+	})
+	if err != nil && err != errPreviewDone {
+		return nil, err
+	}
+	if len(rows) > n {
+		rows = rows[:n]
+	}
+	return rows, nil
+}
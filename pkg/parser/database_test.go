@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/writer"
+)
+
+// writeDump writes a dump to a temporary file and keeps the table index of
+// the test in a temporary cache directory.
+func writeDump(t *testing.T, dump string) string {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	filename := filepath.Join(t.TempDir(), "dump.sql")
+	if err := os.WriteFile(filename, []byte(dump), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// twoTables has a CREATE TABLE and INSERT statements for a and b, and a
+// broken INSERT statement for b.
+const twoTables = "-- dump\n" +
+	"CREATE TABLE `a` (\n  `id` int(11) NOT NULL\n);\n" +
+	"INSERT INTO `a` VALUES (1),(2);\n" +
+	"\n" +
+	"CREATE TABLE `b` (\n  `id` int(11) NOT NULL,\n  `name` varchar(10)\n);\n" +
+	"INSERT INTO `b` VALUES (1,'x');\n" +
+	"INSERT INTO `b` VALUES (2,'y'),(3,'z';\n"
+
+func TestScanTables(t *testing.T) {
+	filename := writeDump(t, twoTables)
+	tables, err := ScanTables(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TableInfo{
+		{Name: "a", LineFrom: 5, LineTo: 5, Bytes: 33, StartLine: 2, StartOffset: 8, StartStatement: 1},
+		{Name: "b", LineFrom: 11, LineTo: 12, Bytes: 71, StartLine: 7, StartOffset: 87, StartStatement: 3},
+	}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("got  %+v\nwant %+v", tables, want)
+	}
+
+	indexed, ok := LoadTableIndex(filename)
+	if !ok || !reflect.DeepEqual(indexed, want) {
+		t.Errorf("index = %+v, %v; want %+v", indexed, ok, want)
+	}
+}
+
+func TestSeekKeepsStatementOrdinals(t *testing.T) {
+	filename := writeDump(t, twoTables)
+	tables, err := ScanTables(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rejected []*ParseError
+	errs := NewErrorHandler(ErrorPolicySkip, nil)
+	errs.OnReject = func(err *ParseError) { rejected = append(rejected, err) }
+	w, err := writer.CreateWriter(models.FormatJSONL, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := ProcessSQLFileInBatches(context.Background(), filename, w, 1, &tables[1], errs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Rows != 1 {
+		t.Errorf("got %d rows, want 1", stats.Rows)
+	}
+	if len(rejected) != 1 {
+		t.Fatalf("got %d rejected statements, want 1", len(rejected))
+	}
+	// The broken statement is the fifth of the file, on line 12
+	if perr := rejected[0]; perr.Statement != 5 || perr.Line != 12 || perr.Table != "b" {
+		t.Errorf("got statement %d on line %d of table %s, want statement 5 on line 12 of b", perr.Statement, perr.Line, perr.Table)
+	}
+}
+
+// twoDatabases has a users table in each of two databases, and an
+// orders table of the first one qualified while the second is in use.
+const twoDatabases = "USE `shop`;\n" +
+	"CREATE TABLE `users` (\n  `id` int(11) NOT NULL\n);\n" +
+	"INSERT INTO `users` VALUES (1),(2);\n" +
+	"USE `other`;\n" +
+	"CREATE TABLE `users` (\n  `id` int(11) NOT NULL\n);\n" +
+	"INSERT INTO `users` VALUES (3);\n" +
+	"INSERT INTO `shop`.`users` VALUES (4);\n" +
+	"INSERT INTO `users` VALUES (5);\n"
+
+func TestTablesOfTwoDatabases(t *testing.T) {
+	filename := writeDump(t, twoDatabases)
+	tables, err := ScanTables(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TableInfo{
+		{Name: "users", Database: "other", LineFrom: 10, LineTo: 12, Bytes: 64, StartLine: 7, StartOffset: 111, StartStatement: 5, StartDatabase: "other"},
+		{Name: "users", Database: "shop", LineFrom: 5, LineTo: 11, Bytes: 75, StartLine: 2, StartOffset: 12, StartStatement: 2, StartDatabase: "shop"},
+	}
+	if !reflect.DeepEqual(tables, want) {
+		t.Fatalf("got  %+v\nwant %+v", tables, want)
+	}
+
+	tests := []struct {
+		table TableInfo
+		ids   []string
+	}{
+		{tables[0], []string{"3", "5"}},
+		{tables[1], []string{"1", "2", "4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.table.Database, func(t *testing.T) {
+			rows, err := PreviewTable(filename, tt.table, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, row := range rows {
+				ids = append(ids, row.Data["id"].(string))
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("got ids %v, want %v", ids, tt.ids)
+			}
+		})
+	}
+}
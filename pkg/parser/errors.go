@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ErrColumnCount is wrapped by the ParseErrors of tuples whose number of
+// values doesn't match the column list.
+var ErrColumnCount = errors.New("column count mismatch")
+
+// ParseError reports a statement that could not be parsed, with enough
+// position information to find the offending spot in the input file.
+type ParseError struct {
+	File      string
+	Statement int    // 1-based ordinal of the statement in the file
+	Table     string // table the statement inserts into, if known
+	Row       int    // 1-based tuple within the statement, 0 if the whole statement failed
+	Line      int    // 1-based line number
+	Column    int    // 1-based byte column within Line
+	Offset    int64  // byte offset from the start of the file
+	Snippet   string // excerpt of the offending line with a caret under Column
+	Err       error
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File)
+		b.WriteByte(':')
+	}
+	fmt.Fprintf(&b, "%d:%d: ", e.Line, e.Column)
+	if e.Statement > 0 {
+		fmt.Fprintf(&b, "statement %d", e.Statement)
+		if e.Table != "" {
+			fmt.Fprintf(&b, " (table %s)", e.Table)
+		}
+		if e.Row > 0 {
+			fmt.Fprintf(&b, " row %d", e.Row)
+		}
+		b.WriteString(": ")
+	}
+	fmt.Fprintf(&b, "%v (byte offset %d)", e.Err, e.Offset)
+	return b.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// LogValue logs the error's position as separate fields.
+func (e *ParseError) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("message", e.Err.Error())}
+	if e.File != "" {
+		attrs = append(attrs, slog.String("file", e.File))
+	}
+	attrs = append(attrs,
+		slog.Int("line", e.Line),
+		slog.Int("column", e.Column),
+		slog.Int64("offset", e.Offset),
+		slog.Int("statement", e.Statement))
+	if e.Table != "" {
+		attrs = append(attrs, slog.String("table", e.Table))
+	}
+	if e.Row > 0 {
+		attrs = append(attrs, slog.Int("row", e.Row))
+	}
+	if e.Snippet != "" {
+		attrs = append(attrs, slog.String("snippet", e.Snippet))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// syntaxError is a parse failure at a byte position within a statement. It is
+// turned into a ParseError once the statement's position in the file is known.
+// Errors about a single tuple carry its 1-based row number and end position.
+type syntaxError struct {
+	pos int
+	end int
+	row int
+	msg string
+	err error // sentinel the error is a case of, if any
+}
+
+func (e *syntaxError) Error() string {
+	return e.msg
+}
+
+func (e *syntaxError) Unwrap() error {
+	return e.err
+}
+
+// newParseError wraps err with the position of stmt in the input. If err is a
+// syntaxError the position is narrowed down to the offending byte.
+func newParseError(stmt *Statement, table string, err error) *ParseError {
+	pos, row := 0, 0
+	if se, ok := err.(*syntaxError); ok {
+		pos, row = se.pos, se.row
+	}
+	line, column, offset := stmt.Position(pos)
+	return &ParseError{
+		Statement: stmt.Ordinal,
+		Table:     table,
+		Row:       row,
+		Line:      line,
+		Column:    column,
+		Offset:    offset,
+		Snippet:   stmt.snippet(pos),
+		Err:       err,
+	}
+}
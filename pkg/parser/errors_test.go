@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorPosition(t *testing.T) {
+	long := "INSERT INTO `t` VALUES " + strings.Repeat("(1,'aaaaaaaaaa'),", 5) + "(2,'b';\n"
+	tests := []struct {
+		name    string
+		dump    string
+		want    ParseError
+		message string
+	}{
+		{
+			name: "single line",
+			dump: "-- header\n\nINSERT INTO `t` (`a`, `b`) VALUES (1,'x');\nINSERT INTO `t` (`a`, `b`) VALUES (1,'x'),(2,'y';\n",
+			want: ParseError{File: "d.sql", Statement: 2, Table: "t", Line: 4, Column: 43, Offset: 96,
+				Snippet: "...SERT INTO `t` (`a`, `b`) VALUES (1,'x'),(2,'y';\n" +
+					strings.Repeat(" ", 43) + "^"},
+			message: "d.sql:4:43: statement 2 (table t): unterminated tuple (byte offset 96)",
+		},
+		{
+			name: "second line of a statement",
+			dump: "INSERT INTO `t` (`a`) VALUES\n(1),\n(2;\n",
+			want: ParseError{File: "d.sql", Statement: 1, Table: "t", Line: 3, Column: 1, Offset: 34,
+				Snippet: "(2;\n^"},
+			message: "d.sql:3:1: statement 1 (table t): unterminated tuple (byte offset 34)",
+		},
+		{
+			name: "snippet of a long line",
+			dump: "CREATE TABLE `t` (\n  `a` int,\n  `b` text\n);\n" + long,
+			want: ParseError{File: "d.sql", Statement: 2, Table: "t", Line: 5, Column: 109, Offset: 152,
+				Snippet: "...aaa'),(1,'aaaaaaaaaa'),(1,'aaaaaaaaaa'),(2,'b';\n" +
+					strings.Repeat(" ", 43) + "^"},
+			message: "d.sql:5:109: statement 2 (table t): unterminated tuple (byte offset 152)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{File: "d.sql", Errors: NewErrorHandler(ErrorPolicyFail, nil)}
+			_, err := Stream(context.Background(), strings.NewReader(tt.dump), cfg, HandlerFuncs{})
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("got %v, want a ParseError", err)
+			}
+			got := *perr
+			got.Err = nil
+			if got != tt.want {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+			if perr.Error() != tt.message {
+				t.Errorf("message = %q, want %q", perr.Error(), tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// tableIndexVersion changes whenever TableInfo does, so that indexes written
+// by older versions are scanned again.
+const tableIndexVersion = 3
+
+// tableIndex is the result of scanning a dump for its tables, kept in the
+// user's cache directory so that later runs can seek to a table without
+// reading the file up to it.
+type tableIndex struct {
+	Version int         `json:"version"`
+	File    string      `json:"file"`
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"mod_time"`
+	Tables  []TableInfo `json:"tables"`
+}
+
+// indexPath returns the file the index of filename is kept in.
+func indexPath(filename string) (string, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, "sqlparser", "index", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// LoadTableIndex returns the tables of filename as found by the last
+// ScanTables of it. ok is false if the file wasn't scanned yet or has
+// changed since.
+func LoadTableIndex(filename string) (tables []TableInfo, ok bool) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, false
+	}
+	path, err := indexPath(filename)
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var idx tableIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, false
+	}
+	if idx.Version != tableIndexVersion || idx.Size != info.Size() || !idx.ModTime.Equal(info.ModTime()) {
+		return nil, false
+	}
+	return idx.Tables, true
+}
+
+// saveTableIndex records the tables of filename for LoadTableIndex. The
+// index is only a shortcut, so failing to write it isn't an error.
+func saveTableIndex(filename string, info os.FileInfo, tables []TableInfo) {
+	path, err := indexPath(filename)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(tableIndex{Version: tableIndexVersion, File: filename, Size: info.Size(), ModTime: info.ModTime(), Tables: tables})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
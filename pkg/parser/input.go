@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// Input is a dump file opened for reading, decompressed if necessary.
+type Input struct {
+	file *os.File
+	size int64
+	pos  int64 // bytes of file consumed, accessed atomically
+	r    io.Reader
+	gz   *gzip.Reader
+}
+
+// OpenInput opens a dump file for reading. Gzip-compressed files are detected
+// by their magic number and decompressed transparently.
+func OpenInput(filename string) (*Input, error) {
+	return OpenInputAt(filename, 0)
+}
+
+// OpenInputAt is like OpenInput but starts reading at offset, a position in
+// the decompressed data as recorded by ScanTables. Plain files are seeked;
+// compressed files are decompressed up to offset.
+func OpenInputAt(filename string, offset int64) (*Input, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+
+	in := &Input{file: file, size: info.Size()}
+	buffered := bufio.NewReaderSize(fileReader{in}, 64*1024)
+	magic, _ := buffered.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		in.gz, err = gzip.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("error opening gzip stream: %v", err)
+		}
+		in.r = in.gz
+		if offset > 0 {
+			if _, err := io.CopyN(io.Discard, in.gz, offset); err != nil {
+				in.Close()
+				return nil, fmt.Errorf("error skipping to offset %d: %v", offset, err)
+			}
+		}
+		return in, nil
+	}
+
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("error seeking to offset %d: %v", offset, err)
+		}
+		atomic.StoreInt64(&in.pos, offset)
+		buffered.Reset(fileReader{in})
+	}
+	in.r = buffered
+	return in, nil
+}
+
+func (in *Input) Read(p []byte) (int, error) {
+	return in.r.Read(p)
+}
+
+// Size returns the size of the file on disk.
+func (in *Input) Size() int64 {
+	return in.size
+}
+
+// Position returns how far into the file on disk reading has got. For
+// compressed files this is less than the amount of data returned by Read.
+// It may be called concurrently with Read.
+func (in *Input) Position() int64 {
+	return atomic.LoadInt64(&in.pos)
+}
+
+func (in *Input) Close() error {
+	if in.gz != nil {
+		in.gz.Close()
+	}
+	return in.file.Close()
+}
+
+// fileReader reads from the file of an Input and keeps track of its position.
+type fileReader struct {
+	in *Input
+}
+
+func (f fileReader) Read(p []byte) (int, error) {
+	n, err := f.in.file.Read(p)
+	atomic.AddInt64(&f.in.pos, int64(n))
+	return n, err
+}
@@ -0,0 +1,318 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sqlparser/pkg/models"
+)
+
+// DumpInfo summarizes a dump file, as reported by Inspect.
+type DumpInfo struct {
+	File       string         `json:"file"`
+	Size       int64          `json:"size"` // bytes on disk
+	Compressed bool           `json:"compressed"`
+	Dialect    string         `json:"dialect"`
+	Header     DumpHeader     `json:"header"`
+	Databases  []string       `json:"databases"`
+	Statements int            `json:"statements"`
+	Tables     []TableSummary `json:"tables"`
+}
+
+// DumpHeader holds what the comment header written by dump tools tells about
+// the dump. Fields are empty if the header doesn't mention them.
+type DumpHeader struct {
+	Tool          string `json:"tool,omitempty"` // e.g. "MySQL dump 10.13"
+	Host          string `json:"host,omitempty"`
+	Database      string `json:"database,omitempty"`
+	ServerVersion string `json:"server_version,omitempty"`
+}
+
+// TableSummary describes one table of a dump.
+type TableSummary struct {
+	Name       string          `json:"name"`
+	Database   string          `json:"database,omitempty"`
+	Statements int             `json:"statements"` // INSERT statements
+	Rows       int             `json:"rows"`
+	Bytes      int64           `json:"bytes"` // size of the INSERT statements, uncompressed
+	LineFrom   int             `json:"line_from"`
+	LineTo     int             `json:"line_to"`
+	Columns    []models.Column `json:"columns"`
+	PrimaryKey []string        `json:"primary_key,omitempty"`
+}
+
+// Dialects reported by Inspect.
+const (
+	DialectMySQL      = "MySQL"
+	DialectMariaDB    = "MariaDB"
+	DialectPostgreSQL = "PostgreSQL"
+	DialectSQLite     = "SQLite"
+	DialectUnknown    = "unknown"
+)
+
+// ParseDialect returns the dialect called name, ignoring case: mysql,
+// mariadb, postgresql or sqlite.
+func ParseDialect(name string) (string, error) {
+	for _, dialect := range []string{DialectMySQL, DialectMariaDB, DialectPostgreSQL, DialectSQLite} {
+		if strings.EqualFold(name, dialect) {
+			return dialect, nil
+		}
+	}
+	return "", fmt.Errorf("unknown dialect %q (want mysql, mariadb, postgresql or sqlite)", name)
+}
+
+// dialectStatements is how many statements DetectDialect looks at.
+const dialectStatements = 100
+
+// DetectDialect guesses the dialect of a dump from its header and first
+// statements, without reading the whole file like Inspect.
+func DetectDialect(filename string) (string, error) {
+	file, err := OpenInput(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	dialect := dialectDetector{}
+	scanner := NewStatementScanner(&headerReader{r: file, info: &DumpInfo{}, dialect: &dialect})
+	for n := 0; n < dialectStatements && scanner.Scan(); n++ {
+		dialect.statement(scanner.Statement().Text)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error scanning file: %v", err)
+	}
+	return dialect.result(), nil
+}
+
+// headerLines is how many lines at the start of a dump are searched for the
+// header comments.
+const headerLines = 50
+
+var headerPatterns = map[string]*regexp.Regexp{
+	"tool":     regexp.MustCompile(`^--\s*((?:MySQL|MariaDB) dump [\d.]+|PostgreSQL database dump)`),
+	"host":     regexp.MustCompile(`^--\s*Host:\s*(\S+)`),
+	"database": regexp.MustCompile(`Database:\s*(\S+)`),
+	"server":   regexp.MustCompile(`^--\s*(?:Server version|Dumped from database version)[:\s]+(.+)$`),
+}
+
+// Inspect reads the whole dump and summarizes its contents: the dialect and
+// header information, the databases, and for every table its columns and the
+// number and size of its INSERT statements and rows.
+func Inspect(filename string) (*DumpInfo, error) {
+	file, err := OpenInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info := &DumpInfo{File: filename, Size: file.Size(), Compressed: file.gz != nil}
+	dialect := dialectDetector{}
+
+	// The header is made of comments, which the statement scanner skips, so
+	// they are picked out of the lines as they are read
+	header := &headerReader{r: file, info: info, dialect: &dialect}
+	scanner := NewStatementScanner(header)
+
+	tables := make(map[string]*TableSummary)
+	schemas := make(map[string]*models.Schema)
+	databases := make(map[string]bool)
+	currentDB := ""
+	table := func(db, name string) *TableSummary {
+		t, ok := tables[name]
+		if !ok {
+			if db == "" {
+				db = currentDB
+			}
+			t = &TableSummary{Name: name, Database: db}
+			tables[name] = t
+		}
+		return t
+	}
+
+	for scanner.Scan() {
+		stmt := scanner.Statement()
+		info.Statements++
+		dialect.statement(stmt.Text)
+
+		if db := useDatabase(stmt.Text); db != "" {
+			currentDB = db
+			databases[db] = true
+			continue
+		}
+		if db := createDatabaseName(stmt.Text); db != "" {
+			databases[db] = true
+			continue
+		}
+		if db, name := createTable(stmt.Text); name != "" {
+			if db != "" {
+				databases[db] = true
+			}
+			if schema, err := parseCreateTable(stmt.Text); err == nil {
+				schemas[name] = schema
+			}
+			continue
+		}
+		if db, name := insertTable(stmt.Text); name != "" {
+			if db != "" {
+				databases[db] = true
+			}
+			t := table(db, name)
+			if t.Statements == 0 {
+				t.LineFrom = stmt.Line
+				for _, col := range insertColumns(stmt.Text) {
+					t.Columns = append(t.Columns, models.Column{Name: col, Nullable: true})
+				}
+			}
+			t.LineTo = stmt.Line
+			t.Statements++
+			t.Rows += countTuples(stmt.Text)
+			t.Bytes += int64(len(stmt.Text)) + 1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning file: %v", err)
+	}
+
+	// Tables that were created but never filled are listed too
+	for name, schema := range schemas {
+		t := table("", name)
+		t.Columns = schema.Columns
+		t.PrimaryKey = schema.PrimaryKey
+	}
+	for _, t := range tables {
+		if t.Database == "" {
+			t.Database = info.Header.Database
+		}
+		info.Tables = append(info.Tables, *t)
+	}
+	sort.Slice(info.Tables, func(i, j int) bool {
+		return info.Tables[i].Name < info.Tables[j].Name
+	})
+
+	if info.Header.Database != "" {
+		databases[info.Header.Database] = true
+	}
+	for db := range databases {
+		info.Databases = append(info.Databases, db)
+	}
+	sort.Strings(info.Databases)
+	info.Dialect = dialect.result()
+	return info, nil
+}
+
+// createDatabaseName returns the database created by a CREATE DATABASE or
+// CREATE SCHEMA statement, or "".
+func createDatabaseName(statement string) string {
+	fields := strings.Fields(statement)
+	if len(fields) < 3 || !strings.EqualFold(fields[0], "CREATE") ||
+		!strings.EqualFold(fields[1], "DATABASE") && !strings.EqualFold(fields[1], "SCHEMA") {
+		return ""
+	}
+	name := fields[2]
+	if strings.EqualFold(name, "IF") && len(fields) >= 6 {
+		name = fields[5] // IF NOT EXISTS name
+	}
+	return unquoteIdentifier(strings.TrimSuffix(name, ";"))
+}
+
+// headerReader passes data through to the statement scanner while looking
+// for header comments in the first lines.
+type headerReader struct {
+	r       *Input
+	info    *DumpInfo
+	dialect *dialectDetector
+	lines   int
+	partial string
+}
+
+func (h *headerReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	if h.lines < headerLines && n > 0 {
+		data := h.partial + string(p[:n])
+		lines := strings.Split(data, "\n")
+		h.partial = lines[len(lines)-1]
+		for _, line := range lines[:len(lines)-1] {
+			if h.lines >= headerLines {
+				break
+			}
+			h.lines++
+			h.headerLine(strings.TrimRight(line, "\r"))
+		}
+	}
+	return n, err
+}
+
+func (h *headerReader) headerLine(line string) {
+	if !strings.HasPrefix(line, "--") {
+		return
+	}
+	header := &h.info.Header
+	set := func(field *string, key string) {
+		if m := headerPatterns[key].FindStringSubmatch(line); m != nil && *field == "" {
+			*field = strings.TrimSpace(m[1])
+		}
+	}
+	set(&header.Tool, "tool")
+	set(&header.Host, "host")
+	set(&header.Database, "database")
+	set(&header.ServerVersion, "server")
+	h.dialect.comment(line)
+}
+
+// dialectDetector guesses the SQL dialect from features typical of the dump
+// tools of each database.
+type dialectDetector struct {
+	mysql, mariadb, postgres, sqlite int
+}
+
+func (d *dialectDetector) comment(line string) {
+	switch {
+	case strings.Contains(line, "MariaDB"):
+		d.mariadb += 10
+	case strings.Contains(line, "MySQL"):
+		d.mysql += 10
+	case strings.Contains(line, "PostgreSQL"):
+		d.postgres += 10
+	case strings.Contains(line, "SQLite"):
+		d.sqlite += 10
+	}
+}
+
+func (d *dialectDetector) statement(text string) {
+	// INSERT statements can be huge, and their start is all that's needed
+	head := strings.TrimSpace(text)
+	if len(head) > 256 && !strings.HasPrefix(strings.ToUpper(head), "CREATE TABLE") {
+		head = head[:256]
+	}
+	upper := strings.ToUpper(head)
+	switch {
+	case strings.HasPrefix(upper, "/*!"), strings.Contains(upper, "ENGINE="), strings.HasPrefix(upper, "LOCK TABLES"):
+		d.mysql++
+	case strings.HasPrefix(upper, "SET SEARCH_PATH"), strings.HasPrefix(upper, "COPY "),
+		strings.Contains(upper, "PG_CATALOG"), strings.HasPrefix(upper, "ALTER TABLE ONLY"):
+		d.postgres++
+	case strings.HasPrefix(upper, "PRAGMA"), upper == "BEGIN TRANSACTION;":
+		d.sqlite++
+	case strings.Contains(head, "`"):
+		d.mysql++
+	}
+}
+
+func (d *dialectDetector) result() string {
+	// MariaDB dumps look like MySQL dumps apart from the header
+	if d.mariadb > 0 && d.mariadb+d.mysql >= d.postgres && d.mariadb+d.mysql >= d.sqlite {
+		return DialectMariaDB
+	}
+	best, dialect := 0, DialectUnknown
+	for _, c := range []struct {
+		score   int
+		dialect string
+	}{{d.mysql, DialectMySQL}, {d.postgres, DialectPostgreSQL}, {d.sqlite, DialectSQLite}} {
+		if c.score > best {
+			best, dialect = c.score, c.dialect
+		}
+	}
+	return dialect
+}
@@ -1,238 +1,348 @@
 package parser
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"os"
+	"log/slog"
 	"strings"
-	"sync"
 	"time"
 
 	"sqlparser/pkg/models"
+	"sqlparser/pkg/progress"
 	"sqlparser/pkg/writer"
 )
 
-func ProcessSQLFileInBatches(filename string, writer writer.Writer, numWorkers int, selectedTable *TableInfo) error {
+// ProcessSQLFileInBatches parses the INSERT statements of selectedTable and
+// writes their rows to writer. Statements that fail to parse are passed to
+// errs, which decides whether processing continues; a nil errs skips them.
+// Progress is reported to prog, which may be nil.
+//
+// Cancelling ctx stops processing after the rows parsed so far have been
+// written and the table has been ended. The returned Stats tell where to
+// resume; they are nil only if the file could not be opened.
+func ProcessSQLFileInBatches(ctx context.Context, filename string, writer writer.Writer, numWorkers int, selectedTable *TableInfo, errs *ErrorHandler, prog *progress.Reporter) (*Stats, error) {
 	startTime := time.Now()
 
-	file, err := os.Open(filename)
+	// Only read the part of the file holding the table, as recorded by ScanTables
+	file, err := OpenInputAt(filename, selectedTable.StartOffset)
 	if err != nil {
-		return fmt.Errorf("error opening file: %v", err)
+		return nil, err
 	}
 	defer file.Close()
 
-	// Use a larger buffer for scanning
-	buf := make([]byte, 64*1024)
-	scanner := bufio.NewScanner(file)
-	scanner.Buffer(buf, 10*1024*1024) // 10MB max line length
-
-	statementChan := make(chan string, numWorkers*2)
-	resultChan := make(chan *struct {
-		tableName string
-		rows      []models.Row
-		err       error
-	}, numWorkers*2)
-
-	fmt.Printf("Starting to process file: %s at %s\n", filename, startTime.Format(time.RFC3339))
-	fmt.Printf("Processing table: %s (lines %d-%d)\n", selectedTable.Name, selectedTable.LineFrom, selectedTable.LineTo)
-
-	// Start worker pool
-	var wg sync.WaitGroup
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			for statement := range statementChan {
-				tableName, rows, err := processStatement(statement, numWorkers)
-				resultChan <- &struct {
-					tableName string
-					rows      []models.Row
-					err       error
-				}{tableName, rows, err}
-			}
-		}(i)
-	}
-
-	// Start result processor
-	var processWg sync.WaitGroup
-	processWg.Add(1)
-	var currentTableName string
-	var rowCount int
-	var currentBatch []models.Row
-	var batchCount int
-	var totalStatements int
-	var tableStartTime time.Time
-
-	go func() {
-		defer processWg.Done()
-		for result := range resultChan {
-			if result.err != nil {
-				fmt.Printf("Error processing statement: %v\n", result.err)
-				continue
-			}
-
-			if result.rows != nil && result.tableName == selectedTable.Name {
-				// Handle new table
-				if result.tableName != currentTableName {
-					currentTableName = result.tableName
-					if err := writer.WriteTableStart(currentTableName); err != nil {
-						fmt.Printf("Error starting table: %v\n", err)
-						continue
-					}
-					currentBatch = make([]models.Row, 0, models.BatchSize)
-					rowCount = 0
-					batchCount = 0
-					tableStartTime = time.Now()
-					fmt.Printf("Started processing table: %s at %s\n", currentTableName, tableStartTime.Format(time.RFC3339))
-				}
-
-				// Process rows immediately
-				for _, row := range result.rows {
-					rowCount++
-					row.RowNumber = rowCount
-					currentBatch = append(currentBatch, row)
-
-					// Write batch if it reaches the batch size
-					if len(currentBatch) >= models.BatchSize {
-						batchStartTime := time.Now()
-						if err := writer.WriteRows(currentBatch); err != nil {
-							fmt.Printf("Error writing rows: %v\n", err)
-							continue
-						}
-						batchCount++
-						batchDuration := time.Since(batchStartTime)
-						fmt.Printf("Processed batch %d for table %s (%d rows) in %v\n",
-							batchCount, currentTableName, len(currentBatch), batchDuration)
-						currentBatch = make([]models.Row, 0, models.BatchSize)
-					}
-				}
-				// Clear the rows to help GC
-				result.rows = nil
-			}
-			totalStatements++
-		}
-	}()
+	slog.Info("processing table", "file", filename, "table", selectedTable.Name,
+		"line_from", selectedTable.LineFrom, "line_to", selectedTable.LineTo)
+
+	cfg := Config{
+		File:           filename,
+		StartLine:      selectedTable.StartLine,
+		StartOffset:    selectedTable.StartOffset,
+		StartStatement: selectedTable.StartStatement,
+		StartDatabase:  selectedTable.StartDatabase,
+		EndLine:        selectedTable.LineTo,
+		Workers:        numWorkers,
+		Tables:         func(db, name string) bool { return db == selectedTable.Database && name == selectedTable.Name },
+		Errors:         errs,
+		ResumeLine:     selectedTable.ResumeLine,
+		RowNumbers:     map[string]int{selectedTable.Name: selectedTable.ResumeRows},
+	}
+	stats, err := Stream(ctx, file, cfg, &writerHandler{writer: writer, input: file, from: file.Position(), progress: prog})
+	prog.EndTable()
+	if err != nil {
+		return stats, err
+	}
 
-	// Read and send statements to workers
-	var currentStatement strings.Builder
-	lineNum := 0
-	inSelectedTable := false
+	totalDuration := time.Since(startTime)
+	var perStatement time.Duration
+	if stats.Statements > 0 {
+		perStatement = totalDuration / time.Duration(stats.Statements)
+	}
+	slog.Info("processing summary", "file", filename, "table", selectedTable.Name,
+		"statements", stats.Statements, "rows", stats.Rows, "duration", totalDuration,
+		"per_statement", perStatement, "workers", numWorkers)
+	return stats, nil
+}
 
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
+// writerHandler is a Handler that writes rows to a writer.Writer and reports
+// progress while reading input.
+type writerHandler struct {
+	writer         writer.Writer
+	input          *Input
+	from           int64 // position in input where reading started
+	progress       *progress.Reporter
+	rowCount       int
+	batchCount     int
+	tableStartTime time.Time
+}
 
-		// Skip empty lines and comments
-		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "--") {
-			continue
+func (h *writerHandler) Schema(schema *models.Schema) error {
+	if sw, ok := h.writer.(writer.SchemaWriter); ok {
+		if err := sw.WriteSchema(schema); err != nil {
+			return fmt.Errorf("error writing schema: %v", err)
 		}
+	}
+	return nil
+}
 
-		// Check if we're in the selected table's INSERT statements
-		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(line)), "INSERT INTO") {
-			tableParts := strings.Split(line, "`")
-			if len(tableParts) >= 2 && tableParts[1] == selectedTable.Name {
-				inSelectedTable = true
-			} else {
-				inSelectedTable = false
-			}
-		}
+func (h *writerHandler) TableStart(table string) error {
+	if err := h.writer.WriteTableStart(table); err != nil {
+		return fmt.Errorf("error starting table: %v", err)
+	}
+	h.rowCount = 0
+	h.batchCount = 0
+	h.tableStartTime = time.Now()
+	slog.Debug("started table", "table", table)
+	h.progress.StartTable(table, h.input, h.from)
+	return nil
+}
 
-		// Only process INSERT statements for the selected table
-		if inSelectedTable {
-			currentStatement.WriteString(line)
-			currentStatement.WriteString(" ")
+func (h *writerHandler) Rows(table string, rows []models.Row) error {
+	if err := h.writer.WriteRows(rows); err != nil {
+		return fmt.Errorf("error writing rows: %v", err)
+	}
+	h.rowCount += len(rows)
+	h.batchCount++
+	h.progress.AddRows(len(rows))
+	return nil
+}
 
-			if strings.HasSuffix(strings.TrimSpace(line), ";") {
-				statementChan <- currentStatement.String()
-				currentStatement.Reset()
-			}
-		}
+func (h *writerHandler) TableEnd(table string) error {
+	h.progress.EndTable()
+	if err := h.writer.WriteTableEnd(); err != nil {
+		return err
 	}
+	elapsed := time.Since(h.tableStartTime)
+	slog.Info("finished table", "table", table, "rows", h.rowCount, "batches", h.batchCount,
+		"duration", elapsed, "rows_per_sec", int64(float64(h.rowCount)/elapsed.Seconds()))
+	return nil
+}
 
-	// Close channels and wait for completion
-	close(statementChan)
-	wg.Wait()
-	close(resultChan)
-	processWg.Wait()
+// statementJob is a statement handed to a worker, along with the schema of
+// its table if a CREATE TABLE statement has been seen.
+type statementJob struct {
+	seq       int
+	statement *Statement
+	table     string
+	create    bool // statement is the CREATE TABLE that produced schema
+	schema    *models.Schema
+	err       error // set if the reader already failed to parse the statement
+}
 
-	// Write any remaining rows in the last batch
-	if len(currentBatch) > 0 {
-		if err := writer.WriteRows(currentBatch); err != nil {
-			return err
-		}
-		batchCount++
-		fmt.Printf("Processed final batch %d for table %s (%d rows)\n", batchCount, currentTableName, len(currentBatch))
-		currentBatch = nil // Help GC
-	}
+// rejectedRow is a tuple that was dropped from an otherwise valid statement.
+type rejectedRow struct {
+	err *ParseError
+	raw string // single-row INSERT statement holding the tuple
+}
+
+type statementResult struct {
+	seq       int
+	statement *Statement
+	schema    *models.Schema // set for CREATE TABLE statements
+	tableName string
+	columns   []string
+	rows      []models.Row
+	rowErrors []rejectedRow
+	err       *ParseError
+}
 
-	// Close the last table if any
-	if currentTableName != "" {
-		if err := writer.WriteTableEnd(); err != nil {
-			return err
+func (job *statementJob) process(filename string, numWorkers int) *statementResult {
+	result := &statementResult{seq: job.seq, statement: job.statement}
+	err := job.err
+	if err == nil && job.create {
+		result.schema = job.schema
+		return result
+	}
+	if err == nil {
+		var rowErrs []*syntaxError
+		var ins *insert
+		ins, err = processStatement(job.statement.Text, job.schema, numWorkers)
+		if ins != nil {
+			result.tableName, result.columns, result.rows = ins.table, ins.columns, ins.rows
+			rowErrs = ins.rowErrors
 		}
-		tableDuration := time.Since(tableStartTime)
-		fmt.Printf("Finished processing table %s (total %d rows in %d batches) in %v\n",
-			currentTableName, rowCount, batchCount, tableDuration)
+		for _, se := range rowErrs {
+			perr := newParseError(job.statement, job.table, se)
+			perr.File = filename
+			result.rowErrors = append(result.rowErrors, rejectedRow{
+				err: perr,
+				raw: rowInsertStatement(job.statement.Text, se),
+			})
+		}
+	}
+	if err != nil {
+		result.err = newParseError(job.statement, job.table, err)
+		result.err.File = filename
 	}
+	return result
+}
 
-	totalDuration := time.Since(startTime)
-	fmt.Printf("\nProcessing Summary:\n")
-	fmt.Printf("File: %s\n", filename)
-	fmt.Printf("Table: %s\n", selectedTable.Name)
-	fmt.Printf("Total Statements: %d\n", totalStatements)
-	fmt.Printf("Total Duration: %v\n", totalDuration)
-	fmt.Printf("Average Time per Statement: %v\n", totalDuration/time.Duration(totalStatements))
-	fmt.Printf("Workers Used: %d\n", numWorkers)
+// rowInsertStatement builds an INSERT statement for the single tuple se
+// refers to, reusing the INSERT ... VALUES prefix of statement.
+func rowInsertStatement(statement string, se *syntaxError) string {
+	prefix := statement[:strings.Index(statement, "VALUES")+len("VALUES")]
+	return prefix + " " + statement[se.pos:se.end] + ";"
+}
 
-	return scanner.Err()
+// insert is a parsed INSERT statement.
+type insert struct {
+	table     string
+	columns   []string
+	rows      []models.Row
+	rowErrors []*syntaxError // tuples left out of rows
 }
 
-func processStatement(statement string, numWorkers int) (string, []models.Row, error) {
-	statement = strings.TrimSpace(strings.TrimSuffix(statement, ";"))
+// processStatement parses statement if it is an INSERT statement, and returns
+// nil otherwise.
+func processStatement(statement string, schema *models.Schema, numWorkers int) (*insert, error) {
+	statement = strings.TrimSuffix(strings.TrimRight(statement, " \t\r\n"), ";")
 
-	if strings.HasPrefix(strings.ToUpper(statement), "INSERT INTO") {
-		tableName, rows, err := parseInsert(statement, numWorkers)
+	if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(statement)), "INSERT INTO") {
+		ins, err := parseInsert(statement, schema, numWorkers)
 		if err != nil {
-			return "", nil, err
+			return nil, err
 		}
 		// Set the table name for each row
-		for i := range rows {
-			rows[i].TableName = tableName
+		for i := range ins.rows {
+			ins.rows[i].TableName = ins.table
 		}
-		return tableName, rows, nil
+		return ins, nil
 	}
-	return "", nil, nil
+	return nil, nil
 }
 
-func parseInsert(statement string, numWorkers int) (string, []models.Row, error) {
-	parts := strings.SplitN(statement, "VALUES", 2)
-	if len(parts) != 2 {
-		return "", nil, fmt.Errorf("invalid INSERT statement format")
+// parseInsert parses an INSERT statement. Errors are returned as syntaxErrors
+// positioned relative to the start of statement. Tuples whose value count
+// doesn't match the column list are left out of the rows and reported as
+// row errors instead. The column list is taken from schema when the statement
+// has none, and checked against it otherwise.
+func parseInsert(statement string, schema *models.Schema, numWorkers int) (*insert, error) {
+	valuesIdx := strings.Index(statement, "VALUES")
+	if valuesIdx < 0 {
+		return nil, &syntaxError{pos: len(statement), msg: "invalid INSERT statement format: missing VALUES"}
 	}
 
-	insertPart := strings.TrimSpace(parts[0])
+	insertPart := statement[:valuesIdx]
+	insertStart := len(insertPart) - len(strings.TrimLeft(insertPart, " \t\r\n"))
+	insertPart = strings.TrimSpace(insertPart)
 	if !strings.HasPrefix(strings.ToUpper(insertPart), "INSERT INTO") {
-		return "", nil, fmt.Errorf("invalid INSERT statement format")
+		return nil, &syntaxError{pos: insertStart, msg: "invalid INSERT statement format: expected INSERT INTO"}
 	}
 
+	var tableName string
+	var columns []string
 	tableParts := strings.SplitN(insertPart[11:], "(", 2)
-	if len(tableParts) != 2 {
-		return "", nil, fmt.Errorf("invalid INSERT statement format")
+	if len(tableParts) == 2 {
+		_, tableName = splitQualifiedName(tableParts[0])
+		columnsPart := strings.TrimRight(tableParts[1], ")")
+		columns = parseColumnList(columnsPart)
+		if schema != nil {
+			for _, col := range columns {
+				if schema.Column(col) == nil {
+					return nil, &syntaxError{
+						pos: insertStart + 11 + len(tableParts[0]),
+						msg: fmt.Sprintf("unknown column %q in table %s", col, tableName),
+					}
+				}
+			}
+		}
+	} else {
+		_, tableName = splitQualifiedName(tableParts[0])
+		if schema == nil {
+			return nil, &syntaxError{pos: insertStart + 11, msg: "invalid INSERT statement format: missing column list and no CREATE TABLE for the table"}
+		}
+		columns = schema.ColumnNames()
 	}
 
-	tableName := strings.Trim(strings.TrimSpace(tableParts[0]), "`")
-	columnsPart := strings.TrimRight(tableParts[1], ")")
-	columns := parseColumnList(columnsPart)
+	valuesStart := valuesIdx + len("VALUES")
+	values, spans, err := parseValuesList(statement[valuesStart:])
+	if err != nil {
+		if se, ok := err.(*syntaxError); ok {
+			se.pos += valuesStart
+		}
+		return nil, err
+	}
 
-	valuesPart := strings.TrimSpace(parts[1])
-	values := parseValuesList(valuesPart)
+	// Validate each tuple against the column list
+	var rowErrs []*syntaxError
+	for i, rowValues := range values {
+		if len(rowValues) != len(columns) {
+			rowErrs = append(rowErrs, &syntaxError{
+				pos: valuesStart + spans[i].start,
+				end: valuesStart + spans[i].end,
+				row: i + 1,
+				msg: fmt.Sprintf("column count mismatch: %d values for %d columns", len(rowValues), len(columns)),
+				err: ErrColumnCount,
+			})
+		}
+	}
+	if len(rowErrs) > 0 {
+		valid := make([][]interface{}, 0, len(values)-len(rowErrs))
+		for _, rowValues := range values {
+			if len(rowValues) == len(columns) {
+				valid = append(valid, rowValues)
+			}
+		}
+		values = valid
+	}
 
 	// Process rows in parallel if we have enough rows
+	ins := &insert{table: tableName, columns: columns, rowErrors: rowErrs}
 	if len(values) > 1000 {
-		return parseRowsParallel(tableName, columns, values, numWorkers)
+		_, ins.rows, err = parseRowsParallel(tableName, columns, values, numWorkers)
+	} else {
+		_, ins.rows, err = parseRowsSequential(tableName, columns, values)
 	}
+	if err != nil {
+		return nil, err
+	}
+	return ins, nil
+}
 
-	return parseRowsSequential(tableName, columns, values)
+// insertTableName returns the backquoted table name of an INSERT statement
+// line, or "" if line does not start an INSERT statement.
+func insertTableName(line string) string {
+	_, table := insertTable(line)
+	return table
+}
+
+// insertTable is like insertTableName but also returns the database the
+// table name is qualified with, if any.
+func insertTable(line string) (db, table string) {
+	if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(line)), "INSERT INTO") {
+		return "", ""
+	}
+	start := strings.IndexByte(line, '`')
+	if start < 0 {
+		return "", ""
+	}
+	end := strings.IndexByte(line[start+1:], '`')
+	if end < 0 {
+		return "", ""
+	}
+	table = line[start+1 : start+1+end]
+
+	// `db`.`table`
+	rest := line[start+end+2:]
+	if strings.HasPrefix(rest, ".`") {
+		if end := strings.IndexByte(rest[2:], '`'); end >= 0 {
+			return table, rest[2 : 2+end]
+		}
+	}
+	return "", table
+}
+
+// insertColumns returns the column list of an INSERT statement, or nil if
+// it has none.
+func insertColumns(statement string) []string {
+	valuesIdx := strings.Index(statement, "VALUES")
+	if valuesIdx < 0 {
+		return nil
+	}
+	tableParts := strings.SplitN(statement[:valuesIdx], "(", 2)
+	if len(tableParts) < 2 {
+		return nil
+	}
+	return parseColumnList(strings.TrimRight(strings.TrimSpace(tableParts[1]), ")"))
 }
 
 func parseColumnList(columnsPart string) []string {
@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"sqlparser/pkg/models"
+)
+
+func TestColumnCountRowErrors(t *testing.T) {
+	schema := &models.Schema{TableName: "t", Columns: []models.Column{{Name: "a"}, {Name: "b"}}}
+	tests := []struct {
+		name      string
+		statement string
+		schema    *models.Schema
+		rows      []string // values of a
+		rowErrors []int    // rows
+		rejects   []string // single-row statements of the rejected tuples
+	}{
+		{
+			name:      "all tuples match",
+			statement: "INSERT INTO `t` VALUES (1,'x'),(2,'y')",
+			schema:    schema,
+			rows:      []string{"1", "2"},
+		},
+		{
+			name:      "too few and too many values",
+			statement: "INSERT INTO `t` VALUES (1,'x'),(2),(3,'z','extra'),(4,'w')",
+			schema:    schema,
+			rows:      []string{"1", "4"},
+			rowErrors: []int{2, 3},
+			rejects:   []string{"INSERT INTO `t` VALUES (2);", "INSERT INTO `t` VALUES (3,'z','extra');"},
+		},
+		{
+			name:      "column list of the statement",
+			statement: "INSERT INTO `t` (`a`) VALUES (1),(2,'y')",
+			rows:      []string{"1"},
+			rowErrors: []int{2},
+			rejects:   []string{"INSERT INTO `t` (`a`) VALUES (2,'y');"},
+		},
+		{
+			name:      "every tuple rejected",
+			statement: "INSERT INTO `t` VALUES (1),(2)",
+			schema:    schema,
+			rowErrors: []int{1, 2},
+			rejects:   []string{"INSERT INTO `t` VALUES (1);", "INSERT INTO `t` VALUES (2);"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ins, err := parseInsert(tt.statement, tt.schema, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rows []string
+			for _, row := range ins.rows {
+				rows = append(rows, row.Data["a"].(string))
+			}
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("got rows %v, want %v", rows, tt.rows)
+			}
+
+			var rowErrors []int
+			var rejects []string
+			for _, se := range ins.rowErrors {
+				if !errors.Is(se, ErrColumnCount) {
+					t.Errorf("row error %v isn't ErrColumnCount", se)
+				}
+				rowErrors = append(rowErrors, se.row)
+				rejects = append(rejects, rowInsertStatement(tt.statement, se))
+			}
+			if !reflect.DeepEqual(rowErrors, tt.rowErrors) {
+				t.Errorf("got row errors for %v, want %v", rowErrors, tt.rowErrors)
+			}
+			if !reflect.DeepEqual(rejects, tt.rejects) {
+				t.Errorf("got rejects %q, want %q", rejects, tt.rejects)
+			}
+		})
+	}
+}
+
+func TestColumnCountErrorMessage(t *testing.T) {
+	ins, err := parseInsert("INSERT INTO `t` (`a`, `b`) VALUES (1,'x'),(2)", nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ins.rowErrors) != 1 {
+		t.Fatalf("got %d row errors, want 1", len(ins.rowErrors))
+	}
+	se := ins.rowErrors[0]
+	if want := "column count mismatch: 1 values for 2 columns"; se.msg != want {
+		t.Errorf("message = %q, want %q", se.msg, want)
+	}
+	// The error points at the tuple
+	if se.pos != 42 || se.end != 45 {
+		t.Errorf("tuple at %d-%d, want 42-45", se.pos, se.end)
+	}
+}
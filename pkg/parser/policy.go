@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// ErrorPolicy decides what happens to statements that fail to parse.
+type ErrorPolicy string
+
+const (
+	ErrorPolicyFail       ErrorPolicy = "fail"       // abort on the first error
+	ErrorPolicySkip       ErrorPolicy = "skip"       // report the error and drop the statement
+	ErrorPolicyQuarantine ErrorPolicy = "quarantine" // like skip, but keep the statement in a rejects file
+)
+
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	switch policy := ErrorPolicy(s); policy {
+	case ErrorPolicyFail, ErrorPolicySkip, ErrorPolicyQuarantine:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown error policy %q (want fail, skip or quarantine)", s)
+	}
+}
+
+// ErrorHandler applies an ErrorPolicy to rejected statements and counts what
+// was rejected. A single handler can be shared by several runs over the same
+// file to get totals across tables.
+type ErrorHandler struct {
+	Policy ErrorPolicy
+
+	RejectedStatements int // statements that failed as a whole
+	RejectedRows       int // rows in rejected statements plus individually rejected rows
+
+	// OnReject, if set, is called with every rejected statement and row
+	// instead of logging it.
+	OnReject func(err *ParseError)
+
+	rejects *bufio.Writer
+}
+
+// NewErrorHandler creates a handler for policy. rejects receives the raw
+// offending statements under ErrorPolicyQuarantine and may be nil otherwise.
+func NewErrorHandler(policy ErrorPolicy, rejects io.Writer) *ErrorHandler {
+	h := &ErrorHandler{Policy: policy}
+	if rejects != nil {
+		h.rejects = bufio.NewWriter(rejects)
+	}
+	return h
+}
+
+// Handle records input that was rejected with err. raw is the offending SQL,
+// which is kept under ErrorPolicyQuarantine, and rows the number of rows it
+// held. Handle returns err if processing should stop, and nil if the input
+// was dropped.
+func (h *ErrorHandler) Handle(err *ParseError, raw string, rows int) error {
+	if err.Row == 0 {
+		h.RejectedStatements++
+	}
+	h.RejectedRows += rows
+
+	switch h.Policy {
+	case ErrorPolicyFail:
+		return err
+	case ErrorPolicyQuarantine:
+		if h.rejects == nil {
+			return fmt.Errorf("quarantine policy requires a rejects file")
+		}
+		if _, werr := fmt.Fprintf(h.rejects, "-- %s\n%s\n", strings.ReplaceAll(err.Error(), "\n", " "), raw); werr != nil {
+			return fmt.Errorf("error writing rejects file: %v", werr)
+		}
+	}
+
+	if h.OnReject != nil {
+		h.OnReject(err)
+	} else if err.Row > 0 {
+		slog.Warn("rejected row", "error", err)
+	} else {
+		slog.Warn("rejected statement", "error", err)
+	}
+	return nil
+}
+
+// Flush writes any buffered rejected statements.
+func (h *ErrorHandler) Flush() error {
+	if h.rejects == nil {
+		return nil
+	}
+	return h.rejects.Flush()
+}
+
+// Rejected reports whether any statement was rejected.
+func (h *ErrorHandler) Rejected() bool {
+	return h.RejectedStatements > 0 || h.RejectedRows > 0
+}
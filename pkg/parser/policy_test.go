@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"sqlparser/pkg/models"
+)
+
+// brokenDump has a statement that fails as a whole and a tuple with too few
+// values among valid rows.
+const brokenDump = "CREATE TABLE `t` (\n  `a` int,\n  `b` text\n);\n" +
+	"INSERT INTO `t` VALUES (1,'x');\n" +
+	"INSERT INTO `t` VALUES (2,'y'),(3,'z';\n" +
+	"INSERT INTO `t` VALUES (4,'w'),(5),(6,'v');\n"
+
+func TestErrorPolicies(t *testing.T) {
+	tests := []struct {
+		policy     ErrorPolicy
+		rows       []string // values of a
+		err        string
+		statements int // rejected
+		rejected   int // rows
+		rejects    string
+	}{
+		{
+			policy:     ErrorPolicyFail,
+			rows:       []string{"1"},
+			err:        "d.sql:6:32: statement 3 (table t): unterminated tuple (byte offset 107)",
+			statements: 1,
+			rejected:   2,
+		},
+		{
+			policy:     ErrorPolicySkip,
+			rows:       []string{"1", "4", "6"},
+			statements: 1,
+			rejected:   3,
+		},
+		{
+			policy:     ErrorPolicyQuarantine,
+			rows:       []string{"1", "4", "6"},
+			statements: 1,
+			rejected:   3,
+			rejects: "-- d.sql:6:32: statement 3 (table t): unterminated tuple (byte offset 107)\n" +
+				"INSERT INTO `t` VALUES (2,'y'),(3,'z';\n" +
+				"-- d.sql:7:32: statement 4 (table t) row 2: column count mismatch: 1 values for 2 columns (byte offset 146)\n" +
+				"INSERT INTO `t` VALUES (5);\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			var rejects bytes.Buffer
+			errs := NewErrorHandler(tt.policy, &rejects)
+			errs.OnReject = func(*ParseError) {}
+			var rows []string
+			cfg := Config{File: "d.sql", Errors: errs}
+			_, err := Stream(context.Background(), strings.NewReader(brokenDump), cfg, HandlerFuncs{
+				OnRows: func(_ string, batch []models.Row) error {
+					for _, row := range batch {
+						rows = append(rows, row.Data["a"].(string))
+					}
+					return nil
+				},
+			})
+			if ferr := errs.Flush(); ferr != nil {
+				t.Fatal(ferr)
+			}
+
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+			if strings.Join(rows, ",") != strings.Join(tt.rows, ",") {
+				t.Errorf("got rows %v, want %v", rows, tt.rows)
+			}
+			if errs.RejectedStatements != tt.statements || errs.RejectedRows != tt.rejected {
+				t.Errorf("rejected %d statements and %d rows, want %d and %d",
+					errs.RejectedStatements, errs.RejectedRows, tt.statements, tt.rejected)
+			}
+			if rejects.String() != tt.rejects {
+				t.Errorf("rejects file:\n%s\nwant:\n%s", rejects.String(), tt.rejects)
+			}
+		})
+	}
+}
+
+func TestQuarantineNeedsRejectsFile(t *testing.T) {
+	errs := NewErrorHandler(ErrorPolicyQuarantine, nil)
+	err := errs.Handle(&ParseError{Line: 1, Err: errors.New("bad")}, "x;", 1)
+	if err == nil {
+		t.Error("quarantining without a rejects file succeeded")
+	}
+}
+
+func TestParseErrorPolicy(t *testing.T) {
+	for _, s := range []string{"fail", "skip", "quarantine"} {
+		if policy, err := ParseErrorPolicy(s); err != nil || string(policy) != s {
+			t.Errorf("ParseErrorPolicy(%q) = %q, %v", s, policy, err)
+		}
+	}
+	if _, err := ParseErrorPolicy("ignore"); err == nil {
+		t.Error("ParseErrorPolicy accepted ignore")
+	}
+}
@@ -13,19 +13,13 @@ var rowDataPool = sync.Pool{
 	},
 }
 
-func parseRowsSequential(tableName string, columns []string, values [][]string) (string, []models.Row, error) {
+func parseRowsSequential(tableName string, columns []string, values [][]interface{}) (string, []models.Row, error) {
 	rows := make([]models.Row, len(values))
 	for i, rowValues := range values {
 		rowData := rowDataPool.Get().(map[string]interface{})
 		for j, value := range rowValues {
 			if j < len(columns) {
-				if value == "NULL" {
-					rowData[columns[j]] = nil
-				} else if value == "" {
-					rowData[columns[j]] = ""
-				} else {
-					rowData[columns[j]] = value
-				}
+				rowData[columns[j]] = value
 			}
 		}
 		rows[i] = models.Row{
@@ -36,7 +30,7 @@ func parseRowsSequential(tableName string, columns []string, values [][]string)
 	return tableName, rows, nil
 }
 
-func parseRowsParallel(tableName string, columns []string, values [][]string, numWorkers int) (string, []models.Row, error) {
+func parseRowsParallel(tableName string, columns []string, values [][]interface{}, numWorkers int) (string, []models.Row, error) {
 	rowsPerWorker := (len(values) + numWorkers - 1) / numWorkers
 
 	rows := make([]models.Row, len(values))
@@ -61,13 +55,7 @@ func parseRowsParallel(tableName string, columns []string, values [][]string, nu
 				rowData := rowDataPool.Get().(map[string]interface{})
 				for j, value := range values[idx] {
 					if j < len(columns) {
-						if value == "NULL" {
-							rowData[columns[j]] = nil
-						} else if value == "" {
-							rowData[columns[j]] = ""
-						} else {
-							rowData[columns[j]] = value
-						}
+						rowData[columns[j]] = value
 					}
 				}
 				rows[idx] = models.Row{
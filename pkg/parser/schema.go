@@ -0,0 +1,195 @@
+package parser
+
+import (
+	"strings"
+
+	"sqlparser/pkg/models"
+)
+
+// createTableName returns the table name of a CREATE TABLE statement line, or
+// "" if line does not start a CREATE TABLE statement.
+func createTableName(line string) string {
+	_, table := createTable(line)
+	return table
+}
+
+// createTable is like createTableName but also returns the database the
+// table name is qualified with, if any.
+func createTable(line string) (db, table string) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(strings.ToUpper(trimmed), "CREATE TABLE") {
+		return "", ""
+	}
+	rest := strings.TrimSpace(trimmed[len("CREATE TABLE"):])
+	if strings.HasPrefix(strings.ToUpper(rest), "IF NOT EXISTS") {
+		rest = strings.TrimSpace(rest[len("IF NOT EXISTS"):])
+	}
+	end := strings.IndexAny(rest, " (")
+	if end < 0 {
+		end = len(rest)
+	}
+	return splitQualifiedName(rest[:end])
+}
+
+// splitQualifiedName splits a table name that may be qualified with a
+// database, such as `db`.`table`, and removes the quotes.
+func splitQualifiedName(name string) (db, table string) {
+	name = strings.TrimSpace(name)
+	quoted := false
+	for i := len(name) - 1; i >= 0; i-- {
+		switch name[i] {
+		case '`':
+			quoted = !quoted
+		case '.':
+			if !quoted {
+				return unquoteIdentifier(name[:i]), unquoteIdentifier(name[i+1:])
+			}
+		}
+	}
+	return "", unquoteIdentifier(name)
+}
+
+// useDatabase returns the database selected by a USE statement line, or ""
+// if line is not a USE statement.
+func useDatabase(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if len(trimmed) < 4 || !strings.EqualFold(trimmed[:4], "USE ") {
+		return ""
+	}
+	return unquoteIdentifier(strings.TrimSpace(strings.TrimSuffix(trimmed[4:], ";")))
+}
+
+// parseCreateTable extracts the columns and primary key of a CREATE TABLE
+// statement. Index, constraint and table options are ignored.
+func parseCreateTable(statement string) (*models.Schema, error) {
+	tableName := createTableName(statement)
+	if tableName == "" {
+		return nil, &syntaxError{pos: 0, msg: "invalid CREATE TABLE statement format"}
+	}
+
+	open := strings.IndexByte(statement, '(')
+	closing := strings.LastIndexByte(statement, ')')
+	if open < 0 || closing < open {
+		return nil, &syntaxError{pos: len(statement), msg: "invalid CREATE TABLE statement format: missing column definitions"}
+	}
+
+	schema := &models.Schema{TableName: tableName}
+	for _, def := range splitDefinitions(statement[open+1 : closing]) {
+		upper := strings.ToUpper(def)
+		if strings.HasPrefix(upper, "PRIMARY KEY") {
+			keyDef := def[len("PRIMARY KEY"):]
+			if open, closing := strings.IndexByte(keyDef, '('), strings.IndexByte(keyDef, ')'); open >= 0 && closing > open {
+				keyDef = keyDef[open+1 : closing]
+			}
+			schema.PrimaryKey = parseColumnList(keyDef)
+			continue
+		}
+		if isIndexDefinition(upper) {
+			// Indexes and constraints don't affect the row layout
+			continue
+		}
+
+		col, primary := parseColumnDefinition(def)
+		schema.Columns = append(schema.Columns, col)
+		if primary {
+			schema.PrimaryKey = []string{col.Name}
+		}
+	}
+
+	if len(schema.Columns) == 0 {
+		return nil, &syntaxError{pos: open, msg: "invalid CREATE TABLE statement format: no columns"}
+	}
+	return schema, nil
+}
+
+// parseColumnDefinition parses "`name` type [attributes]" and reports whether
+// the column is declared inline as the primary key.
+func parseColumnDefinition(def string) (models.Column, bool) {
+	var name, rest string
+	if q := def[0]; q == '`' || q == '"' {
+		end := strings.IndexByte(def[1:], q)
+		if end < 0 {
+			end = len(def) - 1
+		}
+		name, rest = def[1:end+1], strings.TrimSpace(def[end+2:])
+	} else {
+		fields := strings.SplitN(def, " ", 2)
+		name = fields[0]
+		if len(fields) == 2 {
+			rest = strings.TrimSpace(fields[1])
+		}
+	}
+
+	// The type runs up to the first space outside of parentheses, followed by
+	// any numeric modifiers
+	typeEnd, depth := len(rest), 0
+	for i := 0; i < len(rest); i++ {
+		if rest[i] == '(' {
+			depth++
+		} else if rest[i] == ')' {
+			depth--
+		} else if rest[i] == ' ' && depth == 0 {
+			typeEnd = i
+			break
+		}
+	}
+	colType := strings.ToLower(rest[:typeEnd])
+	attributes := strings.ToUpper(rest[typeEnd:])
+	for _, modifier := range []string{" UNSIGNED", " ZEROFILL"} {
+		if strings.HasPrefix(attributes, modifier) {
+			colType += strings.ToLower(modifier)
+			attributes = attributes[len(modifier):]
+		}
+	}
+
+	primary := strings.Contains(attributes, "PRIMARY KEY")
+	return models.Column{
+		Name:     name,
+		Type:     colType,
+		Nullable: !strings.Contains(attributes, "NOT NULL") && !primary,
+	}, primary
+}
+
+func isIndexDefinition(upper string) bool {
+	for _, prefix := range []string{"KEY ", "UNIQUE ", "INDEX ", "FULLTEXT ", "SPATIAL ", "CONSTRAINT ", "FOREIGN KEY", "CHECK "} {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitDefinitions splits the body of a CREATE TABLE statement on the commas
+// that separate column and index definitions.
+func splitDefinitions(body string) []string {
+	var defs []string
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case quote != 0:
+			if c == quote && body[i-1] != '\\' {
+				quote = 0
+			}
+		case c == '\'' || c == '`' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			defs = append(defs, strings.TrimSpace(body[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(body[start:]); last != "" {
+		defs = append(defs, last)
+	}
+	return defs
+}
+
+func unquoteIdentifier(name string) string {
+	return strings.Trim(name, "`\"")
+}
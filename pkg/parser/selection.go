@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// TableFilter selects tables by name, for running without the interactive
+// prompt. Patterns are globs ("user*", "log_202?") or regular expressions
+// between slashes ("/^tmp_/"). A glob containing a dot ("shop.*") is matched
+// against the table name qualified with its database; a regular expression is
+// matched against both the plain and the qualified name.
+type TableFilter struct {
+	include []tablePattern
+	exclude []tablePattern
+}
+
+type tablePattern struct {
+	text string
+	glob string
+	re   *regexp.Regexp
+}
+
+// ParseTableFilter parses comma-separated lists of patterns for the tables to
+// include and the tables to exclude. An empty include list includes every
+// table that isn't excluded.
+func ParseTableFilter(include, exclude string) (*TableFilter, error) {
+	f := &TableFilter{}
+	var err error
+	if f.include, err = parseTablePatterns(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = parseTablePatterns(exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// IsEmpty reports whether the filter has no patterns at all.
+func (f *TableFilter) IsEmpty() bool {
+	return len(f.include) == 0 && len(f.exclude) == 0
+}
+
+// Match reports whether table is selected by the filter.
+func (f *TableFilter) Match(table TableInfo) bool {
+	if len(f.include) > 0 && !matchAny(f.include, table) {
+		return false
+	}
+	return !matchAny(f.exclude, table)
+}
+
+// Select returns the tables selected by the filter. Include patterns that
+// match no table are reported as an error, since they usually are typos.
+func (f *TableFilter) Select(tables []TableInfo) ([]*TableInfo, error) {
+	for _, p := range f.include {
+		found := false
+		for _, table := range tables {
+			if p.match(table) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no table matches %q", p.text)
+		}
+	}
+
+	var selected []*TableInfo
+	for i := range tables {
+		if f.Match(tables[i]) {
+			selected = append(selected, &tables[i])
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no tables selected")
+	}
+	return selected, nil
+}
+
+func matchAny(patterns []tablePattern, table TableInfo) bool {
+	for _, p := range patterns {
+		if p.match(table) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p tablePattern) match(table TableInfo) bool {
+	qualified := table.Name
+	if table.Database != "" {
+		qualified = table.Database + "." + table.Name
+	}
+	if p.re != nil {
+		return p.re.MatchString(table.Name) || p.re.MatchString(qualified)
+	}
+	name := table.Name
+	if strings.Contains(p.glob, ".") {
+		name = qualified
+	}
+	ok, _ := path.Match(p.glob, name)
+	return ok
+}
+
+// parseTablePatterns splits a comma-separated list of patterns. Commas inside
+// a regular expression don't separate patterns.
+func parseTablePatterns(list string) ([]tablePattern, error) {
+	var patterns []tablePattern
+	for _, text := range splitPatternList(list) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if len(text) >= 2 && text[0] == '/' && text[len(text)-1] == '/' {
+			re, err := regexp.Compile(text[1 : len(text)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid table pattern %s: %v", text, err)
+			}
+			patterns = append(patterns, tablePattern{text: text, re: re})
+			continue
+		}
+		if _, err := path.Match(text, ""); err != nil {
+			return nil, fmt.Errorf("invalid table pattern %s: %v", text, err)
+		}
+		patterns = append(patterns, tablePattern{text: text, glob: text})
+	}
+	return patterns, nil
+}
+
+func splitPatternList(list string) []string {
+	var parts []string
+	start, inRegexp := 0, false
+	for i := 0; i < len(list); i++ {
+		switch list[i] {
+		case '/':
+			// A regular expression starts with a slash at the start of an
+			// item and ends with the next unescaped slash
+			if !inRegexp && strings.TrimSpace(list[start:i]) == "" {
+				inRegexp = true
+			} else if inRegexp && list[i-1] != '\\' {
+				inRegexp = false
+			}
+		case ',':
+			if !inRegexp {
+				parts = append(parts, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, list[start:])
+}
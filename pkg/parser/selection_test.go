@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+var selectionTables = []TableInfo{
+	{Name: "users", Database: "shop"},
+	{Name: "orders", Database: "shop"},
+	{Name: "log_2023", Database: "shop"},
+	{Name: "log_2024"},
+	{Name: "tmp_users", Database: "stage"},
+	{Name: "users", Database: "stage"},
+}
+
+func selectedNames(tables []*TableInfo) string {
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = table.Name
+		if table.Database != "" {
+			names[i] = table.Database + "." + table.Name
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+func TestTableFilter(t *testing.T) {
+	tests := []struct {
+		name             string
+		include, exclude string
+		want             string
+		err              string
+	}{
+		{name: "everything", want: "shop.users shop.orders shop.log_2023 log_2024 stage.tmp_users stage.users"},
+		{name: "names", include: "users, orders", want: "shop.users shop.orders stage.users"},
+		{name: "glob", include: "log_202?", want: "shop.log_2023 log_2024"},
+		{name: "qualified glob", include: "shop.*", want: "shop.users shop.orders shop.log_2023"},
+		{name: "qualified name", include: "stage.users", want: "stage.users"},
+		{name: "regular expression", include: "/^tmp_|_2024$/", want: "log_2024 stage.tmp_users"},
+		{name: "regular expression on the qualified name", include: "/^stage\\./", want: "stage.tmp_users stage.users"},
+		{name: "comma in a regular expression", include: "/^log_20{1,2}2[34]$/,orders", want: "shop.orders shop.log_2023 log_2024"},
+		{name: "exclude", exclude: "log_*,/users/", want: "shop.orders"},
+		{name: "include and exclude", include: "shop.*", exclude: "users", want: "shop.orders shop.log_2023"},
+		{name: "include matching nothing", include: "users,usres", err: `no table matches "usres"`},
+		{name: "everything excluded", exclude: "*", err: "no tables selected"},
+		{name: "invalid glob", include: "log_[", err: "invalid table pattern log_["},
+		{name: "invalid regular expression", exclude: "/(/", err: "invalid table pattern /(/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseTableFilter(tt.include, tt.exclude)
+			var selected []*TableInfo
+			if err == nil {
+				selected, err = f.Select(selectionTables)
+			}
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := selectedNames(selected); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if f.IsEmpty() != (tt.include == "" && tt.exclude == "") {
+				t.Errorf("IsEmpty = %v", f.IsEmpty())
+			}
+		})
+	}
+}
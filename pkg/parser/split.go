@@ -0,0 +1,310 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SplitFile describes a file written by Split.
+type SplitFile struct {
+	Table      string `json:"table"`
+	Database   string `json:"database,omitempty"`
+	Path       string `json:"path"`
+	Statements int    `json:"statements"` // statements of the table, without the header
+	Bytes      int64  `json:"bytes"`
+}
+
+const splitBufferSize = 256 * 1024
+
+// conditionalComment matches the start of a MySQL versioned comment such as
+// "/*!40000 ALTER TABLE ...".
+var conditionalComment = regexp.MustCompile(`^/\*!\d*\s*`)
+
+// tableStatementPatterns match statements other than CREATE TABLE and INSERT
+// that belong to a table, capturing its name.
+var tableStatementPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?([^\s,;]+)`),
+	regexp.MustCompile(`(?i)^LOCK\s+TABLES\s+([^\s,;]+)`),
+	regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+(?:ONLY\s+)?([^\s,;]+)`),
+	regexp.MustCompile(`(?i)^TRUNCATE\s+(?:TABLE\s+)?([^\s,;]+)`),
+}
+
+// statementTable returns the table a statement of a dump belongs to, or ""
+// for statements that don't name a table.
+func statementTable(text string) (db, table string) {
+	text = conditionalComment.ReplaceAllString(strings.TrimSpace(text), "")
+	if db, table := createTable(text); table != "" {
+		return db, table
+	}
+	if db, table := insertTable(text); table != "" {
+		return db, table
+	}
+	for _, p := range tableStatementPatterns {
+		if m := p.FindStringSubmatch(text); m != nil {
+			return splitQualifiedName(m[1])
+		}
+	}
+	return "", ""
+}
+
+// isSessionStatement reports whether a statement sets session state, like
+// the SET statements that open and close mysqldump output.
+func isSessionStatement(text string) bool {
+	text = conditionalComment.ReplaceAllString(strings.TrimSpace(text), "")
+	upper := strings.ToUpper(text)
+	return strings.HasPrefix(upper, "SET ") || strings.HasPrefix(upper, "SET\t")
+}
+
+// Split writes the statements of every table selected by filter to its own
+// file in dir, named after the table, so that tables can be restored one by
+// one. Each file starts with the session SET statements of the dump's header
+// and the USE statement in effect, and ends with the SET statements of its
+// footer. filter may be nil to split every table.
+//
+// Statements that don't name a table, such as the SET statements mysqldump
+// puts around CREATE TABLE, go to the file of the table that follows them;
+// UNLOCK TABLES goes to the file of the table before it.
+func Split(filename, dir string, filter *TableFilter) ([]SplitFile, error) {
+	file, err := OpenInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	s := &splitter{dir: dir, filter: filter, files: make(map[string]*splitOutput)}
+	defer s.close()
+
+	scanner := NewStatementScanner(file)
+	seenTable := false
+	for scanner.Scan() {
+		stmt := scanner.Statement()
+		if db := useDatabase(stmt.Text); db != "" {
+			s.database = db
+			continue
+		}
+
+		db, table := statementTable(stmt.Text)
+		if table == "" {
+			trimmed := strings.ToUpper(strings.TrimSpace(stmt.Text))
+			switch {
+			case !seenTable:
+				if isSessionStatement(stmt.Text) {
+					s.header = append(s.header, stmt.Text)
+				}
+			case strings.HasPrefix(trimmed, "UNLOCK TABLES") && s.current != nil:
+				if err := s.current.write(stmt.Text); err != nil {
+					return nil, err
+				}
+			default:
+				s.pending = append(s.pending, stmt.Text)
+			}
+			continue
+		}
+
+		seenTable = true
+		if db == "" {
+			db = s.database
+		}
+		if err := s.write(db, table, stmt.Text); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning file: %v", err)
+	}
+
+	// What follows the last table is the footer
+	var footer []string
+	for _, text := range s.pending {
+		if isSessionStatement(text) {
+			footer = append(footer, text)
+		}
+	}
+	return s.finish(footer)
+}
+
+type splitter struct {
+	dir      string
+	filter   *TableFilter
+	header   []string
+	database string   // database of the last USE statement
+	pending  []string // statements waiting for the next table
+	files    map[string]*splitOutput
+	order    []*splitOutput
+	names    map[string]bool // file names in use
+	current  *splitOutput    // table the last statement belonged to
+}
+
+type splitOutput struct {
+	info SplitFile
+	file *os.File
+	w    *bufio.Writer
+	skip bool // the table is filtered out
+}
+
+func (o *splitOutput) write(text string) error {
+	if o.skip {
+		return nil
+	}
+	o.info.Statements++
+	return o.writeRaw(text)
+}
+
+func (o *splitOutput) writeRaw(text string) error {
+	if o.skip {
+		return nil
+	}
+	n, err := o.w.WriteString(text + "\n")
+	o.info.Bytes += int64(n)
+	return err
+}
+
+func (s *splitter) write(db, table, text string) error {
+	key := db + "." + table
+	out, ok := s.files[key]
+	if !ok {
+		var err error
+		if out, err = s.create(db, table); err != nil {
+			return err
+		}
+		s.files[key] = out
+	}
+
+	if s.current != out {
+		// Only one file is kept open, since dumps can have many tables
+		if err := s.closeCurrent(); err != nil {
+			return err
+		}
+		if err := s.open(out); err != nil {
+			return err
+		}
+		s.current = out
+	}
+
+	for _, pending := range s.pending {
+		if err := out.write(pending); err != nil {
+			return err
+		}
+	}
+	s.pending = s.pending[:0]
+	return out.write(text)
+}
+
+func (s *splitter) create(db, table string) (*splitOutput, error) {
+	out := &splitOutput{info: SplitFile{Table: table, Database: db}}
+	if s.filter != nil && !s.filter.Match(TableInfo{Name: table, Database: db}) {
+		out.skip = true
+		return out, nil
+	}
+	s.order = append(s.order, out)
+
+	// Tables of the same name in different databases get the database
+	// as a prefix
+	if s.names == nil {
+		s.names = make(map[string]bool)
+	}
+	name := table + ".sql"
+	if s.names[name] && db != "" {
+		name = db + "." + table + ".sql"
+	}
+	for n := 2; s.names[name]; n++ {
+		name = fmt.Sprintf("%s.%d.sql", table, n)
+	}
+	s.names[name] = true
+	out.info.Path = filepath.Join(s.dir, name)
+
+	file, err := os.Create(out.info.Path)
+	if err != nil {
+		return nil, err
+	}
+	out.file, out.w = file, bufio.NewWriterSize(file, splitBufferSize)
+	for _, text := range s.header {
+		if err := out.writeRaw(text); err != nil {
+			return nil, err
+		}
+	}
+	if db != "" {
+		if err := out.writeRaw("USE " + quoteIdentifier(db) + ";"); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// open reopens the file of a table that was written to before.
+func (s *splitter) open(out *splitOutput) error {
+	if out.skip || out.file != nil {
+		return nil
+	}
+	file, err := os.OpenFile(out.info.Path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	out.file, out.w = file, bufio.NewWriterSize(file, splitBufferSize)
+	return nil
+}
+
+func (s *splitter) closeCurrent() error {
+	if s.current == nil {
+		return nil
+	}
+	err := s.current.close()
+	s.current = nil
+	return err
+}
+
+func (o *splitOutput) close() error {
+	if o.file == nil {
+		return nil
+	}
+	err := o.w.Flush()
+	if cerr := o.file.Close(); err == nil {
+		err = cerr
+	}
+	o.file, o.w = nil, nil
+	return err
+}
+
+// finish appends the footer to every file and closes them.
+func (s *splitter) finish(footer []string) ([]SplitFile, error) {
+	if err := s.closeCurrent(); err != nil {
+		return nil, err
+	}
+	files := make([]SplitFile, 0, len(s.order))
+	for _, out := range s.order {
+		if len(footer) > 0 {
+			if err := s.open(out); err != nil {
+				return nil, err
+			}
+			for _, text := range footer {
+				if err := out.writeRaw(text); err != nil {
+					return nil, err
+				}
+			}
+			if err := out.close(); err != nil {
+				return nil, err
+			}
+		}
+		files = append(files, out.info)
+	}
+	return files, nil
+}
+
+// close releases the open file after an error.
+func (s *splitter) close() {
+	for _, out := range s.order {
+		out.close()
+	}
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
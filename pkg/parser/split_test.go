@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const splitDump = "-- MySQL dump\n" +
+	"/*!40101 SET NAMES utf8mb4 */;\n" +
+	"SET FOREIGN_KEY_CHECKS=0;\n" +
+	"DROP TABLE IF EXISTS `a`;\n" +
+	"/*!40101 SET character_set_client = utf8 */;\n" +
+	"CREATE TABLE `a` (\n  `id` int\n);\n" +
+	"LOCK TABLES `a` WRITE;\n" +
+	"INSERT INTO `a` VALUES (1);\n" +
+	"UNLOCK TABLES;\n" +
+	"USE `other`;\n" +
+	"CREATE TABLE `a` (\n  `id` int\n);\n" +
+	"INSERT INTO `a` VALUES (2);\n" +
+	"CREATE TABLE `b` (\n  `id` int\n);\n" +
+	"INSERT INTO `b` VALUES (3);\n" +
+	"INSERT INTO `other`.`a` VALUES (4);\n" +
+	"SET FOREIGN_KEY_CHECKS=1;\n" +
+	"-- Dump completed\n"
+
+const (
+	splitHeader = "/*!40101 SET NAMES utf8mb4 */;\nSET FOREIGN_KEY_CHECKS=0;\n"
+	splitFooter = "SET FOREIGN_KEY_CHECKS=1;\n"
+	createA     = "CREATE TABLE `a` (\n  `id` int\n);\n"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		include string
+		exclude string
+		want    []SplitFile // with the path relative to the output directory, without the size
+		files   map[string]string
+	}{
+		{
+			name: "every table",
+			want: []SplitFile{
+				{Table: "a", Path: "a.sql", Statements: 6},
+				{Table: "a", Database: "other", Path: "other.a.sql", Statements: 3},
+				{Table: "b", Database: "other", Path: "b.sql", Statements: 2},
+			},
+			files: map[string]string{
+				"a.sql": splitHeader +
+					"DROP TABLE IF EXISTS `a`;\n" +
+					"/*!40101 SET character_set_client = utf8 */;\n" +
+					createA +
+					"LOCK TABLES `a` WRITE;\n" +
+					"INSERT INTO `a` VALUES (1);\n" +
+					"UNLOCK TABLES;\n" +
+					splitFooter,
+				"other.a.sql": splitHeader + "USE `other`;\n" + createA +
+					"INSERT INTO `a` VALUES (2);\n" +
+					"INSERT INTO `other`.`a` VALUES (4);\n" +
+					splitFooter,
+				"b.sql": splitHeader + "USE `other`;\n" +
+					"CREATE TABLE `b` (\n  `id` int\n);\n" +
+					"INSERT INTO `b` VALUES (3);\n" +
+					splitFooter,
+			},
+		},
+		{
+			name:    "filtered",
+			include: "other.*",
+			exclude: "b",
+			want: []SplitFile{
+				{Table: "a", Database: "other", Path: "a.sql", Statements: 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := writeDump(t, splitDump)
+			filter, err := ParseTableFilter(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := filepath.Join(t.TempDir(), "out")
+			files, err := Split(filename, dir, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range files {
+				info, err := os.Stat(files[i].Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if files[i].Bytes != info.Size() {
+					t.Errorf("%s has %d bytes, reported as %d", files[i].Path, info.Size(), files[i].Bytes)
+				}
+				files[i].Path, _ = filepath.Rel(dir, files[i].Path)
+				files[i].Bytes = 0
+			}
+			if !reflect.DeepEqual(files, tt.want) {
+				t.Errorf("got files %+v, want %+v", files, tt.want)
+			}
+			for name, want := range tt.files {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != want {
+					t.Errorf("%s holds\n%s\nwant\n%s", name, data, want)
+				}
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != len(tt.want) {
+				t.Errorf("%d files written, want %d", len(entries), len(tt.want))
+			}
+		})
+	}
+}
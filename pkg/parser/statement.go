@@ -0,0 +1,202 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Statement is a single SQL statement read from a dump, together with the
+// position it was read from.
+type Statement struct {
+	Text    string
+	Ordinal int   // 1-based index of the statement in the input
+	Line    int   // line the statement starts on
+	Offset  int64 // byte offset of the first line of the statement
+	EndLine int   // line the statement ends on
+
+	marks []lineMark
+}
+
+// lineMark maps the start of a line in Statement.Text back to the input.
+type lineMark struct {
+	pos    int
+	line   int
+	offset int64
+}
+
+// Position translates a byte index into Text to a line, column and byte
+// offset in the input.
+func (s *Statement) Position(pos int) (line, column int, offset int64) {
+	if len(s.marks) == 0 {
+		return s.Line, pos + 1, s.Offset + int64(pos)
+	}
+	m := s.marks[0]
+	for _, next := range s.marks[1:] {
+		if next.pos > pos {
+			break
+		}
+		m = next
+	}
+	return m.line, pos - m.pos + 1, m.offset + int64(pos-m.pos)
+}
+
+// snippet returns the input line containing pos, shortened to a window around
+// pos, followed by a caret line pointing at pos.
+func (s *Statement) snippet(pos int) string {
+	if pos > len(s.Text) {
+		pos = len(s.Text)
+	}
+	start := strings.LastIndexByte(s.Text[:pos], '\n') + 1
+	end := strings.IndexByte(s.Text[pos:], '\n')
+	if end < 0 {
+		end = len(s.Text)
+	} else {
+		end += pos
+	}
+
+	const window = 40
+	prefix, suffix := "", ""
+	if pos-start > window {
+		start = pos - window
+		prefix = "..."
+	}
+	if end-pos > window {
+		end = pos + window
+		suffix = "..."
+	}
+
+	line := prefix + s.Text[start:end] + suffix
+	caret := strings.Repeat(" ", len(prefix)+pos-start) + "^"
+	return line + "\n" + caret
+}
+
+// StatementScanner splits a SQL dump into statements. A statement ends on a
+// line whose last non-blank character is a semicolon; blank lines and "--"
+// comments are skipped.
+type StatementScanner struct {
+	scanner   *bufio.Scanner
+	filter    func(firstLine string) bool
+	endLine   int
+	consumed  int64
+	lineStart int64
+	line      int
+	ordinal   int
+	stmt      *Statement
+	err       error
+}
+
+func NewStatementScanner(r io.Reader) *StatementScanner {
+	s := &StatementScanner{}
+
+	// Use a larger buffer for scanning
+	buf := make([]byte, 64*1024)
+	s.scanner = bufio.NewScanner(r)
+	s.scanner.Buffer(buf, 10*1024*1024) // 10MB max line length
+	s.scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if advance > 0 {
+			s.lineStart = s.consumed
+			s.consumed += int64(advance)
+		}
+		return advance, token, err
+	})
+	return s
+}
+
+// SetPosition tells the scanner that its reader starts at the given line and
+// byte offset of the input rather than at its beginning, with the statement
+// of the given ordinal, so that statements keep the ordinals they have in the
+// whole input.
+func (s *StatementScanner) SetPosition(line int, offset int64, statement int) {
+	s.line = line - 1
+	s.consumed = offset
+	if statement > 0 {
+		s.ordinal = statement - 1
+	}
+}
+
+// SetEndLine makes Scan stop at the first statement that starts after line.
+func (s *StatementScanner) SetEndLine(line int) {
+	s.endLine = line
+}
+
+// SetFilter installs a function that is called with the first line of every
+// statement. Statements it rejects are still counted but their text is not
+// collected, and Scan moves on to the next statement.
+func (s *StatementScanner) SetFilter(filter func(firstLine string) bool) {
+	s.filter = filter
+}
+
+// Scan advances to the next statement, which is then available through
+// Statement. It returns false at the end of the input or on error.
+func (s *StatementScanner) Scan() bool {
+	var text strings.Builder
+	var stmt *Statement
+	skipping := false
+
+	for s.scanner.Scan() {
+		s.line++
+		line := s.scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		// Skip empty lines and comments
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		if stmt == nil {
+			if s.endLine > 0 && s.line > s.endLine {
+				return false
+			}
+			s.ordinal++
+			stmt = &Statement{Ordinal: s.ordinal, Line: s.line, Offset: s.lineStart}
+			skipping = s.filter != nil && !s.filter(line)
+		}
+
+		if !skipping {
+			if text.Len() > 0 {
+				text.WriteByte('\n')
+			}
+			stmt.marks = append(stmt.marks, lineMark{pos: text.Len(), line: s.line, offset: s.lineStart})
+			text.WriteString(line)
+		}
+
+		if strings.HasSuffix(trimmed, ";") {
+			if skipping {
+				stmt, skipping = nil, false
+				text.Reset()
+				continue
+			}
+			stmt.Text = text.String()
+			stmt.EndLine = s.line
+			s.stmt = stmt
+			return true
+		}
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			err = &ParseError{Line: s.line + 1, Column: 1, Offset: s.consumed, Err: err}
+		}
+		s.err = err
+		return false
+	}
+
+	// Hand out a trailing statement that is missing its terminating semicolon
+	if stmt != nil && !skipping {
+		stmt.Text = text.String()
+		stmt.EndLine = s.line
+		s.stmt = stmt
+		return true
+	}
+	return false
+}
+
+func (s *StatementScanner) Statement() *Statement {
+	return s.stmt
+}
+
+func (s *StatementScanner) Err() error {
+	return s.err
+}
@@ -0,0 +1,411 @@
+package parser
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"sqlparser/pkg/models"
+)
+
+// Handler receives the contents of a dump as Stream parses it. Calls are made
+// from a single goroutine, in input order. TableStart is called before the
+// first rows of a table and TableEnd once its rows are complete; a table whose
+// INSERT statements are interrupted by another table's is started again.
+//
+// Schema is called for every CREATE TABLE statement. Tables without one get a
+// schema listing the columns of their first INSERT statement, with empty
+// types, before TableStart.
+type Handler interface {
+	Schema(schema *models.Schema) error
+	TableStart(table string) error
+	Rows(table string, rows []models.Row) error
+	TableEnd(table string) error
+}
+
+// HandlerFuncs adapts a set of optional callbacks to Handler. Nil callbacks
+// are skipped.
+type HandlerFuncs struct {
+	OnSchema     func(schema *models.Schema) error
+	OnTableStart func(table string) error
+	OnRows       func(table string, rows []models.Row) error
+	OnTableEnd   func(table string) error
+}
+
+func (h HandlerFuncs) Schema(schema *models.Schema) error {
+	if h.OnSchema == nil {
+		return nil
+	}
+	return h.OnSchema(schema)
+}
+
+func (h HandlerFuncs) TableStart(table string) error {
+	if h.OnTableStart == nil {
+		return nil
+	}
+	return h.OnTableStart(table)
+}
+
+func (h HandlerFuncs) Rows(table string, rows []models.Row) error {
+	if h.OnRows == nil {
+		return nil
+	}
+	return h.OnRows(table, rows)
+}
+
+func (h HandlerFuncs) TableEnd(table string) error {
+	if h.OnTableEnd == nil {
+		return nil
+	}
+	return h.OnTableEnd(table)
+}
+
+// Config controls a Stream run. The zero value parses every table with one
+// worker, the default batch size and ErrorPolicySkip.
+type Config struct {
+	File           string        // input name used in ParseErrors
+	StartLine      int           // line the reader starts at, if not at the beginning of File
+	StartOffset    int64         // byte offset the reader starts at
+	StartStatement int           // ordinal of the statement the reader starts with
+	StartDatabase  string        // database selected by a USE statement before the reader starts
+	EndLine        int           // stop after the statement starting on this line; 0 reads to the end
+	Workers        int           // number of statements parsed concurrently
+	BatchSize      int           // maximum number of rows per Handler.Rows call
+	Errors         *ErrorHandler // error policy for statements and rows
+
+	// Tables selects the tables to parse by database and name; nil selects
+	// all. The database is the one the statement qualifies the table with,
+	// or else the one selected by the last USE statement, and may be empty.
+	Tables func(database, name string) bool
+
+	// ResumeLine continues an interrupted run: INSERT statements starting
+	// before it are skipped, while CREATE TABLE statements are still parsed.
+	// RowNumbers holds the number of rows each table had before ResumeLine
+	// so that row numbers carry on where they stopped.
+	ResumeLine int
+	RowNumbers map[string]int
+}
+
+func (c Config) withDefaults() Config {
+	if c.Workers < 1 {
+		c.Workers = 1
+	}
+	if c.BatchSize < 1 {
+		c.BatchSize = models.BatchSize
+	}
+	if c.Tables == nil {
+		c.Tables = func(string, string) bool { return true }
+	}
+	if c.Errors == nil {
+		c.Errors = NewErrorHandler(ErrorPolicySkip, nil)
+	}
+	return c
+}
+
+// Stats summarizes a Stream run.
+type Stats struct {
+	Statements int   // INSERT statements parsed
+	Rows       int   // rows passed to the handler
+	Bytes      int64 // size of the INSERT statements parsed
+	LastLine   int   // last line of the last statement that was fully handled
+}
+
+// Stream parses the dump read from r and passes the schemas and rows of the
+// selected tables to h. Statements are parsed by cfg.Workers goroutines but
+// delivered in input order.
+//
+// Stream stops when the input ends, the error policy rejects a statement, h
+// returns an error or ctx is cancelled. Unless h failed, rows that were
+// already batched are delivered and the open table is ended before Stream
+// returns.
+func Stream(ctx context.Context, r io.Reader, cfg Config, h Handler) (*Stats, error) {
+	cfg = cfg.withDefaults()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	scanner := NewStatementScanner(&contextReader{ctx: ctx, r: r})
+	if cfg.StartLine > 0 {
+		scanner.SetPosition(cfg.StartLine, cfg.StartOffset, cfg.StartStatement)
+	}
+	scanner.SetEndLine(cfg.EndLine)
+	database := cfg.StartDatabase
+	selected := func(db, name string) bool {
+		if db == "" {
+			db = database
+		}
+		return cfg.Tables(db, name)
+	}
+	scanner.SetFilter(func(firstLine string) bool {
+		if db := useDatabase(firstLine); db != "" {
+			database = db
+			return false
+		}
+		if db, name := insertTable(firstLine); name != "" {
+			return selected(db, name) && scanner.line >= cfg.ResumeLine
+		}
+		if db, name := createTable(firstLine); name != "" {
+			return selected(db, name)
+		}
+		return false
+	})
+
+	jobs := make(chan *statementJob, cfg.Workers*2)
+	results := make(chan *statementResult, cfg.Workers*2)
+	// Bounds the number of statements in flight, including results that
+	// arrived early and wait to be delivered in order
+	window := make(chan struct{}, cfg.Workers*4)
+
+	// Read and send statements to workers. CREATE TABLE is parsed here so that
+	// the schema is known before the INSERT statements that follow it.
+	var readErr error
+	go func() {
+		defer close(jobs)
+		schemas := make(map[string]*models.Schema)
+		for seq := 0; scanner.Scan(); seq++ {
+			job := &statementJob{seq: seq, statement: scanner.Statement()}
+			if name := createTableName(job.statement.Text); name != "" {
+				job.table, job.create = name, true
+				job.schema, job.err = parseCreateTable(job.statement.Text)
+				if job.err == nil {
+					schemas[name] = job.schema
+				}
+			} else {
+				job.table = insertTableName(job.statement.Text)
+				job.schema = schemas[job.table]
+			}
+
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr = scanner.Err()
+	}()
+
+	// Start worker pool
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var job *statementJob
+				var ok bool
+				select {
+				case job, ok = <-jobs:
+					if !ok {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+
+				select {
+				case results <- job.process(cfg.File, cfg.Workers):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Deliver results in input order
+	state := &streamState{cfg: cfg, handler: h, stats: &Stats{}, rowNumbers: make(map[string]int), schemas: make(map[string]bool)}
+	for table, n := range cfg.RowNumbers {
+		state.rowNumbers[table] = n
+	}
+	pending := make(map[int]*statementResult)
+	next := 0
+	var stopErr error
+	for result := range results {
+		if stopErr != nil {
+			continue // drain after stopping
+		}
+		pending[result.seq] = result
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-window
+
+			if err := state.deliver(ready); err != nil {
+				stopErr = err
+				cancel()
+				break
+			}
+		}
+	}
+
+	if herr, ok := stopErr.(*handlerError); ok {
+		return state.stats, herr.err
+	}
+	if err := state.endTable(); err != nil {
+		return state.stats, err
+	}
+	if stopErr != nil {
+		return state.stats, stopErr
+	}
+	if err := ctx.Err(); err != nil {
+		return state.stats, err
+	}
+	if readErr != nil {
+		if perr, ok := readErr.(*ParseError); ok {
+			perr.File = cfg.File
+		}
+		return state.stats, readErr
+	}
+	return state.stats, nil
+}
+
+// handlerError marks an error returned by the Handler, after which it must
+// not be called again.
+type handlerError struct {
+	err error
+}
+
+func (e *handlerError) Error() string {
+	return e.err.Error()
+}
+
+// streamState batches the rows of the current table on behalf of Stream.
+type streamState struct {
+	cfg        Config
+	handler    Handler
+	stats      *Stats
+	table      string
+	batch      []models.Row
+	rowNumbers map[string]int
+	schemas    map[string]bool // tables whose schema was delivered
+}
+
+func (s *streamState) deliver(result *statementResult) error {
+	errs := s.cfg.Errors
+	if result.err != nil {
+		if err := errs.Handle(result.err, result.statement.Text, countTuples(result.statement.Text)); err != nil {
+			return err
+		}
+		s.stats.LastLine = result.statement.EndLine
+		return nil
+	}
+	for _, rowErr := range result.rowErrors {
+		if err := errs.Handle(rowErr.err, rowErr.raw, 1); err != nil {
+			return err
+		}
+	}
+	// The statement counts as handled once it's in the batch: a Stream that
+	// stops early still delivers the batch unless the handler failed
+	s.stats.LastLine = result.statement.EndLine
+
+	if result.schema != nil {
+		if result.schema.TableName != s.table {
+			if err := s.endTable(); err != nil {
+				return &handlerError{err}
+			}
+		}
+		s.schemas[result.schema.TableName] = true
+		if err := s.handler.Schema(result.schema); err != nil {
+			return &handlerError{err}
+		}
+		return nil
+	}
+	if result.tableName == "" {
+		return nil
+	}
+
+	// Handle new table
+	if result.tableName != s.table {
+		if err := s.endTable(); err != nil {
+			return &handlerError{err}
+		}
+		s.table = result.tableName
+		if !s.schemas[s.table] {
+			if err := s.inferSchema(result.columns); err != nil {
+				return &handlerError{err}
+			}
+		}
+		if err := s.handler.TableStart(s.table); err != nil {
+			return &handlerError{err}
+		}
+		s.batch = make([]models.Row, 0, s.cfg.BatchSize)
+	}
+
+	s.stats.Statements++
+	s.stats.Bytes += int64(len(result.statement.Text))
+	for _, row := range result.rows {
+		s.rowNumbers[s.table]++
+		row.RowNumber = s.rowNumbers[s.table]
+		s.batch = append(s.batch, row)
+
+		// Deliver batch if it reaches the batch size
+		if len(s.batch) >= s.cfg.BatchSize {
+			if err := s.flush(); err != nil {
+				return &handlerError{err}
+			}
+		}
+	}
+	return nil
+}
+
+// inferSchema delivers a schema made of the column names of the table's first
+// INSERT statement, for tables without a CREATE TABLE statement.
+func (s *streamState) inferSchema(columns []string) error {
+	schema := &models.Schema{TableName: s.table}
+	for _, name := range columns {
+		schema.Columns = append(schema.Columns, models.Column{Name: name, Nullable: true})
+	}
+	s.schemas[s.table] = true
+	return s.handler.Schema(schema)
+}
+
+func (s *streamState) flush() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	s.stats.Rows += len(s.batch)
+	if err := s.handler.Rows(s.table, s.batch); err != nil {
+		return err
+	}
+	s.batch = make([]models.Row, 0, s.cfg.BatchSize)
+	return nil
+}
+
+// endTable delivers the remaining rows of the current table and ends it.
+func (s *streamState) endTable() error {
+	if s.table == "" {
+		return nil
+	}
+	if err := s.flush(); err != nil {
+		return err
+	}
+	table := s.table
+	s.table = ""
+	return s.handler.TableEnd(table)
+}
+
+// contextReader fails reads once ctx is done, so that a cancelled Stream stops
+// reading its input promptly.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sqlparser/pkg/models"
+)
+
+// resumeDump has the CREATE TABLE statement of t on lines 1-3 and INSERT
+// statements on lines 4, 5 and 6.
+const resumeDump = "CREATE TABLE `t` (\n  `id` int\n);\n" +
+	"INSERT INTO `t` VALUES (1),(2);\n" +
+	"INSERT INTO `t` VALUES (3);\n" +
+	"INSERT INTO `t` VALUES (4),(5);\n"
+
+func TestStreamResume(t *testing.T) {
+	tests := []struct {
+		line int
+		rows int // exported before line
+		want []string
+	}{
+		{line: 0, want: []string{"1:1", "2:2", "3:3", "4:4", "5:5"}},
+		{line: 5, rows: 2, want: []string{"3:3", "4:4", "5:5"}},
+		{line: 6, rows: 3, want: []string{"4:4", "5:5"}},
+		{line: 7, rows: 5},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("line %d", tt.line), func(t *testing.T) {
+			var got []string
+			schema := false
+			h := HandlerFuncs{
+				OnSchema: func(*models.Schema) error {
+					schema = true
+					return nil
+				},
+				OnRows: func(_ string, rows []models.Row) error {
+					for _, row := range rows {
+						got = append(got, fmt.Sprintf("%d:%s", row.RowNumber, row.Data["id"]))
+					}
+					return nil
+				},
+			}
+			cfg := Config{Workers: 2, ResumeLine: tt.line, RowNumbers: map[string]int{"t": tt.rows}}
+			stats, err := Stream(context.Background(), strings.NewReader(resumeDump), cfg, h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got rows %v, want %v", got, tt.want)
+			}
+			// The schema is parsed even when the resumed run starts after it
+			if !schema {
+				t.Error("no schema delivered")
+			}
+			if stats.Rows != len(tt.want) {
+				t.Errorf("stats count %d rows, want %d", stats.Rows, len(tt.want))
+			}
+		})
+	}
+}
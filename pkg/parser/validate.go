@@ -0,0 +1,280 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strings"
+
+	"sqlparser/pkg/models"
+)
+
+// Kinds of problems found by Validate.
+const (
+	ProblemSyntax         = "syntax"          // a statement or tuple that can't be parsed
+	ProblemColumnCount    = "column_count"    // a tuple whose value count doesn't match the columns
+	ProblemTruncated      = "truncated"       // the dump ends in the middle of a statement
+	ProblemDuplicateKey   = "duplicate_key"   // a primary key value that occurs more than once
+	ProblemMissingTrailer = "missing_trailer" // a mysqldump dump without "Dump completed"
+)
+
+// ValidateOptions controls Validate.
+type ValidateOptions struct {
+	Workers int
+
+	// CheckKeys enables the duplicate primary key check, which keeps a 16
+	// byte hash of every key in memory.
+	CheckKeys bool
+
+	// MaxProblems limits the problems of each kind kept in the report; all
+	// of them are counted. 0 keeps every problem.
+	MaxProblems int
+}
+
+// ValidationReport is the result of Validate.
+type ValidationReport struct {
+	File       string         `json:"file"`
+	Statements int            `json:"statements"` // INSERT statements
+	Rows       int            `json:"rows"`
+	Valid      bool           `json:"valid"`
+	Counts     map[string]int `json:"counts"` // problems by kind
+	Problems   []Problem      `json:"problems"`
+}
+
+// Problem is something wrong with a dump.
+type Problem struct {
+	Kind      string `json:"kind"`
+	Message   string `json:"message"`
+	Table     string `json:"table,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Statement int    `json:"statement,omitempty"`
+	Row       int    `json:"row,omitempty"` // tuple within the statement, or row of the table for duplicate keys
+}
+
+// dumpTrailer is the comment mysqldump ends complete dumps with.
+const dumpTrailer = "-- Dump completed"
+
+// tailSize is how much of the end of a dump is kept to check how it ends.
+const tailSize = 4096
+
+// Validate parses the whole dump without producing output and reports what is
+// wrong with it: statements and tuples that don't parse, tuples with the
+// wrong number of values, a last statement cut off by truncation, duplicate
+// primary keys and, for mysqldump dumps, a missing "Dump completed" trailer.
+func Validate(ctx context.Context, filename string, opts ValidateOptions) (*ValidationReport, error) {
+	file, err := OpenInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	report := &ValidationReport{File: filename, Counts: make(map[string]int), Problems: []Problem{}}
+	add := func(p Problem) int {
+		report.Counts[p.Kind]++
+		if opts.MaxProblems == 0 || report.Counts[p.Kind] <= opts.MaxProblems {
+			report.Problems = append(report.Problems, p)
+			return len(report.Problems) - 1
+		}
+		return -1
+	}
+
+	// A statement cut off by truncation fails to parse too, but is only
+	// reported as truncated. lastSyntax tells whether the last statement
+	// delivered was rejected as a whole, and lastSyntaxKept where its problem
+	// is in the report, if it was kept.
+	lastSyntax, lastSyntaxKept := false, -1
+	delivered := func() { lastSyntax, lastSyntaxKept = false, -1 }
+
+	errs := NewErrorHandler(ErrorPolicySkip, nil)
+	errs.OnReject = func(perr *ParseError) {
+		p := parseErrorProblem(perr)
+		i := add(p)
+		if p.Kind == ProblemSyntax && perr.Row == 0 {
+			lastSyntax, lastSyntaxKept = true, i
+		} else {
+			delivered()
+		}
+	}
+
+	// The header and trailer are comments, which the statement scanner skips,
+	// so they are picked out of the data as it is read
+	info := &DumpInfo{}
+	tail := &tailReader{r: &headerReader{r: file, info: info, dialect: &dialectDetector{}}}
+
+	keys := &keyChecker{schemas: make(map[string]*models.Schema), seen: make(map[string]map[[16]byte]int)}
+	h := HandlerFuncs{
+		OnSchema: func(schema *models.Schema) error {
+			delivered()
+			return keys.schema(schema)
+		},
+		OnRows: func(table string, rows []models.Row) error {
+			delivered()
+			if opts.CheckKeys {
+				keys.rows(table, rows, func(p Problem) { add(p) })
+			}
+			return nil
+		},
+	}
+
+	cfg := Config{File: filename, Workers: opts.Workers, Errors: errs}
+	stats, err := Stream(ctx, tail, cfg, h)
+	if err != nil {
+		// Lines too long for the scanner end the run
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			return nil, err
+		}
+		add(parseErrorProblem(perr))
+	}
+	if stats != nil {
+		report.Statements = stats.Statements
+		report.Rows = stats.Rows
+	}
+
+	if line, ok := tail.lastLine(); ok && !strings.HasSuffix(line, ";") {
+		// The cut-off statement ends on the last line of the input
+		if lastSyntax && stats != nil && stats.LastLine == tail.lines() {
+			report.Counts[ProblemSyntax]--
+			if report.Counts[ProblemSyntax] == 0 {
+				delete(report.Counts, ProblemSyntax)
+			}
+			if lastSyntaxKept >= 0 {
+				report.Problems = append(report.Problems[:lastSyntaxKept], report.Problems[lastSyntaxKept+1:]...)
+			}
+		}
+		add(Problem{Kind: ProblemTruncated, Message: "the dump ends in the middle of a statement"})
+	}
+	tool := info.Header.Tool
+	if (strings.HasPrefix(tool, "MySQL dump") || strings.HasPrefix(tool, "MariaDB dump")) && !tail.contains(dumpTrailer) {
+		add(Problem{Kind: ProblemMissingTrailer, Message: fmt.Sprintf("no %q comment at the end of the %s output", dumpTrailer, tool)})
+	}
+
+	report.Valid = len(report.Counts) == 0
+	return report, nil
+}
+
+func parseErrorProblem(perr *ParseError) Problem {
+	kind := ProblemSyntax
+	if errors.Is(perr, ErrColumnCount) {
+		kind = ProblemColumnCount
+	}
+	return Problem{
+		Kind:      kind,
+		Message:   perr.Err.Error(),
+		Table:     perr.Table,
+		Line:      perr.Line,
+		Statement: perr.Statement,
+		Row:       perr.Row,
+	}
+}
+
+// keyChecker finds duplicate primary keys by remembering a hash of the key
+// of every row.
+type keyChecker struct {
+	schemas map[string]*models.Schema
+	seen    map[string]map[[16]byte]int // table, key hash, row number
+}
+
+func (k *keyChecker) schema(schema *models.Schema) error {
+	k.schemas[schema.TableName] = schema
+	return nil
+}
+
+func (k *keyChecker) rows(table string, rows []models.Row, add func(Problem)) {
+	schema := k.schemas[table]
+	if schema == nil || len(schema.PrimaryKey) == 0 {
+		return
+	}
+	seen := k.seen[table]
+	if seen == nil {
+		seen = make(map[[16]byte]int)
+		k.seen[table] = seen
+	}
+
+	h := fnv.New128a()
+	var sum [16]byte
+	for _, row := range rows {
+		h.Reset()
+		for _, col := range schema.PrimaryKey {
+			value := row.Data[col]
+			if value == nil {
+				h.Write([]byte{0})
+				continue
+			}
+			s := fmt.Sprint(value)
+			fmt.Fprintf(h, "%d:%s", len(s), s)
+		}
+		h.Sum(sum[:0])
+		if first, ok := seen[sum]; ok {
+			add(Problem{
+				Kind:    ProblemDuplicateKey,
+				Message: fmt.Sprintf("duplicate primary key %s, first seen in row %d", formatKey(schema.PrimaryKey, row.Data), first),
+				Table:   table,
+				Row:     row.RowNumber,
+			})
+			continue
+		}
+		seen[sum] = row.RowNumber
+	}
+}
+
+func formatKey(columns []string, data map[string]interface{}) string {
+	parts := make([]string, len(columns))
+	for i, col := range columns {
+		value := data[col]
+		if value == nil {
+			value = "NULL"
+		}
+		parts[i] = fmt.Sprintf("%s=%v", col, value)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// tailReader passes data through while keeping the last bytes read and
+// counting lines.
+type tailReader struct {
+	r        io.Reader
+	tail     []byte
+	newlines int
+}
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.newlines += bytes.Count(p[:n], []byte("\n"))
+		t.tail = append(t.tail, p[:n]...)
+		if len(t.tail) > tailSize {
+			t.tail = append(t.tail[:0], t.tail[len(t.tail)-tailSize:]...)
+		}
+	}
+	return n, err
+}
+
+// lastLine returns the last line read that isn't blank or a comment, with
+// surrounding whitespace removed.
+func (t *tailReader) lastLine() (string, bool) {
+	lines := bytes.Split(t.tail, []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(string(lines[i]))
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return line, true
+		}
+	}
+	return "", false
+}
+
+// lines returns the number of lines read, counting a last line without a
+// newline.
+func (t *tailReader) lines() int {
+	if len(t.tail) > 0 && t.tail[len(t.tail)-1] != '\n' {
+		return t.newlines + 1
+	}
+	return t.newlines
+}
+
+func (t *tailReader) contains(s string) bool {
+	return bytes.Contains(t.tail, []byte(s))
+}
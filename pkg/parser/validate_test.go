@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	const users = "CREATE TABLE `users` (\n  `id` int(11) NOT NULL,\n  `name` varchar(10),\n  PRIMARY KEY (`id`)\n);\n"
+	tests := []struct {
+		name   string
+		dump   string
+		counts map[string]int
+		lines  []int // of the problems, in order
+	}{
+		{
+			name:   "valid",
+			dump:   users + "INSERT INTO `users` VALUES (1,'a'),(2,'b');\n",
+			counts: map[string]int{},
+		},
+		{
+			name:   "syntax error",
+			dump:   users + "INSERT INTO `users` VALUES (1,'a';\nINSERT INTO `users` VALUES (2,'b');\n",
+			counts: map[string]int{ProblemSyntax: 1},
+			lines:  []int{6},
+		},
+		{
+			name:   "column count",
+			dump:   users + "INSERT INTO `users` VALUES (1,'a'),(2);\n",
+			counts: map[string]int{ProblemColumnCount: 1},
+			lines:  []int{6},
+		},
+		{
+			name:   "duplicate key",
+			dump:   users + "INSERT INTO `users` VALUES (1,'a'),(1,'b');\n",
+			counts: map[string]int{ProblemDuplicateKey: 1},
+			lines:  []int{0},
+		},
+		{
+			name:   "truncated statement is not a syntax error",
+			dump:   users + "INSERT INTO `users` VALUES (1,'a'),(2,'b",
+			counts: map[string]int{ProblemTruncated: 1},
+			lines:  []int{0},
+		},
+		{
+			name:   "truncated after a syntax error",
+			dump:   users + "INSERT INTO `users` VALUES (1,'a';\nINSERT INTO `users` VALUES (2,'b'),(3,\n",
+			counts: map[string]int{ProblemSyntax: 1, ProblemTruncated: 1},
+			lines:  []int{6, 0},
+		},
+		{
+			name:   "truncated statement that parses",
+			dump:   users + "INSERT INTO `users` VALUES (1,'a';\nINSERT INTO `users` VALUES (2,'b')\n",
+			counts: map[string]int{ProblemSyntax: 1, ProblemTruncated: 1},
+			lines:  []int{6, 0},
+		},
+		{
+			name:   "truncated in a statement that isn't parsed",
+			dump:   users + "INSERT INTO `users` VALUES (1,'a';\nUNLOCK TAB",
+			counts: map[string]int{ProblemSyntax: 1, ProblemTruncated: 1},
+			lines:  []int{6, 0},
+		},
+		{
+			name:   "missing trailer",
+			dump:   "-- MySQL dump 10.13  Distrib 8.0.32, for Linux (x86_64)\n" + users + "INSERT INTO `users` VALUES (1,'a');\n",
+			counts: map[string]int{ProblemMissingTrailer: 1},
+			lines:  []int{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := writeDump(t, tt.dump)
+			report, err := Validate(context.Background(), filename, ValidateOptions{Workers: 2, CheckKeys: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(report.Counts, tt.counts) {
+				t.Errorf("counts = %v, want %v", report.Counts, tt.counts)
+			}
+			var lines []int
+			for _, p := range report.Problems {
+				lines = append(lines, p.Line)
+			}
+			if !reflect.DeepEqual(lines, tt.lines) {
+				t.Errorf("problems on lines %v, want %v: %+v", lines, tt.lines, report.Problems)
+			}
+			if report.Valid != (len(tt.counts) == 0) {
+				t.Errorf("valid = %v with counts %v", report.Valid, report.Counts)
+			}
+		})
+	}
+}
+
+func TestValidateOptions(t *testing.T) {
+	const dump = "CREATE TABLE `t` (\n  `id` int NOT NULL,\n  PRIMARY KEY (`id`)\n);\n" +
+		"INSERT INTO `t` VALUES (1),(1),(1);\n" +
+		"INSERT INTO `t` VALUES (1;\n" +
+		"INSERT INTO `t` VALUES (2;\n" +
+		"INSERT INTO `t` VALUES (3;\n" +
+		"INSERT INTO `t` VALUES (2),(2);\n"
+	tests := []struct {
+		name   string
+		opts   ValidateOptions
+		counts map[string]int
+		kept   map[string]int
+	}{
+		{
+			name:   "every problem",
+			opts:   ValidateOptions{CheckKeys: true},
+			counts: map[string]int{ProblemSyntax: 3, ProblemDuplicateKey: 3},
+			kept:   map[string]int{ProblemSyntax: 3, ProblemDuplicateKey: 3},
+		},
+		{
+			name:   "problems of each kind limited",
+			opts:   ValidateOptions{CheckKeys: true, MaxProblems: 2},
+			counts: map[string]int{ProblemSyntax: 3, ProblemDuplicateKey: 3},
+			kept:   map[string]int{ProblemSyntax: 2, ProblemDuplicateKey: 2},
+		},
+		{
+			name:   "keys not checked",
+			opts:   ValidateOptions{Workers: 4, MaxProblems: 1},
+			counts: map[string]int{ProblemSyntax: 3},
+			kept:   map[string]int{ProblemSyntax: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Validate(context.Background(), writeDump(t, dump), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(report.Counts, tt.counts) {
+				t.Errorf("counts = %v, want %v", report.Counts, tt.counts)
+			}
+			kept := make(map[string]int)
+			for _, p := range report.Problems {
+				kept[p.Kind]++
+			}
+			if !reflect.DeepEqual(kept, tt.kept) {
+				t.Errorf("kept problems %v, want %v", kept, tt.kept)
+			}
+			if report.Statements != 2 || report.Rows != 5 {
+				t.Errorf("got %d statements and %d rows, want 2 and 5", report.Statements, report.Rows)
+			}
+		})
+	}
+}
@@ -6,11 +6,15 @@ import (
 	"sync"
 )
 
-func parseValuesList(valuesPart string) [][]string {
-	// Pre-allocate capacity based on rough estimate
-	estimatedRows := strings.Count(valuesPart, "),(") + 1
-	values := make([][]string, 0, estimatedRows)
+// span is the position of a tuple, parentheses included, within the VALUES
+// clause it was parsed from.
+type span struct {
+	start, end int
+}
 
+// parseValuesList splits the VALUES clause of an INSERT statement into tuples
+// of field values, along with the span of each tuple.
+func parseValuesList(valuesPart string) ([][]interface{}, []span, error) {
 	// Use a pool of builders to reduce allocations
 	builderPool := sync.Pool{
 		New: func() interface{} {
@@ -26,172 +30,175 @@ func parseValuesList(valuesPart string) [][]string {
 		return parseValuesListParallel(valuesPart, &builderPool)
 	}
 
-	var currentValue []string
 	fieldBuf := builderPool.Get().(*strings.Builder)
 	defer builderPool.Put(fieldBuf)
 
-	inQuotes := false
-	inParentheses := 0
-
-	// Process the string in a single pass
-	for i := 0; i < len(valuesPart); i++ {
-		char := valuesPart[i]
-
-		switch char {
-		case '(':
-			if !inQuotes {
-				inParentheses++
-				if inParentheses == 1 {
-					currentValue = make([]string, 0, 10)
-					continue
-				}
-			}
-		case ')':
-			if !inQuotes {
-				inParentheses--
-				if inParentheses == 0 {
-					if fieldBuf.Len() > 0 {
-						currentValue = append(currentValue, strings.TrimSpace(fieldBuf.String()))
-						fieldBuf.Reset()
-					}
-					values = append(values, currentValue)
-					continue
-				}
-			}
-		case '\'':
-			if i > 0 && valuesPart[i-1] != '\\' {
-				inQuotes = !inQuotes
-				continue
-			}
-		case ',':
-			if !inQuotes && inParentheses == 1 {
-				currentValue = append(currentValue, strings.TrimSpace(fieldBuf.String()))
-				fieldBuf.Reset()
-				continue
-			}
-		}
-
-		if inParentheses > 0 {
-			fieldBuf.WriteByte(char)
-		}
-	}
-
-	// Process the values in parallel if we have enough rows
-	if len(values) > 1000 {
-		processValuesParallel(values)
-	} else {
-		processValuesSequential(values)
-	}
-
-	return values
+	return parseValuesChunk(valuesPart, 0, fieldBuf)
 }
 
-func parseValuesListParallel(valuesPart string, builderPool *sync.Pool) [][]string {
+func parseValuesListParallel(valuesPart string, builderPool *sync.Pool) ([][]interface{}, []span, error) {
 	numWorkers := runtime.NumCPU()
-	chunkSize := (len(valuesPart) + numWorkers - 1) / numWorkers
+	bounds := splitTuples(valuesPart, numWorkers)
 
 	type chunkResult struct {
-		values [][]string
-		index  int
+		values [][]interface{}
+		spans  []span
+		err    error
 	}
 
-	results := make(chan chunkResult, numWorkers)
+	results := make([]chunkResult, len(bounds)-1)
 	var wg sync.WaitGroup
 
 	// Process chunks in parallel
-	for i := 0; i < numWorkers; i++ {
-		start := i * chunkSize
-		if start >= len(valuesPart) {
-			break
-		}
-
-		end := start + chunkSize
-		if end > len(valuesPart) {
-			end = len(valuesPart)
-		}
-
-		// Adjust chunk boundaries to not split in the middle of a value
-		if start > 0 {
-			for start < len(valuesPart) && valuesPart[start] != '(' {
-				start++
-			}
-		}
-		if end < len(valuesPart) {
-			for end > start && valuesPart[end] != ')' {
-				end--
-			}
-			end++ // include the closing parenthesis
-		}
-
+	for i := 0; i < len(bounds)-1; i++ {
 		wg.Add(1)
 		go func(start, end, index int) {
 			defer wg.Done()
 
 			fieldBuf := builderPool.Get().(*strings.Builder)
 			defer builderPool.Put(fieldBuf)
+			fieldBuf.Reset()
 
-			chunk := valuesPart[start:end]
-			values := parseValuesChunk(chunk, fieldBuf)
-			results <- chunkResult{values: values, index: index}
-		}(start, end, i)
+			values, spans, err := parseValuesChunk(valuesPart[start:end], start, fieldBuf)
+			results[index] = chunkResult{values: values, spans: spans, err: err}
+		}(bounds[i], bounds[i+1], i)
 	}
+	wg.Wait()
 
-	// Close results channel when all workers are done
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	// Collect and merge results
-	var allValues [][]string
-	for result := range results {
+	// Merge results in input order
+	var allValues [][]interface{}
+	var allSpans []span
+	for _, result := range results {
+		if result.err != nil {
+			return nil, nil, result.err
+		}
 		allValues = append(allValues, result.values...)
+		allSpans = append(allSpans, result.spans...)
+	}
+	return allValues, allSpans, nil
+}
+
+// splitTuples cuts valuesPart into roughly n chunks, only cutting between
+// tuples so that no chunk starts inside a quoted value. It returns the chunk
+// boundaries, starting with 0 and ending with len(valuesPart).
+func splitTuples(valuesPart string, n int) []int {
+	bounds := []int{0}
+	target := (len(valuesPart) + n - 1) / n
+
+	inQuotes := false
+	depth := 0
+	for i := 0; i < len(valuesPart); i++ {
+		switch valuesPart[i] {
+		case '\\':
+			if inQuotes {
+				i++ // the escaped character
+			}
+		case '\'':
+			// A doubled quote inside a string closes and reopens it
+			inQuotes = !inQuotes
+		case '(':
+			if !inQuotes {
+				depth++
+			}
+		case ')':
+			if !inQuotes {
+				depth--
+				if depth == 0 && i+1-bounds[len(bounds)-1] >= target {
+					bounds = append(bounds, i+1)
+				}
+			}
+		}
 	}
 
-	return allValues
+	if bounds[len(bounds)-1] != len(valuesPart) {
+		bounds = append(bounds, len(valuesPart))
+	}
+	return bounds
 }
 
-func parseValuesChunk(chunk string, fieldBuf *strings.Builder) [][]string {
-	var values [][]string
-	var currentValue []string
+// parseValuesChunk splits a run of "(...),(...)" tuples into their fields.
+// Quoted strings are decoded: their backslash escapes and doubled quotes are
+// replaced by the characters they stand for, and a charset introducer such
+// as _binary is dropped. NULL becomes nil. base is the position
+// of chunk within the VALUES clause and is used to report where an
+// unterminated tuple or string starts.
+func parseValuesChunk(chunk string, base int, fieldBuf *strings.Builder) ([][]interface{}, []span, error) {
+	// Pre-allocate capacity based on rough estimate
+	estimatedRows := strings.Count(chunk, "),(") + 1
+	values := make([][]interface{}, 0, estimatedRows)
+	spans := make([]span, 0, estimatedRows)
+
+	var currentValue []interface{}
 	inQuotes := false
+	quoted := false // the current field contained a quoted string, possibly empty
 	inParentheses := 0
+	tupleStart, quoteStart := 0, 0
 
+	// Process the string in a single pass
 	for i := 0; i < len(chunk); i++ {
 		char := chunk[i]
 
+		if inQuotes {
+			switch char {
+			case '\\':
+				if i+1 < len(chunk) {
+					i++
+					writeEscape(fieldBuf, chunk[i])
+				}
+			case '\'':
+				if i+1 < len(chunk) && chunk[i+1] == '\'' {
+					fieldBuf.WriteByte('\'')
+					i++
+				} else {
+					inQuotes = false
+				}
+			default:
+				fieldBuf.WriteByte(char)
+			}
+			continue
+		}
+
 		switch char {
 		case '(':
-			if !inQuotes {
-				inParentheses++
-				if inParentheses == 1 {
-					currentValue = make([]string, 0, 10)
-					continue
-				}
+			inParentheses++
+			if inParentheses == 1 {
+				tupleStart = i
+				quoted = false
+				currentValue = make([]interface{}, 0, 10)
+				continue
 			}
 		case ')':
-			if !inQuotes {
-				inParentheses--
-				if inParentheses == 0 {
-					if fieldBuf.Len() > 0 {
-						currentValue = append(currentValue, strings.TrimSpace(fieldBuf.String()))
-						fieldBuf.Reset()
-					}
-					values = append(values, currentValue)
-					continue
+			inParentheses--
+			if inParentheses == 0 {
+				if fieldBuf.Len() > 0 || quoted || len(currentValue) > 0 {
+					currentValue = append(currentValue, fieldValue(fieldBuf, quoted))
+					quoted = false
 				}
+				values = append(values, currentValue)
+				spans = append(spans, span{start: base + tupleStart, end: base + i + 1})
+				continue
+			}
+			if inParentheses < 0 {
+				return nil, nil, &syntaxError{pos: base + i, msg: "unexpected ')' outside of a tuple"}
 			}
 		case '\'':
-			if i > 0 && chunk[i-1] != '\\' {
-				inQuotes = !inQuotes
-				continue
+			if strings.HasPrefix(fieldBuf.String(), "_") {
+				// A charset introducer, as in _binary 'abc'
+				fieldBuf.Reset()
 			}
+			quoteStart = i
+			inQuotes = true
+			quoted = true
+			continue
 		case ',':
-			if !inQuotes && inParentheses == 1 {
-				currentValue = append(currentValue, strings.TrimSpace(fieldBuf.String()))
-				fieldBuf.Reset()
+			if inParentheses == 1 {
+				currentValue = append(currentValue, fieldValue(fieldBuf, quoted))
+				quoted = false
 				continue
 			}
+		case ' ', '\t', '\r', '\n':
+			// Whitespace outside of strings separates tokens only
+			continue
 		}
 
 		if inParentheses > 0 {
@@ -199,64 +206,87 @@ func parseValuesChunk(chunk string, fieldBuf *strings.Builder) [][]string {
 		}
 	}
 
-	return values
+	if inQuotes {
+		fieldBuf.Reset()
+		return nil, nil, &syntaxError{pos: base + quoteStart, msg: "unterminated string literal"}
+	}
+	if inParentheses > 0 {
+		fieldBuf.Reset()
+		return nil, nil, &syntaxError{pos: base + tupleStart, msg: "unterminated tuple"}
+	}
+
+	return values, spans, nil
 }
 
-func processValuesSequential(values [][]string) {
-	for i, row := range values {
-		for j, val := range row {
-			val = strings.TrimSpace(val)
-			if strings.ToUpper(val) == "NULL" {
-				values[i][j] = "NULL"
-				continue
-			}
-			if val == "''" || val == "" {
-				values[i][j] = ""
-				continue
-			}
-			if strings.HasPrefix(val, "'") && strings.HasSuffix(val, "'") {
-				values[i][j] = val[1 : len(val)-1]
-			}
-		}
+// fieldValue returns the field collected in fieldBuf, or nil for NULL, and
+// resets fieldBuf.
+func fieldValue(fieldBuf *strings.Builder, quoted bool) interface{} {
+	value := fieldBuf.String()
+	fieldBuf.Reset()
+	if !quoted && strings.EqualFold(value, "NULL") {
+		return nil
 	}
+	return value
 }
 
-func processValuesParallel(values [][]string) {
-	numWorkers := runtime.NumCPU()
-	rowsPerWorker := (len(values) + numWorkers - 1) / numWorkers
-
-	var wg sync.WaitGroup
-	for i := 0; i < numWorkers; i++ {
-		start := i * rowsPerWorker
-		if start >= len(values) {
-			break
-		}
+// writeEscape writes the character that the MySQL escape sequence \c stands
+// for. As in MySQL, \% and \_ keep their backslash, and a backslash before
+// any other character is dropped.
+func writeEscape(fieldBuf *strings.Builder, c byte) {
+	switch c {
+	case '0':
+		fieldBuf.WriteByte(0)
+	case 'b':
+		fieldBuf.WriteByte('\b')
+	case 'n':
+		fieldBuf.WriteByte('\n')
+	case 'r':
+		fieldBuf.WriteByte('\r')
+	case 't':
+		fieldBuf.WriteByte('\t')
+	case 'Z':
+		fieldBuf.WriteByte(0x1a)
+	case '%', '_':
+		fieldBuf.WriteByte('\\')
+		fieldBuf.WriteByte(c)
+	default:
+		fieldBuf.WriteByte(c)
+	}
+}
 
-		end := start + rowsPerWorker
-		if end > len(values) {
-			end = len(values)
-		}
+// countTuples counts the value tuples of an INSERT statement without parsing
+// them, including a trailing tuple that is cut off.
+func countTuples(statement string) int {
+	valuesIdx := strings.Index(statement, "VALUES")
+	if valuesIdx < 0 {
+		return 0
+	}
+	valuesPart := statement[valuesIdx+len("VALUES"):]
 
-		wg.Add(1)
-		go func(start, end int) {
-			defer wg.Done()
-			for idx := start; idx < end; idx++ {
-				for j, val := range values[idx] {
-					val = strings.TrimSpace(val)
-					if strings.ToUpper(val) == "NULL" {
-						values[idx][j] = "NULL"
-						continue
-					}
-					if val == "''" || val == "" {
-						values[idx][j] = ""
-						continue
-					}
-					if strings.HasPrefix(val, "'") && strings.HasSuffix(val, "'") {
-						values[idx][j] = val[1 : len(val)-1]
-					}
+	count := 0
+	inQuotes := false
+	depth := 0
+	for i := 0; i < len(valuesPart); i++ {
+		switch valuesPart[i] {
+		case '\\':
+			if inQuotes {
+				i++ // the escaped character
+			}
+		case '\'':
+			// A doubled quote inside a string closes and reopens it
+			inQuotes = !inQuotes
+		case '(':
+			if !inQuotes {
+				if depth == 0 {
+					count++
 				}
+				depth++
+			}
+		case ')':
+			if !inQuotes && depth > 0 {
+				depth--
 			}
-		}(start, end)
+		}
 	}
-	wg.Wait()
+	return count
 }
@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseValuesList(t *testing.T) {
+	tests := []struct {
+		name   string
+		values string
+		want   [][]interface{}
+		err    string
+	}{
+		{
+			name:   "numbers, strings and NULL",
+			values: " (1,'a',NULL),( 2 , 'b' , null )",
+			want:   [][]interface{}{{"1", "a", nil}, {"2", "b", nil}},
+		},
+		{
+			name:   "escaped quotes",
+			values: `(1,'O\'Brien','it''s','\'')`,
+			want:   [][]interface{}{{"1", "O'Brien", "it's", "'"}},
+		},
+		{
+			name:   "escaped backslash before the closing quote",
+			values: `('c:\\','x')`,
+			want:   [][]interface{}{{`c:\`, "x"}},
+		},
+		{
+			name:   "escape sequences",
+			values: `('\0\b\n\r\t\Z\"\x','\%\_')`,
+			want:   [][]interface{}{{"\x00\b\n\r\t\x1a\"x", `\%\_`}},
+		},
+		{
+			name:   "spaces and separators inside strings",
+			values: `(' a, (b) ','')`,
+			want:   [][]interface{}{{" a, (b) ", ""}},
+		},
+		{
+			name:   "quoted NULL is a string",
+			values: `('NULL',NULL)`,
+			want:   [][]interface{}{{"NULL", nil}},
+		},
+		{
+			name:   "charset introducer",
+			values: `(_binary 'ab\0',_utf8mb4'c')`,
+			want:   [][]interface{}{{"ab\x00", "c"}},
+		},
+		{
+			name:   "empty tuple",
+			values: `()`,
+			want:   [][]interface{}{{}},
+		},
+		{
+			name:   "unterminated string",
+			values: `(1,'a\')`,
+			err:    "unterminated string literal",
+		},
+		{
+			name:   "unterminated tuple",
+			values: `(1,'a'),(2`,
+			err:    "unterminated tuple",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, spans, err := parseValuesList(tt.values)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if len(spans) != len(got) {
+				t.Errorf("%d spans for %d tuples", len(spans), len(got))
+			}
+		})
+	}
+}
+
+func TestParseValuesListParallel(t *testing.T) {
+	// Large enough to be split into chunks, with tuples that would end a
+	// naively scanned string early
+	const tuple = `(1,'it\'s \\','a),(b','x''y')`
+	n := 3*1024*1024/len(tuple) + 1
+	values, _, err := parseValuesList(strings.Repeat(tuple+",", n-1) + tuple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != n {
+		t.Fatalf("got %d tuples, want %d", len(values), n)
+	}
+	want := []interface{}{"1", `it's \`, "a),(b", "x'y"}
+	for i, v := range values {
+		if !reflect.DeepEqual(v, want) {
+			t.Fatalf("tuple %d = %q, want %q", i, v, want)
+		}
+	}
+}
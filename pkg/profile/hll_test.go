@@ -0,0 +1,84 @@
+package profile
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+
+	"sqlparser/pkg/models"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		distinct  int
+		repeat    int     // times every value is added
+		tolerance float64 // relative error allowed
+		format    func(i int) string
+	}{
+		{name: "empty", distinct: 0, repeat: 1},
+		{name: "one value", distinct: 1, repeat: 100},
+		// Two of these hash to the same register, where 0.3 are expected
+		{name: "small", distinct: 100, repeat: 3, tolerance: 0.03},
+		// Linear counting is used below 2.5 times the number of registers
+		{name: "linear counting", distinct: 10000, repeat: 2, tolerance: 0.02},
+		{name: "raw estimate", distinct: 200000, repeat: 1, tolerance: 0.03},
+		{name: "sequential ids", distinct: 1000000, repeat: 1, tolerance: 0.03},
+		{
+			name: "similar strings", distinct: 50000, repeat: 1, tolerance: 0.03,
+			format: func(i int) string { return fmt.Sprintf("user%08d@example.com", i) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := tt.format
+			if format == nil {
+				format = strconv.Itoa
+			}
+			h := new(hyperLogLog)
+			for r := 0; r < tt.repeat; r++ {
+				for i := 0; i < tt.distinct; i++ {
+					h.add(format(i))
+				}
+			}
+			got := h.estimate()
+			if err := math.Abs(float64(got)-float64(tt.distinct)) / math.Max(1, float64(tt.distinct)); err > tt.tolerance {
+				t.Errorf("estimate = %d for %d distinct values, off by %.2f%%", got, tt.distinct, 100*err)
+			}
+		})
+	}
+}
+
+func TestHash64Spreads(t *testing.T) {
+	// Values that differ in their last character should pick registers all
+	// over the range, not neighbouring ones
+	used := make(map[uint64]bool)
+	for c := 0; c < 256; c++ {
+		used[hash64("value"+string(rune(c)))>>(64-8)] = true
+	}
+	if len(used) < 128 {
+		t.Errorf("256 values fell into only %d of 256 top-byte buckets", len(used))
+	}
+}
+
+func TestProfileDistinct(t *testing.T) {
+	p := New("t", &models.Schema{Columns: []models.Column{{Name: "a"}, {Name: "b"}}}, 0)
+	var rows []models.Row
+	for i := 0; i < 1000; i++ {
+		data := map[string]interface{}{"a": strconv.Itoa(i % 10), "b": nil}
+		if i%2 == 0 {
+			data["b"] = strconv.Itoa(i)
+		}
+		rows = append(rows, models.Row{RowNumber: i + 1, Data: data})
+	}
+	p.Add(rows)
+
+	want := map[string]uint64{"a": 10, "b": 500}
+	for _, c := range p.Profile().Columns {
+		// NULLs aren't values, so they aren't counted
+		if c.Distinct != want[c.Name] {
+			t.Errorf("column %s: distinct = %d, want %d", c.Name, c.Distinct, want[c.Name])
+		}
+	}
+}
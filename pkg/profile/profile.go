@@ -0,0 +1,313 @@
+// Package profile computes per-column statistics of table rows in a single
+// pass and bounded memory: null counts, distinct-value estimates, min/max,
+// value lengths, the most frequent values and an inferred type.
+package profile
+
+import (
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"sqlparser/pkg/models"
+)
+
+// Types inferred from column values.
+const (
+	TypeInteger  = "integer"
+	TypeDecimal  = "decimal"
+	TypeDate     = "date"
+	TypeDateTime = "datetime"
+	TypeString   = "string"
+	TypeNull     = "null" // every value is NULL
+)
+
+// lengthBuckets are the upper bounds of the length histogram buckets.
+var lengthBuckets = []int{0, 8, 16, 32, 64, 128, 255, 1024}
+
+// TableProfile is the profile of one table.
+type TableProfile struct {
+	Table   string          `json:"table"`
+	Rows    int64           `json:"rows"`
+	Columns []ColumnProfile `json:"columns"`
+}
+
+// ColumnProfile holds the statistics of one column. Distinct is an estimate
+// with a standard error of about 1%. TopValues counts are lower bounds: for
+// columns with many distinct values they may be too low, and values seen only
+// once may be missing.
+type ColumnProfile struct {
+	Name         string       `json:"name"`
+	DeclaredType string       `json:"declared_type,omitempty"`
+	InferredType string       `json:"inferred_type"`
+	Nulls        int64        `json:"nulls"`
+	NullFraction float64      `json:"null_fraction"`
+	Distinct     uint64       `json:"distinct"`
+	Min          string       `json:"min,omitempty"`
+	Max          string       `json:"max,omitempty"`
+	Length       LengthStats  `json:"length"`
+	TopValues    []ValueCount `json:"top_values"`
+}
+
+// LengthStats describes the lengths, in characters, of the non-NULL values
+// of a column.
+type LengthStats struct {
+	Min       int           `json:"min"`
+	Max       int           `json:"max"`
+	Mean      float64       `json:"mean"`
+	Histogram []LengthCount `json:"histogram"`
+}
+
+// LengthCount is the number of values whose length is in Range, e.g. "9-16".
+type LengthCount struct {
+	Range string `json:"range"`
+	Count int64  `json:"count"`
+}
+
+// ValueCount is a value and the number of rows it appears in.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Profiler accumulates the profile of a table from its rows.
+type Profiler struct {
+	table   string
+	topK    int
+	rows    int64
+	columns []*columnStats
+	byName  map[string]*columnStats
+}
+
+// New returns a Profiler for table that reports the topK most frequent values
+// of every column. The columns of schema are profiled in declaration order;
+// schema may be nil, in which case columns are taken from the rows.
+func New(table string, schema *models.Schema, topK int) *Profiler {
+	p := &Profiler{table: table, topK: topK, byName: make(map[string]*columnStats)}
+	if schema != nil {
+		for _, col := range schema.Columns {
+			p.column(col.Name).declaredType = col.Type
+		}
+	}
+	return p
+}
+
+func (p *Profiler) column(name string) *columnStats {
+	c, ok := p.byName[name]
+	if !ok {
+		c = newColumnStats(name, p.topK)
+		p.byName[name] = c
+		p.columns = append(p.columns, c)
+	}
+	return c
+}
+
+// Add adds rows to the profile.
+func (p *Profiler) Add(rows []models.Row) {
+	for _, row := range rows {
+		p.rows++
+		if len(p.columns) < len(row.Data) {
+			p.addColumns(row)
+		}
+		for _, c := range p.columns {
+			value, ok := row.Data[c.name].(string)
+			if !ok {
+				c.nulls++
+				continue
+			}
+			c.add(value)
+		}
+	}
+}
+
+// addColumns adds the columns of row that aren't known yet, in name order
+// since rows don't keep the column order. Earlier rows lacked them, so they
+// count as NULL there.
+func (p *Profiler) addColumns(row models.Row) {
+	var names []string
+	for name := range row.Data {
+		if _, ok := p.byName[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p.column(name).nulls = p.rows - 1
+	}
+}
+
+// Profile returns the profile of the rows added so far.
+func (p *Profiler) Profile() *TableProfile {
+	profile := &TableProfile{Table: p.table, Rows: p.rows, Columns: []ColumnProfile{}}
+	for _, c := range p.columns {
+		profile.Columns = append(profile.Columns, c.profile(p.rows, p.topK))
+	}
+	return profile
+}
+
+type columnStats struct {
+	name         string
+	declaredType string
+	count        int64 // non-NULL values
+	nulls        int64
+
+	distinct hyperLogLog
+	top      *topK
+
+	// Values are compared as numbers while they all parse as numbers, which
+	// also makes the column decimal
+	numeric              bool
+	minNum, maxNum       float64
+	minNumStr, maxNumStr string
+	minStr, maxStr       string
+
+	minLen, maxLen int
+	totalLen       int64
+	histogram      []int64
+
+	// Whether every value so far has the type
+	integers, dates, datetimes bool
+}
+
+func newColumnStats(name string, topK int) *columnStats {
+	return &columnStats{
+		name:      name,
+		top:       newTopK(topK),
+		numeric:   true,
+		histogram: make([]int64, len(lengthBuckets)+1),
+		integers:  true,
+		dates:     true,
+		datetimes: true,
+	}
+}
+
+func (c *columnStats) add(value string) {
+	first := c.count == 0
+	c.count++
+	c.distinct.add(value)
+	c.top.add(value)
+
+	if first || value < c.minStr {
+		c.minStr = value
+	}
+	if first || value > c.maxStr {
+		c.maxStr = value
+	}
+
+	length := utf8.RuneCountInString(value)
+	if first || length < c.minLen {
+		c.minLen = length
+	}
+	if length > c.maxLen {
+		c.maxLen = length
+	}
+	c.totalLen += int64(length)
+	c.histogram[sort.SearchInts(lengthBuckets, length)]++
+
+	if c.integers {
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			c.integers = false
+		}
+	}
+	if c.numeric {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			c.numeric = false
+		} else {
+			if first || n < c.minNum {
+				c.minNum, c.minNumStr = n, value
+			}
+			if first || n > c.maxNum {
+				c.maxNum, c.maxNumStr = n, value
+			}
+		}
+	}
+	if c.dates || c.datetimes {
+		date, datetime := dateKind(value)
+		c.dates = c.dates && date
+		c.datetimes = c.datetimes && (date || datetime)
+	}
+}
+
+func (c *columnStats) profile(rows int64, topK int) ColumnProfile {
+	p := ColumnProfile{
+		Name:         c.name,
+		DeclaredType: c.declaredType,
+		InferredType: c.inferredType(),
+		Nulls:        c.nulls,
+		Distinct:     c.distinct.estimate(),
+		TopValues:    c.top.top(topK),
+		Length:       LengthStats{Min: c.minLen, Max: c.maxLen, Histogram: []LengthCount{}},
+	}
+	if rows > 0 {
+		p.NullFraction = float64(c.nulls) / float64(rows)
+	}
+	if c.count == 0 {
+		return p
+	}
+
+	// The estimate can't exceed what was counted
+	if p.Distinct > uint64(c.count) {
+		p.Distinct = uint64(c.count)
+	}
+	if c.numeric {
+		p.Min, p.Max = c.minNumStr, c.maxNumStr
+	} else {
+		p.Min, p.Max = c.minStr, c.maxStr
+	}
+	p.Length.Mean = float64(c.totalLen) / float64(c.count)
+	for i, n := range c.histogram {
+		if n > 0 {
+			p.Length.Histogram = append(p.Length.Histogram, LengthCount{Range: bucketRange(i), Count: n})
+		}
+	}
+	return p
+}
+
+func (c *columnStats) inferredType() string {
+	switch {
+	case c.count == 0:
+		return TypeNull
+	case c.integers:
+		return TypeInteger
+	case c.numeric:
+		return TypeDecimal
+	case c.dates:
+		return TypeDate
+	case c.datetimes:
+		return TypeDateTime
+	default:
+		return TypeString
+	}
+}
+
+func bucketRange(i int) string {
+	switch {
+	case i == 0:
+		return "0"
+	case i == len(lengthBuckets):
+		return strconv.Itoa(lengthBuckets[i-1]+1) + "+"
+	}
+	return strconv.Itoa(lengthBuckets[i-1]+1) + "-" + strconv.Itoa(lengthBuckets[i])
+}
+
+var datetimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+// dateKind reports whether value is a date or a date and time, in the
+// formats databases dump them in.
+func dateKind(value string) (date, datetime bool) {
+	if len(value) == len("2006-01-02") {
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil, false
+	}
+	for _, layout := range datetimeLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return false, true
+		}
+	}
+	return false, false
+}
@@ -0,0 +1,100 @@
+package profile
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// topK finds the most frequent values with the Space-Saving algorithm: it
+// counts up to capacity distinct values and, when a new value arrives with
+// all counters taken, the least frequent one is replaced. The new value takes
+// over its count, which is remembered as the possible overestimation.
+type topK struct {
+	capacity int
+	counters map[string]*counter
+	heap     counterHeap // least frequent first
+}
+
+type counter struct {
+	value string
+	count int64
+	err   int64 // count inherited from the value it replaced
+	index int   // position in the heap
+}
+
+func newTopK(k int) *topK {
+	// Tracking more values than reported makes the top values reliable
+	capacity := k * 10
+	if capacity < 100 {
+		capacity = 100
+	}
+	return &topK{capacity: capacity, counters: make(map[string]*counter)}
+}
+
+func (t *topK) add(value string) {
+	if c, ok := t.counters[value]; ok {
+		c.count++
+		heap.Fix(&t.heap, c.index)
+		return
+	}
+	if len(t.heap) < t.capacity {
+		c := &counter{value: value, count: 1}
+		t.counters[value] = c
+		heap.Push(&t.heap, c)
+		return
+	}
+
+	c := t.heap[0]
+	delete(t.counters, c.value)
+	c.value = value
+	c.err = c.count
+	c.count++
+	t.counters[value] = c
+	heap.Fix(&t.heap, 0)
+}
+
+// top returns the k most frequent values, most frequent first. Counts are
+// lower bounds, exact unless the column has more distinct values than are
+// tracked; values whose frequency is unknown are left out.
+func (t *topK) top(k int) []ValueCount {
+	values := make([]ValueCount, 0, len(t.heap))
+	for _, c := range t.heap {
+		if c.count-c.err > 1 || c.err == 0 {
+			values = append(values, ValueCount{Value: c.value, Count: c.count - c.err})
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	if len(values) > k {
+		values = values[:k]
+	}
+	return values
+}
+
+type counterHeap []*counter
+
+func (h counterHeap) Len() int           { return len(h) }
+func (h counterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *counterHeap) Push(x interface{}) {
+	c := x.(*counter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *counterHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,213 @@
+// Package progress reports how far processing of a dump file has got. On a
+// terminal it draws a progress bar that is updated in place; otherwise it
+// logs the status at a fixed interval, which suits log files and CI.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	barWidth    = 30
+	ttyInterval = 200 * time.Millisecond
+	logInterval = 10 * time.Second
+)
+
+// Source reports the position reached in the input file and its size, both in
+// bytes of the file on disk.
+type Source interface {
+	Position() int64
+	Size() int64
+}
+
+// Reporter tracks the bytes read and rows processed for one table at a time.
+// A nil Reporter reports nothing, so callers don't need to check for one.
+type Reporter struct {
+	out      io.Writer
+	tty      bool
+	interval time.Duration
+
+	mu         sync.Mutex
+	table      string
+	source     Source
+	start      time.Time
+	startPos   int64
+	rows       int64 // accessed atomically
+	stop, done chan struct{}
+}
+
+// New returns a Reporter that draws a progress bar on out if it is a
+// terminal, and logs the status otherwise.
+func New(out *os.File) *Reporter {
+	tty := term.IsTerminal(int(out.Fd()))
+	interval := logInterval
+	if tty {
+		interval = ttyInterval
+	}
+	return &Reporter{out: out, tty: tty, interval: interval}
+}
+
+// StartTable starts reporting on table, which is read from source starting at
+// position from.
+func (r *Reporter) StartTable(table string, source Source, from int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.table = table
+	r.source = source
+	r.start = time.Now()
+	r.startPos = from
+	atomic.StoreInt64(&r.rows, 0)
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	r.mu.Unlock()
+
+	go r.run(r.stop, r.done)
+}
+
+// AddRows records that n more rows of the current table were processed.
+func (r *Reporter) AddRows(n int) {
+	if r == nil {
+		return
+	}
+	atomic.AddInt64(&r.rows, int64(n))
+}
+
+// EndTable stops reporting on the current table and writes a final status.
+func (r *Reporter) EndTable() {
+	if r == nil || r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+	r.stop = nil
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tty {
+		fmt.Fprintf(r.out, "\r%s\x1b[K\n", r.bar())
+	} else {
+		r.log()
+	}
+}
+
+func (r *Reporter) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			if r.tty {
+				fmt.Fprintf(r.out, "\r%s\x1b[K", r.bar())
+			} else {
+				r.log()
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+// snapshot is the state of the current table at one point in time.
+type snapshot struct {
+	pos, size    int64
+	rows         int64
+	bytesPerSec  float64
+	rowsPerSec   float64
+	eta          time.Duration
+	etaKnown     bool
+	fractionDone float64
+}
+
+func (r *Reporter) snapshot() snapshot {
+	s := snapshot{
+		pos:  r.source.Position(),
+		size: r.source.Size(),
+		rows: atomic.LoadInt64(&r.rows),
+	}
+	if s.pos > s.size {
+		s.pos = s.size
+	}
+	if s.size > 0 {
+		s.fractionDone = float64(s.pos) / float64(s.size)
+	}
+
+	elapsed := time.Since(r.start).Seconds()
+	if elapsed > 0 {
+		s.bytesPerSec = float64(s.pos-r.startPos) / elapsed
+		s.rowsPerSec = float64(s.rows) / elapsed
+	}
+	if s.bytesPerSec > 0 {
+		s.eta = time.Duration(float64(s.size-s.pos) / s.bytesPerSec * float64(time.Second))
+		s.etaKnown = true
+	}
+	return s
+}
+
+// bar renders the status as a single terminal line.
+func (r *Reporter) bar() string {
+	s := r.snapshot()
+	filled := int(s.fractionDone * barWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	return fmt.Sprintf("%s [%s] %5.1f%% %s/%s %s/s %s rows/s ETA %s",
+		r.table, bar, s.fractionDone*100, FormatBytes(s.pos), FormatBytes(s.size),
+		FormatBytes(int64(s.bytesPerSec)), formatCount(s.rowsPerSec), formatETA(s))
+}
+
+// log writes the status to the default logger.
+func (r *Reporter) log() {
+	s := r.snapshot()
+	slog.Info("progress", "table", r.table,
+		"percent", math.Round(s.fractionDone*1000)/10,
+		"bytes", s.pos, "size", s.size, "rows", s.rows,
+		"bytes_per_sec", int64(s.bytesPerSec), "rows_per_sec", int64(s.rowsPerSec),
+		"eta", formatETA(s))
+}
+
+func formatETA(s snapshot) string {
+	if !s.etaKnown {
+		return "unknown"
+	}
+	return s.eta.Round(time.Second).String()
+}
+
+// FormatBytes formats a byte count in binary units, such as "1.5 MB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatCount(n float64) string {
+	switch {
+	case n >= 1e6:
+		return fmt.Sprintf("%.1fM", n/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1fk", n/1e3)
+	}
+	return fmt.Sprintf("%.0f", n)
+}
@@ -0,0 +1,122 @@
+package progress
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixedSource is a Source at a fixed position.
+type fixedSource struct{ pos, size int64 }
+
+func (s fixedSource) Position() int64 { return s.pos }
+func (s fixedSource) Size() int64     { return s.size }
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{5 << 20, "5.0 MB"},
+		{3 << 30, "3.0 GB"},
+		{1 << 40, "1.0 TB"},
+	}
+	for _, tt := range tests {
+		if got := FormatBytes(tt.n); got != tt.want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	tests := []struct {
+		n    float64
+		want string
+	}{
+		{0, "0"},
+		{999.4, "999"},
+		{1500, "1.5k"},
+		{2500000, "2.5M"},
+	}
+	for _, tt := range tests {
+		if got := formatCount(tt.n); got != tt.want {
+			t.Errorf("formatCount(%v) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   fixedSource
+		from     int64
+		rows     int64
+		fraction float64
+		eta      string
+	}{
+		// 400 bytes in 4s leaves 500 bytes, or 5s, to go
+		{name: "halfway", source: fixedSource{pos: 500, size: 1000}, from: 100, rows: 40, fraction: 0.5, eta: "5s"},
+		{name: "nothing read yet", source: fixedSource{pos: 100, size: 1000}, from: 100, fraction: 0.1, eta: "unknown"},
+		{name: "past the end", source: fixedSource{pos: 1200, size: 1000}, rows: 8, fraction: 1, eta: "0s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Reporter{source: tt.source, start: time.Now().Add(-4 * time.Second), startPos: tt.from, rows: tt.rows}
+			s := r.snapshot()
+			if s.fractionDone != tt.fraction {
+				t.Errorf("fraction done = %v, want %v", s.fractionDone, tt.fraction)
+			}
+			if got := formatETA(s); got != tt.eta {
+				t.Errorf("ETA = %s, want %s", got, tt.eta)
+			}
+			if rate := s.rowsPerSec * 4; rate < float64(tt.rows)*0.9 || rate > float64(tt.rows)*1.1 {
+				t.Errorf("rows per second = %v, want about %v", s.rowsPerSec, float64(tt.rows)/4)
+			}
+		})
+	}
+}
+
+func TestReporter(t *testing.T) {
+	t.Run("terminal", func(t *testing.T) {
+		var out bytes.Buffer
+		r := &Reporter{out: &out, tty: true, interval: time.Hour}
+		r.StartTable("users", fixedSource{pos: 250, size: 1000}, 0)
+		r.AddRows(10)
+		r.EndTable()
+		got := out.String()
+		if !strings.HasPrefix(got, "\rusers [=======>                      ]  25.0% 250 B/1000 B ") || !strings.HasSuffix(got, "\x1b[K\n") {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("log", func(t *testing.T) {
+		var out bytes.Buffer
+		defer slog.SetDefault(slog.Default())
+		slog.SetDefault(slog.New(slog.NewTextHandler(&out, nil)))
+
+		r := &Reporter{tty: false, interval: time.Hour}
+		r.StartTable("users", fixedSource{pos: 1000, size: 1000}, 0)
+		r.AddRows(3)
+		r.AddRows(4)
+		r.EndTable()
+		got := out.String()
+		for _, want := range []string{"msg=progress", "table=users", "percent=100", "bytes=1000", "size=1000", "rows=7", "eta=0s"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("log %q lacks %s", got, want)
+			}
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var r *Reporter
+		r.StartTable("users", fixedSource{}, 0)
+		r.AddRows(1)
+		r.EndTable()
+	})
+}
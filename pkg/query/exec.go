@@ -0,0 +1,955 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Source provides the rows of the tables a query reads.
+type Source interface {
+	// Scan calls columns with the column names of table and their SQL types,
+	// or nil types if they aren't known, then fn with each of its rows until
+	// fn returns an error, which Scan returns. fn may keep the rows it is
+	// passed.
+	Scan(table string, columns func(names, types []string) error, fn func(row map[string]interface{}) error) error
+}
+
+// errStop ends the scan of the FROM table once the query has all the rows it
+// needs.
+var errStop = errors.New("query: enough rows")
+
+// Run executes the query over the tables of src. It calls columns with the
+// names of the result's columns and then emit with each result row.
+//
+// The rows of the FROM table are processed as Scan delivers them. Without
+// ORDER BY, GROUP BY or aggregates, result rows are emitted right away and
+// the scan stops once LIMIT is reached; otherwise they are emitted at the end.
+// Joined tables are read into memory first, so the largest table of a join
+// belongs in FROM. Joins whose ON clause compares a column of the joined
+// table with an earlier table use a hash lookup instead of scanning the
+// joined table for every row.
+//
+// As in MySQL, columns that are neither grouped nor aggregated take their
+// value from the first row of the group.
+func (s *Select) Run(src Source, args []interface{}, columns func(names []string) error, emit func(values []interface{}) error) error {
+	e := &execution{sel: s, args: args, emit: emit, skip: s.Offset, remaining: s.Limit}
+	for i, join := range s.Joins {
+		t := &scopeTable{name: join.name(), join: &s.Joins[i]}
+		err := src.Scan(join.Table, func(names, types []string) error {
+			t.setColumns(names, types)
+			return nil
+		}, func(row map[string]interface{}) error {
+			t.rows = append(t.rows, row)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		e.joined = append(e.joined, t)
+	}
+
+	planned := false
+	err := src.Scan(s.From, func(names, types []string) error {
+		if err := e.plan(names, types); err != nil {
+			return err
+		}
+		planned = true
+		return columns(e.names)
+	}, e.row)
+	if err != nil && !errors.Is(err, errStop) {
+		return err
+	}
+	if !planned {
+		return fmt.Errorf("no columns found for table %q", s.From)
+	}
+	return e.finish()
+}
+
+// scopeTable is a table of the FROM clause.
+type scopeTable struct {
+	name    string // alias or table name
+	columns []string
+	has     map[string]bool
+	types   map[string]string // SQL types of the columns, if known
+
+	// Joined tables only
+	join  *Join
+	rows  []map[string]interface{}
+	index map[string][]int // rows by the value of a column in an equality of ON
+	probe Expr             // the other side of that equality
+}
+
+func (t *scopeTable) setColumns(names, types []string) {
+	t.columns = names
+	t.has = make(map[string]bool, len(names))
+	t.types = make(map[string]string, len(types))
+	for i, name := range names {
+		t.has[name] = true
+		if i < len(types) {
+			t.types[name] = types[i]
+		}
+	}
+}
+
+type execution struct {
+	sel  *Select
+	args []interface{}
+	emit func([]interface{}) error
+
+	tables  []*scopeTable // the FROM table followed by the joined ones
+	joined  []*scopeTable
+	unique  map[string]bool // column names that only one table has
+	names   []string
+	fields  []Expr         // the SELECT list with * expanded
+	aliases map[string]int // output columns by alias
+	groupBy []Expr
+	order   []orderKey
+	aggs    []*FuncCall
+	approx  []bool // aggregates over a FLOAT or DOUBLE column
+	grouped bool
+
+	groups    map[string]*group
+	groupList []*group      // in order of appearance
+	results   []result      // rows waiting for ORDER BY
+	cutoff    []interface{} // sort keys of the last row LIMIT keeps so far
+	seen      map[string]bool
+	skip      int // rows still to skip for OFFSET
+	remaining int // rows still to emit, -1 without LIMIT
+}
+
+type orderKey struct {
+	expr   Expr
+	column int // output column the key is, or -1 to evaluate expr
+	desc   bool
+}
+
+type result struct {
+	values []interface{}
+	keys   []interface{}
+}
+
+type group struct {
+	row  map[string]interface{} // the group's first row
+	aggs []*aggState
+}
+
+// plan checks the query against the columns of its tables and prepares it
+// for execution.
+func (e *execution) plan(fromColumns, fromTypes []string) error {
+	from := &scopeTable{name: e.sel.name()}
+	from.setColumns(fromColumns, fromTypes)
+	e.tables = append([]*scopeTable{from}, e.joined...)
+
+	names := make(map[string]bool)
+	count := make(map[string]int)
+	for _, t := range e.tables {
+		if names[t.name] {
+			return fmt.Errorf("table name %q is used twice, give one of them an alias", t.name)
+		}
+		names[t.name] = true
+		for _, col := range t.columns {
+			count[col]++
+		}
+	}
+	e.unique = make(map[string]bool, len(count))
+	for col, n := range count {
+		e.unique[col] = n == 1
+	}
+
+	if err := e.planFields(); err != nil {
+		return err
+	}
+	for i, t := range e.joined {
+		if err := e.check(t.join.On, "ON", i+2, false, nil); err != nil {
+			return err
+		}
+		e.planJoin(i+1, t)
+	}
+	if err := e.check(e.sel.Where, "WHERE", len(e.tables), false, nil); err != nil {
+		return err
+	}
+	if err := e.planGroupBy(); err != nil {
+		return err
+	}
+	if err := e.check(e.sel.Having, "HAVING", len(e.tables), true, e.aliases); err != nil {
+		return err
+	}
+	if err := e.planOrderBy(); err != nil {
+		return err
+	}
+
+	// Aggregates are computed once per distinct call
+	calls := make(map[string]bool)
+	for _, expr := range append(append(append([]Expr{}, e.fields...), e.sel.Having), orderExprs(e.order)...) {
+		walk(expr, func(x Expr) {
+			if call, ok := x.(*FuncCall); ok && !calls[call.String()] {
+				calls[call.String()] = true
+				e.aggs = append(e.aggs, call)
+				e.approx = append(e.approx, e.approximate(call))
+			}
+		})
+	}
+	e.grouped = len(e.groupBy) > 0 || len(e.aggs) > 0
+	if e.sel.Having != nil && !e.grouped {
+		return errors.New("HAVING requires GROUP BY or an aggregate")
+	}
+	if e.grouped {
+		e.groups = make(map[string]*group)
+	}
+	if e.sel.Distinct {
+		e.seen = make(map[string]bool)
+	}
+	return nil
+}
+
+// approximate reports whether call aggregates a FLOAT or DOUBLE column, whose
+// values are summed as floating point numbers rather than exactly.
+func (e *execution) approximate(call *FuncCall) bool {
+	if call.Star || len(call.Args) == 0 {
+		return false
+	}
+	ref, ok := call.Args[0].(*ColumnRef)
+	if !ok {
+		return false
+	}
+	i, err := e.resolve(ref)
+	if err != nil {
+		return false
+	}
+	t := strings.ToLower(e.tables[i].types[ref.Name])
+	return strings.HasPrefix(t, "float") || strings.HasPrefix(t, "double") || strings.HasPrefix(t, "real")
+}
+
+// planFields expands * and names the output columns.
+func (e *execution) planFields() error {
+	var qualifiers []string // table of columns that came from *, to tell them apart
+	e.aliases = make(map[string]int)
+	for _, field := range e.sel.Fields {
+		if field.Expr != nil {
+			if field.Alias != "" {
+				e.aliases[field.Alias] = len(e.fields)
+			}
+			if err := e.check(field.Expr, "field list", len(e.tables), true, nil); err != nil {
+				return err
+			}
+			e.fields = append(e.fields, field.Expr)
+			e.names = append(e.names, field.Name())
+			qualifier := ""
+			if ref, ok := field.Expr.(*ColumnRef); ok && field.Alias == "" {
+				if i, err := e.resolve(ref); err == nil {
+					qualifier = e.tables[i].name
+				}
+			}
+			qualifiers = append(qualifiers, qualifier)
+			continue
+		}
+
+		found := false
+		for _, t := range e.tables {
+			if field.Table != "" && t.name != field.Table {
+				continue
+			}
+			found = true
+			for _, col := range t.columns {
+				if len(e.tables) == 1 {
+					// Rows of a single table are used as they are
+					e.fields = append(e.fields, &ColumnRef{Name: col})
+				} else {
+					e.fields = append(e.fields, &ColumnRef{Table: t.name, Name: col})
+				}
+				e.names = append(e.names, col)
+				qualifiers = append(qualifiers, t.name)
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown table %q in %s.*", field.Table, field.Table)
+		}
+	}
+
+	// Columns of the same name from different tables are qualified
+	count := make(map[string]int)
+	for _, name := range e.names {
+		count[name]++
+	}
+	for i, name := range e.names {
+		if count[name] > 1 && qualifiers[i] != "" {
+			e.names[i] = qualifiers[i] + "." + name
+		}
+	}
+	return nil
+}
+
+// planJoin sets up a hash lookup for the joined table i if its ON clause
+// has an equality between an expression over the table and one over the
+// tables before it.
+func (e *execution) planJoin(i int, t *scopeTable) {
+	for _, cond := range conjuncts(t.join.On) {
+		eq, ok := cond.(*BinaryExpr)
+		if !ok || eq.Op != "=" {
+			continue
+		}
+		for _, sides := range [][2]Expr{{eq.Left, eq.Right}, {eq.Right, eq.Left}} {
+			key, probe := sides[0], sides[1]
+			if lo, hi, ok := e.tableRange(key); !ok || lo != i || hi != i {
+				continue
+			}
+			if _, hi, ok := e.tableRange(probe); ok && hi >= i {
+				continue
+			}
+
+			t.index = make(map[string][]int)
+			for n, row := range t.rows {
+				if k, ok := hashKey(key.Eval(e.combine(nil, t, row), e.args)); ok {
+					t.index[k] = append(t.index[k], n)
+				}
+			}
+			t.probe = probe
+			return
+		}
+	}
+}
+
+// tableRange returns the lowest and highest index of the tables expr refers
+// to; ok is false if it refers to none.
+func (e *execution) tableRange(expr Expr) (lo, hi int, ok bool) {
+	walk(expr, func(x Expr) {
+		ref, isRef := x.(*ColumnRef)
+		if !isRef {
+			return
+		}
+		i, err := e.resolve(ref)
+		if err != nil {
+			return
+		}
+		if !ok || i < lo {
+			lo = i
+		}
+		if !ok || i > hi {
+			hi = i
+		}
+		ok = true
+	})
+	return lo, hi, ok
+}
+
+// planGroupBy resolves GROUP BY entries that are output positions or
+// aliases to the expressions of the output columns.
+func (e *execution) planGroupBy() error {
+	for _, expr := range e.sel.GroupBy {
+		if i, ok, err := e.outputColumn(expr, "GROUP BY"); err != nil {
+			return err
+		} else if ok {
+			if walkAny(e.fields[i], isAggregate) {
+				return fmt.Errorf("can't group by aggregate %s", e.fields[i])
+			}
+			e.groupBy = append(e.groupBy, e.fields[i])
+			continue
+		}
+		if err := e.check(expr, "GROUP BY", len(e.tables), false, nil); err != nil {
+			return err
+		}
+		e.groupBy = append(e.groupBy, expr)
+	}
+	return nil
+}
+
+func (e *execution) planOrderBy() error {
+	for _, item := range e.sel.OrderBy {
+		key := orderKey{expr: item.Expr, column: -1, desc: item.Desc}
+		if i, ok, err := e.outputColumn(item.Expr, "ORDER BY"); err != nil {
+			return err
+		} else if ok {
+			key.column = i
+		} else if err := e.check(item.Expr, "ORDER BY", len(e.tables), true, nil); err != nil {
+			return err
+		}
+		e.order = append(e.order, key)
+	}
+	return nil
+}
+
+// outputColumn reports whether expr is the position (starting at 1) or the
+// name of an output column, and which.
+func (e *execution) outputColumn(expr Expr, clause string) (int, bool, error) {
+	switch x := expr.(type) {
+	case *Literal:
+		if n, ok := x.Value.(int64); ok {
+			if n < 1 || int(n) > len(e.fields) {
+				return 0, false, fmt.Errorf("unknown column %d in %s", n, clause)
+			}
+			return int(n) - 1, true, nil
+		}
+	case *ColumnRef:
+		if x.Table != "" {
+			return 0, false, nil
+		}
+		if i, ok := e.aliases[x.Name]; ok {
+			return i, true, nil
+		}
+		if _, err := e.resolve(x); err != nil {
+			for i, name := range e.names {
+				if name == x.Name {
+					return i, true, nil
+				}
+			}
+		}
+	}
+	return 0, false, nil
+}
+
+// resolve returns the index of the table a column reference refers to.
+func (e *execution) resolve(ref *ColumnRef) (int, error) {
+	if ref.Table != "" {
+		for i, t := range e.tables {
+			if t.name == ref.Table {
+				if !t.has[ref.Name] {
+					return -1, fmt.Errorf("unknown column %q", ref.String())
+				}
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("unknown table %q in column %q", ref.Table, ref.String())
+	}
+
+	found := -1
+	for i, t := range e.tables {
+		if t.has[ref.Name] {
+			if found >= 0 {
+				return -1, fmt.Errorf("column %q is ambiguous", ref.Name)
+			}
+			found = i
+		}
+	}
+	if found < 0 {
+		return -1, fmt.Errorf("unknown column %q", ref.Name)
+	}
+	return found, nil
+}
+
+// check checks that the columns expr refers to exist in the first scope
+// tables, or are among aliases, and that it only has aggregates if they are
+// allowed in clause.
+func (e *execution) check(expr Expr, clause string, scope int, aggregates bool, aliases map[string]int) error {
+	var err error
+	walk(expr, func(x Expr) {
+		if err != nil {
+			return
+		}
+		switch x := x.(type) {
+		case *ColumnRef:
+			i, rerr := e.resolve(x)
+			if rerr != nil {
+				if _, ok := aliases[x.Name]; ok && x.Table == "" {
+					return
+				}
+				err = fmt.Errorf("%v in %s", rerr, clause)
+			} else if i >= scope {
+				err = fmt.Errorf("column %q in %s refers to a table joined after it", x.String(), clause)
+			}
+		case *FuncCall:
+			if !aggregates {
+				err = fmt.Errorf("aggregate %s is not allowed in %s", x, clause)
+				return
+			}
+			for _, arg := range x.Args {
+				if walkAny(arg, isAggregate) {
+					err = fmt.Errorf("aggregate %s has an aggregate argument", x)
+				}
+			}
+		}
+	})
+	return err
+}
+
+// row processes a row of the FROM table.
+func (e *execution) row(row map[string]interface{}) error {
+	if len(e.tables) == 1 {
+		return e.process(row)
+	}
+	return e.join(1, e.combine(nil, e.tables[0], row))
+}
+
+// combine adds the columns of a row of t to the combined row of a join,
+// under their qualified names and, if no other table has them, their own.
+// A nil row adds NULLs.
+func (e *execution) combine(into map[string]interface{}, t *scopeTable, row map[string]interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(into)+2*len(t.columns))
+	for k, v := range into {
+		m[k] = v
+	}
+	for _, col := range t.columns {
+		v := row[col]
+		m[t.name+"."+col] = v
+		if e.unique[col] {
+			m[col] = v
+		}
+	}
+	return m
+}
+
+// join matches a combined row with the rows of the joined table i.
+func (e *execution) join(i int, row map[string]interface{}) error {
+	if i == len(e.tables) {
+		return e.process(row)
+	}
+	t := e.tables[i]
+
+	try := func(n int) (bool, error) {
+		combined := e.combine(row, t, t.rows[n])
+		if truth(t.join.On.Eval(combined, e.args)) != true {
+			return false, nil
+		}
+		return true, e.join(i+1, combined)
+	}
+
+	matched := false
+	if t.index != nil {
+		if k, ok := hashKey(t.probe.Eval(row, e.args)); ok {
+			for _, n := range t.index[k] {
+				ok, err := try(n)
+				if err != nil {
+					return err
+				}
+				matched = matched || ok
+			}
+		}
+	} else {
+		for n := range t.rows {
+			ok, err := try(n)
+			if err != nil {
+				return err
+			}
+			matched = matched || ok
+		}
+	}
+
+	if !matched && t.join.Left {
+		return e.join(i+1, e.combine(row, t, nil))
+	}
+	return nil
+}
+
+// process handles a row with the columns of every table.
+func (e *execution) process(row map[string]interface{}) error {
+	if e.remaining == 0 && !e.grouped && e.order == nil {
+		return errStop
+	}
+	if e.sel.Where != nil && truth(e.sel.Where.Eval(row, e.args)) != true {
+		return nil
+	}
+
+	if e.grouped {
+		e.accumulate(row)
+		return nil
+	}
+
+	values := e.project(row)
+	if e.duplicate(values) {
+		return nil
+	}
+	if e.order == nil {
+		return e.output(values)
+	}
+	keys := e.sortKeys(row, values)
+	if e.cutoff != nil && e.compareKeys(keys, e.cutoff) >= 0 {
+		return nil
+	}
+	e.results = append(e.results, result{values: values, keys: keys})
+
+	// With a LIMIT only the first rows in order are needed
+	if n := e.sel.Offset + e.sel.Limit; e.sel.Limit >= 0 && len(e.results) >= 2*n+1024 {
+		e.sortResults()
+		e.results = e.results[:n]
+		if n > 0 {
+			e.cutoff = e.results[n-1].keys
+		}
+	}
+	return nil
+}
+
+func (e *execution) accumulate(row map[string]interface{}) {
+	key := ""
+	if len(e.groupBy) > 0 {
+		values := make([]interface{}, len(e.groupBy))
+		for i, expr := range e.groupBy {
+			values[i] = expr.Eval(row, e.args)
+		}
+		key = encodeKey(values)
+	}
+
+	g, ok := e.groups[key]
+	if !ok {
+		g = e.newGroup(row)
+		e.groups[key] = g
+		e.groupList = append(e.groupList, g)
+	}
+	for i, call := range e.aggs {
+		g.aggs[i].add(call, row, e.args)
+	}
+}
+
+func (e *execution) newGroup(row map[string]interface{}) *group {
+	g := &group{row: make(map[string]interface{}, len(row)+len(e.aggs)), aggs: make([]*aggState, len(e.aggs))}
+	for k, v := range row {
+		g.row[k] = v
+	}
+	for i := range g.aggs {
+		g.aggs[i] = &aggState{approx: e.approx[i]}
+	}
+	return g
+}
+
+// finish emits the rows of groups and those waiting for ORDER BY.
+func (e *execution) finish() error {
+	if e.grouped {
+		if len(e.groupList) == 0 && len(e.groupBy) == 0 {
+			// Aggregates over no rows still give a row
+			e.groupList = append(e.groupList, e.newGroup(nil))
+		}
+		for _, g := range e.groupList {
+			row := g.row
+			for i, call := range e.aggs {
+				row[call.String()] = g.aggs[i].result(call)
+			}
+			for alias, i := range e.aliases {
+				if _, ok := row[alias]; !ok {
+					row[alias] = e.fields[i].Eval(row, e.args)
+				}
+			}
+			if e.sel.Having != nil && truth(e.sel.Having.Eval(row, e.args)) != true {
+				continue
+			}
+
+			values := e.project(row)
+			if e.duplicate(values) {
+				continue
+			}
+			if e.order == nil {
+				if err := e.output(values); err != nil {
+					if errors.Is(err, errStop) {
+						return nil
+					}
+					return err
+				}
+				continue
+			}
+			e.results = append(e.results, result{values: values, keys: e.sortKeys(row, values)})
+		}
+	}
+
+	e.sortResults()
+	for _, r := range e.results {
+		if err := e.output(r.values); err != nil {
+			if errors.Is(err, errStop) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *execution) project(row map[string]interface{}) []interface{} {
+	values := make([]interface{}, len(e.fields))
+	for i, field := range e.fields {
+		values[i] = field.Eval(row, e.args)
+	}
+	return values
+}
+
+// duplicate reports whether a SELECT DISTINCT has already had values.
+func (e *execution) duplicate(values []interface{}) bool {
+	if e.seen == nil {
+		return false
+	}
+	key := encodeKey(values)
+	if e.seen[key] {
+		return true
+	}
+	e.seen[key] = true
+	return false
+}
+
+// output emits a result row, applying OFFSET and LIMIT. It returns errStop
+// once the last row has been emitted.
+func (e *execution) output(values []interface{}) error {
+	if e.remaining == 0 {
+		return errStop
+	}
+	if e.skip > 0 {
+		e.skip--
+		return nil
+	}
+	if err := e.emit(values); err != nil {
+		return err
+	}
+	if e.remaining > 0 {
+		e.remaining--
+		if e.remaining == 0 {
+			return errStop
+		}
+	}
+	return nil
+}
+
+func (e *execution) sortKeys(row map[string]interface{}, values []interface{}) []interface{} {
+	keys := make([]interface{}, len(e.order))
+	for i, key := range e.order {
+		if key.column >= 0 {
+			keys[i] = values[key.column]
+		} else {
+			keys[i] = key.expr.Eval(row, e.args)
+		}
+	}
+	return keys
+}
+
+// sortResults sorts the rows waiting for ORDER BY. NULLs sort first.
+func (e *execution) sortResults() {
+	if e.order == nil {
+		return
+	}
+	sort.SliceStable(e.results, func(i, j int) bool {
+		return e.compareKeys(e.results[i].keys, e.results[j].keys) < 0
+	})
+}
+
+// compareKeys orders rows by their ORDER BY keys.
+func (e *execution) compareKeys(a, b []interface{}) int {
+	for k, key := range e.order {
+		c := compareOrder(a[k], b[k])
+		if c == 0 {
+			continue
+		}
+		if key.desc {
+			return -c
+		}
+		return c
+	}
+	return 0
+}
+
+func compareOrder(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	c, _ := compare(a, b)
+	return c
+}
+
+// aggState accumulates an aggregate over the rows of a group. SUM and AVG
+// add integers and decimals exactly, and only fall back to floating point for
+// FLOAT and DOUBLE columns and values that aren't decimal numbers.
+type aggState struct {
+	count    int64
+	intSum   int64
+	decSum   *big.Rat // sum of the values with a fractional part, nil if none
+	scale    int      // most digits after the decimal point in decSum
+	floatSum float64
+	float    bool        // SUM has had a floating point value
+	approx   bool        // the argument is a FLOAT or DOUBLE column
+	value    interface{} // MIN or MAX so far
+	seen     map[string]bool
+}
+
+func (a *aggState) add(call *FuncCall, row map[string]interface{}, args []interface{}) {
+	if call.Star {
+		a.count++
+		return
+	}
+	v := call.Args[0].Eval(row, args)
+	if v == nil {
+		return
+	}
+	if call.Distinct {
+		k, _ := hashKey(v)
+		if a.seen == nil {
+			a.seen = make(map[string]bool)
+		}
+		if a.seen[k] {
+			return
+		}
+		a.seen[k] = true
+	}
+
+	switch call.Name {
+	case "COUNT":
+		a.count++
+	case "SUM", "AVG":
+		// Values that aren't numbers are left out
+		if i, ok := toInt(v); ok && !a.approx {
+			a.count++
+			a.intSum += i
+			return
+		}
+		if d, scale, ok := parseDecimal(v); ok && !a.approx {
+			a.count++
+			if a.decSum == nil {
+				a.decSum = new(big.Rat)
+			}
+			a.decSum.Add(a.decSum, d)
+			if scale > a.scale {
+				a.scale = scale
+			}
+			return
+		}
+		f, ok := toFloat(v)
+		if !ok {
+			return
+		}
+		a.count++
+		a.floatSum += f
+		a.float = true
+	case "MIN", "MAX":
+		c, _ := compare(v, a.value)
+		if a.value == nil || call.Name == "MIN" && c < 0 || call.Name == "MAX" && c > 0 {
+			a.value = v
+		}
+	}
+}
+
+func (a *aggState) result(call *FuncCall) interface{} {
+	switch call.Name {
+	case "COUNT":
+		return a.count
+	case "SUM":
+		if a.count == 0 {
+			return nil
+		}
+		if a.float {
+			return a.floatTotal()
+		}
+		if a.decSum == nil {
+			return a.intSum
+		}
+		return a.exactTotal().FloatString(a.scale)
+	case "AVG":
+		if a.count == 0 {
+			return nil
+		}
+		if a.float || a.decSum == nil {
+			return a.floatTotal() / float64(a.count)
+		}
+		// With four more digits after the decimal point, as in MySQL
+		avg := a.exactTotal()
+		avg.Quo(avg, new(big.Rat).SetInt64(a.count))
+		return avg.FloatString(a.scale + 4)
+	}
+	return a.value
+}
+
+func (a *aggState) exactTotal() *big.Rat {
+	total := new(big.Rat).SetInt64(a.intSum)
+	if a.decSum != nil {
+		total.Add(total, a.decSum)
+	}
+	return total
+}
+
+func (a *aggState) floatTotal() float64 {
+	total := float64(a.intSum) + a.floatSum
+	if a.decSum != nil {
+		f, _ := a.decSum.Float64()
+		total += f
+	}
+	return total
+}
+
+// parseDecimal parses a string holding a decimal number without exponent,
+// such as a DECIMAL value of a dump, and returns it with the number of
+// digits after its decimal point.
+func parseDecimal(v interface{}) (*big.Rat, int, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, 0, false
+	}
+	s = strings.TrimSpace(s)
+	digits, scale, point := 0, 0, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			digits++
+			if point {
+				scale++
+			}
+		case c == '.' && !point:
+			point = true
+		case (c == '-' || c == '+') && i == 0:
+		default:
+			return nil, 0, false
+		}
+	}
+	if digits == 0 {
+		return nil, 0, false
+	}
+	d, ok := new(big.Rat).SetString(s)
+	return d, scale, ok
+}
+
+// encodeKey encodes values so that distinct lists of values give distinct
+// strings.
+func encodeKey(values []interface{}) string {
+	var b []byte
+	for _, v := range values {
+		if v == nil {
+			b = append(b, "N;"...)
+			continue
+		}
+		s := toString(v)
+		b = strconv.AppendInt(b, int64(len(s)), 10)
+		b = append(b, ':')
+		b = append(b, s...)
+	}
+	return string(b)
+}
+
+// hashKey returns a string that is the same for values that compare equal.
+// ok is false for NULL, which equals nothing.
+func hashKey(v interface{}) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+	if f, ok := toFloat(v); ok {
+		return "n" + strconv.FormatFloat(f, 'g', -1, 64), true
+	}
+	return "s" + toString(v), true
+}
+
+// conjuncts splits expr into the conditions it ANDs together.
+func conjuncts(expr Expr) []Expr {
+	if b, ok := expr.(*BinaryExpr); ok && b.Op == "AND" {
+		return append(conjuncts(b.Left), conjuncts(b.Right)...)
+	}
+	return []Expr{expr}
+}
+
+func orderExprs(keys []orderKey) []Expr {
+	exprs := make([]Expr, len(keys))
+	for i, key := range keys {
+		exprs[i] = key.expr
+	}
+	return exprs
+}
+
+func isAggregate(e Expr) bool {
+	_, ok := e.(*FuncCall)
+	return ok
+}
+
+// walkAny reports whether fn is true for e or any expression below it.
+func walkAny(e Expr, fn func(Expr) bool) bool {
+	found := false
+	walk(e, func(x Expr) {
+		found = found || fn(x)
+	})
+	return found
+}
@@ -0,0 +1,198 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// memTable is a table of memSource.
+type memTable struct {
+	names []string
+	types []string
+	rows  [][]interface{}
+}
+
+// memSource is a Source over tables held in memory.
+type memSource map[string]memTable
+
+func (s memSource) Scan(table string, columns func(names, types []string) error, fn func(row map[string]interface{}) error) error {
+	t, ok := s[table]
+	if !ok {
+		return fmt.Errorf("no table %s", table)
+	}
+	if err := columns(t.names, t.types); err != nil {
+		return err
+	}
+	for _, values := range t.rows {
+		row := make(map[string]interface{}, len(values))
+		for i, v := range values {
+			row[t.names[i]] = v
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// testSource has values as a dump holds them: strings, or nil for NULL.
+var testSource = memSource{
+	"users": {
+		names: []string{"id", "name", "country"},
+		types: []string{"int(11)", "varchar(10)", "varchar(2)"},
+		rows: [][]interface{}{
+			{"1", "ann", "de"},
+			{"2", "bob", "us"},
+			{"3", "cy", "de"},
+			{"4", "dee", nil},
+		},
+	},
+	"orders": {
+		names: []string{"id", "user_id", "price", "weight"},
+		types: []string{"int(11)", "int(11)", "decimal(10,2)", "double"},
+		rows: [][]interface{}{
+			{"1", "1", "0.10", "0.1"},
+			{"2", "1", "0.10", "0.2"},
+			{"3", "2", "0.05", "0.3"},
+			{"4", "9", "1.00", nil},
+		},
+	},
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		args  []interface{}
+		want  string // column names, then a line per row
+	}{
+		{
+			name:  "where and limit",
+			query: "SELECT name FROM users WHERE id > ? LIMIT 2",
+			args:  []interface{}{int64(1)},
+			want:  "name\nbob\ncy",
+		},
+		{
+			name:  "join",
+			query: "SELECT u.name, o.id FROM orders o JOIN users u ON u.id = o.user_id ORDER BY o.id",
+			want:  "name|id\nann|1\nann|2\nbob|3",
+		},
+		{
+			name:  "left join",
+			query: "SELECT u.name, COUNT(o.id) AS n FROM users u LEFT JOIN orders o ON o.user_id = u.id GROUP BY u.name ORDER BY u.id",
+			want:  "name|n\nann|2\nbob|1\ncy|0\ndee|0",
+		},
+		{
+			name:  "group by with null group",
+			query: "SELECT country, COUNT(*) FROM users GROUP BY country ORDER BY country",
+			want:  "country|COUNT(*)\nNULL|1\nde|2\nus|1",
+		},
+		{
+			name:  "group by position",
+			query: "SELECT country, COUNT(*) FROM users GROUP BY 1 ORDER BY 2 DESC, 1",
+			want:  "country|COUNT(*)\nde|2\nNULL|1\nus|1",
+		},
+		{
+			name:  "having",
+			query: "SELECT user_id, COUNT(*) AS n FROM orders GROUP BY user_id HAVING n > 1",
+			want:  "user_id|n\n1|2",
+		},
+		{
+			name:  "order by alias",
+			query: "SELECT name AS who FROM users ORDER BY who DESC LIMIT 2",
+			want:  "who\ndee\ncy",
+		},
+		{
+			name:  "order by position with offset",
+			query: "SELECT id, name FROM users ORDER BY 2 DESC LIMIT 2 OFFSET 1",
+			want:  "id|name\n3|cy\n2|bob",
+		},
+		{
+			name:  "distinct",
+			query: "SELECT DISTINCT country FROM users WHERE country IS NOT NULL ORDER BY country",
+			want:  "country\nde\nus",
+		},
+		{
+			name:  "decimal sum and avg are exact",
+			query: "SELECT SUM(price), AVG(price) FROM orders WHERE user_id < 9",
+			want:  "SUM(price)|AVG(price)\n0.25|0.083333",
+		},
+		{
+			name:  "decimal sum per group",
+			query: "SELECT user_id, SUM(price) AS total FROM orders GROUP BY user_id ORDER BY total DESC",
+			want:  "user_id|total\n9|1.00\n1|0.20\n2|0.05",
+		},
+		{
+			name:  "integer sum",
+			query: "SELECT SUM(id), MIN(name), MAX(name) FROM users",
+			want:  "SUM(id)|MIN(name)|MAX(name)\n10|ann|dee",
+		},
+		{
+			name:  "double sum stays floating point",
+			query: "SELECT SUM(weight) FROM orders",
+			want:  "SUM(weight)\n0.6000000000000001",
+		},
+		{
+			name:  "aggregates over no rows",
+			query: "SELECT COUNT(*), SUM(price) FROM orders WHERE id > 10",
+			want:  "COUNT(*)|SUM(price)\n0|NULL",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := run(tt.query, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("%s:\ngot\n%s\nwant\n%s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"SELECT x FROM users", "x"},
+		{"SELECT name FROM users ORDER BY 3", "ORDER BY"},
+		{"SELECT name FROM users GROUP BY 0", "GROUP BY"},
+		{"SELECT id FROM users u JOIN orders o ON o.user_id = u.id", "id"},
+		{"SELECT name FROM nowhere", "nowhere"},
+	}
+	for _, tt := range tests {
+		_, err := run(tt.query, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want one mentioning %s", tt.query, err, tt.err)
+		}
+	}
+}
+
+// run runs query over testSource and returns the names of the columns and
+// the result rows, one per line, with values separated by |.
+func run(query string, args []interface{}) (string, error) {
+	sel, err := Parse(query)
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	err = sel.Run(testSource, args, func(names []string) error {
+		lines = append(lines, strings.Join(names, "|"))
+		return nil
+	}, func(values []interface{}) error {
+		s := make([]string, len(values))
+		for i, v := range values {
+			if v == nil {
+				s[i] = "NULL"
+			} else {
+				s[i] = toString(v)
+			}
+		}
+		lines = append(lines, strings.Join(s, "|"))
+		return nil
+	})
+	return strings.Join(lines, "\n"), err
+}
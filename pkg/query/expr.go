@@ -0,0 +1,366 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expr is an expression in a query. Values are nil (SQL NULL), string,
+// int64, float64 or bool; values read from a dump are strings or nil.
+type Expr interface {
+	// Eval evaluates the expression against row. args holds the values of
+	// the query's ? placeholders.
+	Eval(row map[string]interface{}, args []interface{}) interface{}
+	String() string
+}
+
+// ColumnRef refers to a column, optionally qualified by its table.
+type ColumnRef struct {
+	Table string
+	Name  string
+}
+
+func (e *ColumnRef) Eval(row map[string]interface{}, args []interface{}) interface{} {
+	if e.Table != "" {
+		if v, ok := row[e.Table+"."+e.Name]; ok {
+			return v
+		}
+	}
+	return row[e.Name]
+}
+
+func (e *ColumnRef) String() string {
+	if e.Table != "" {
+		return e.Table + "." + e.Name
+	}
+	return e.Name
+}
+
+type Literal struct {
+	Value interface{}
+}
+
+func (e *Literal) Eval(row map[string]interface{}, args []interface{}) interface{} {
+	return e.Value
+}
+
+func (e *Literal) String() string {
+	switch v := e.Value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Param is a ? placeholder. Index is its 0-based position in the query.
+type Param struct {
+	Index int
+}
+
+func (e *Param) Eval(row map[string]interface{}, args []interface{}) interface{} {
+	if e.Index < len(args) {
+		return args[e.Index]
+	}
+	return nil
+}
+
+func (e *Param) String() string {
+	return "?"
+}
+
+// BinaryExpr is a comparison, arithmetic or logical (AND, OR) operation.
+type BinaryExpr struct {
+	Op          string
+	Left, Right Expr
+}
+
+func (e *BinaryExpr) Eval(row map[string]interface{}, args []interface{}) interface{} {
+	switch e.Op {
+	case "AND":
+		left := truth(e.Left.Eval(row, args))
+		if left == false {
+			return false
+		}
+		right := truth(e.Right.Eval(row, args))
+		if right == false {
+			return false
+		}
+		if left == nil || right == nil {
+			return nil
+		}
+		return true
+	case "OR":
+		left := truth(e.Left.Eval(row, args))
+		if left == true {
+			return true
+		}
+		right := truth(e.Right.Eval(row, args))
+		if right == true {
+			return true
+		}
+		if left == nil || right == nil {
+			return nil
+		}
+		return false
+	}
+
+	left, right := e.Left.Eval(row, args), e.Right.Eval(row, args)
+	switch e.Op {
+	case "+", "-", "*", "/", "%":
+		return arithmetic(e.Op, left, right)
+	}
+
+	c, ok := compare(left, right)
+	if !ok {
+		return nil
+	}
+	switch e.Op {
+	case "=":
+		return c == 0
+	case "<>", "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return nil
+}
+
+func (e *BinaryExpr) String() string {
+	return "(" + e.Left.String() + " " + e.Op + " " + e.Right.String() + ")"
+}
+
+// UnaryExpr is NOT or a numeric negation.
+type UnaryExpr struct {
+	Op string
+	X  Expr
+}
+
+func (e *UnaryExpr) Eval(row map[string]interface{}, args []interface{}) interface{} {
+	v := e.X.Eval(row, args)
+	if e.Op == "NOT" {
+		t := truth(v)
+		if t == nil {
+			return nil
+		}
+		return !t.(bool)
+	}
+	return arithmetic("-", int64(0), v)
+}
+
+func (e *UnaryExpr) String() string {
+	if e.Op == "NOT" {
+		return "NOT " + e.X.String()
+	}
+	return e.Op + e.X.String()
+}
+
+// IsNullExpr is "X IS [NOT] NULL".
+type IsNullExpr struct {
+	X   Expr
+	Not bool
+}
+
+func (e *IsNullExpr) Eval(row map[string]interface{}, args []interface{}) interface{} {
+	return (e.X.Eval(row, args) == nil) != e.Not
+}
+
+func (e *IsNullExpr) String() string {
+	if e.Not {
+		return e.X.String() + " IS NOT NULL"
+	}
+	return e.X.String() + " IS NULL"
+}
+
+// InExpr is "X [NOT] IN (list)".
+type InExpr struct {
+	X    Expr
+	List []Expr
+	Not  bool
+}
+
+func (e *InExpr) Eval(row map[string]interface{}, args []interface{}) interface{} {
+	v := e.X.Eval(row, args)
+	if v == nil {
+		return nil
+	}
+	sawNull := false
+	for _, item := range e.List {
+		c, ok := compare(v, item.Eval(row, args))
+		if !ok {
+			sawNull = true
+			continue
+		}
+		if c == 0 {
+			return !e.Not
+		}
+	}
+	if sawNull {
+		return nil
+	}
+	return e.Not
+}
+
+func (e *InExpr) String() string {
+	items := make([]string, len(e.List))
+	for i, item := range e.List {
+		items[i] = item.String()
+	}
+	op := " IN ("
+	if e.Not {
+		op = " NOT IN ("
+	}
+	return e.X.String() + op + strings.Join(items, ", ") + ")"
+}
+
+// LikeExpr is "X [NOT] LIKE pattern", with % and _ wildcards.
+type LikeExpr struct {
+	X       Expr
+	Pattern Expr
+	Not     bool
+}
+
+func (e *LikeExpr) Eval(row map[string]interface{}, args []interface{}) interface{} {
+	v, p := e.X.Eval(row, args), e.Pattern.Eval(row, args)
+	if v == nil || p == nil {
+		return nil
+	}
+	return like(strings.ToLower(toString(v)), strings.ToLower(toString(p))) != e.Not
+}
+
+func (e *LikeExpr) String() string {
+	op := " LIKE "
+	if e.Not {
+		op = " NOT LIKE "
+	}
+	return e.X.String() + op + e.Pattern.String()
+}
+
+// BetweenExpr is "X [NOT] BETWEEN Low AND High".
+type BetweenExpr struct {
+	X, Low, High Expr
+	Not          bool
+}
+
+func (e *BetweenExpr) Eval(row map[string]interface{}, args []interface{}) interface{} {
+	v := e.X.Eval(row, args)
+	lo, ok1 := compare(v, e.Low.Eval(row, args))
+	hi, ok2 := compare(v, e.High.Eval(row, args))
+	if !ok1 || !ok2 {
+		return nil
+	}
+	return (lo >= 0 && hi <= 0) != e.Not
+}
+
+func (e *BetweenExpr) String() string {
+	op := " BETWEEN "
+	if e.Not {
+		op = " NOT BETWEEN "
+	}
+	return e.X.String() + op + e.Low.String() + " AND " + e.High.String()
+}
+
+// FuncCall is a call of an aggregate function: COUNT, SUM, AVG, MIN or MAX.
+// Aggregates are computed over the rows of a group before the expressions
+// that use them are evaluated, and Eval looks up the result in row by the
+// call's String.
+type FuncCall struct {
+	Name     string // upper case
+	Args     []Expr
+	Star     bool // COUNT(*)
+	Distinct bool
+}
+
+// aggregates are the functions FuncCall supports.
+var aggregates = map[string]bool{"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true}
+
+func (e *FuncCall) Eval(row map[string]interface{}, args []interface{}) interface{} {
+	return row[e.String()]
+}
+
+func (e *FuncCall) String() string {
+	if e.Star {
+		return e.Name + "(*)"
+	}
+	items := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		items[i] = arg.String()
+	}
+	if e.Distinct {
+		return e.Name + "(DISTINCT " + strings.Join(items, ", ") + ")"
+	}
+	return e.Name + "(" + strings.Join(items, ", ") + ")"
+}
+
+// like matches s against a LIKE pattern.
+func like(s, pattern string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '%':
+			for len(pattern) > 0 && pattern[0] == '%' {
+				pattern = pattern[1:]
+			}
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if like(s[i:], pattern) {
+					return true
+				}
+			}
+			return false
+		case '_':
+			if s == "" {
+				return false
+			}
+			s, pattern = s[1:], pattern[1:]
+		default:
+			if s == "" || s[0] != pattern[0] {
+				return false
+			}
+			s, pattern = s[1:], pattern[1:]
+		}
+	}
+	return s == ""
+}
+
+// walk calls fn for e and every expression below it.
+func walk(e Expr, fn func(Expr)) {
+	if e == nil {
+		return
+	}
+	fn(e)
+	switch e := e.(type) {
+	case *BinaryExpr:
+		walk(e.Left, fn)
+		walk(e.Right, fn)
+	case *UnaryExpr:
+		walk(e.X, fn)
+	case *IsNullExpr:
+		walk(e.X, fn)
+	case *InExpr:
+		walk(e.X, fn)
+		for _, item := range e.List {
+			walk(item, fn)
+		}
+	case *LikeExpr:
+		walk(e.X, fn)
+		walk(e.Pattern, fn)
+	case *BetweenExpr:
+		walk(e.X, fn)
+		walk(e.Low, fn)
+		walk(e.High, fn)
+	case *FuncCall:
+		for _, arg := range e.Args {
+			walk(arg, fn)
+		}
+	}
+}
@@ -0,0 +1,549 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse parses a SELECT query.
+func Parse(sql string) (*Select, error) {
+	tokens, err := lex(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	stmt, err := p.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+	stmt.NumParams = p.params
+	return stmt, nil
+}
+
+// ParseCondition parses a condition as written after WHERE, for filtering
+// rows outside of a query.
+func ParseCondition(sql string) (*Condition, error) {
+	tokens, err := lex(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected input after condition")
+	}
+	if p.params > 0 {
+		return nil, errors.New("conditions can't have ? placeholders")
+	}
+	if walkAny(expr, isAggregate) {
+		return nil, errors.New("conditions can't have aggregates")
+	}
+	return &Condition{expr: expr}, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	params int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the keyword or symbol s.
+func (p *parser) accept(s string) bool {
+	if p.peek().is(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return p.errorf("expected %s", s)
+	}
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	t := p.peek()
+	return fmt.Errorf("syntax error at position %d near %s: %s", t.pos, t, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseSelect() (*Select, error) {
+	if err := p.expect("SELECT"); err != nil {
+		return nil, err
+	}
+	stmt := &Select{Limit: -1}
+	stmt.Distinct = p.accept("DISTINCT")
+
+	for {
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Fields = append(stmt.Fields, field)
+		if !p.accept(",") {
+			break
+		}
+	}
+
+	if err := p.expect("FROM"); err != nil {
+		return nil, err
+	}
+	var err error
+	if stmt.From, stmt.Alias, err = p.parseTable(); err != nil {
+		return nil, err
+	}
+
+	for {
+		join, ok, err := p.parseJoin()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		stmt.Joins = append(stmt.Joins, join)
+	}
+
+	if p.accept("WHERE") {
+		if stmt.Where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.accept("GROUP") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			stmt.GroupBy = append(stmt.GroupBy, expr)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+
+	if p.accept("HAVING") {
+		if stmt.Having, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.accept("ORDER") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			item := OrderItem{Expr: expr}
+			if p.accept("DESC") {
+				item.Desc = true
+			} else {
+				p.accept("ASC")
+			}
+			stmt.OrderBy = append(stmt.OrderBy, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+
+	if p.accept("LIMIT") {
+		n, err := p.parseInt()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Limit = n
+		if p.accept(",") {
+			// LIMIT offset, count
+			if stmt.Limit, err = p.parseInt(); err != nil {
+				return nil, err
+			}
+			stmt.Offset = n
+		} else if p.accept("OFFSET") {
+			if stmt.Offset, err = p.parseInt(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	p.accept(";")
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected input after query")
+	}
+	return stmt, nil
+}
+
+// parseJoin parses a JOIN clause if one is next.
+func (p *parser) parseJoin() (Join, bool, error) {
+	join := Join{}
+	switch {
+	case p.accept("JOIN"):
+	case p.accept("INNER"):
+		if err := p.expect("JOIN"); err != nil {
+			return join, false, err
+		}
+	case p.accept("LEFT"):
+		p.accept("OUTER")
+		if err := p.expect("JOIN"); err != nil {
+			return join, false, err
+		}
+		join.Left = true
+	default:
+		return join, false, nil
+	}
+
+	var err error
+	if join.Table, join.Alias, err = p.parseTable(); err != nil {
+		return join, false, err
+	}
+	if err := p.expect("ON"); err != nil {
+		return join, false, err
+	}
+	if join.On, err = p.parseExpr(); err != nil {
+		return join, false, err
+	}
+	return join, true, nil
+}
+
+// parseTable parses a table name with an optional alias.
+func (p *parser) parseTable() (table, alias string, err error) {
+	if table, err = p.parseIdent(); err != nil {
+		return "", "", err
+	}
+	if p.accept("AS") {
+		if alias, err = p.parseIdent(); err != nil {
+			return "", "", err
+		}
+	} else if t := p.peek(); t.kind == tokQuotedIdent || t.kind == tokIdent && !isReserved(t.text) {
+		alias, _ = p.parseIdent()
+	}
+	return table, alias, nil
+}
+
+func (p *parser) parseField() (Field, error) {
+	if p.accept("*") {
+		return Field{}, nil
+	}
+	if t := p.peek(); (t.kind == tokIdent || t.kind == tokQuotedIdent) && p.pos+2 < len(p.tokens) &&
+		p.tokens[p.pos+1].is(".") && p.tokens[p.pos+2].is("*") {
+		// table.*
+		p.pos += 3
+		return Field{Table: t.text}, nil
+	}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return Field{}, err
+	}
+	field := Field{Expr: expr}
+	if p.accept("AS") {
+		if field.Alias, err = p.parseIdent(); err != nil {
+			return Field{}, err
+		}
+	} else if t := p.peek(); t.kind == tokQuotedIdent || t.kind == tokIdent && !isReserved(t.text) {
+		field.Alias, _ = p.parseIdent()
+	}
+	return field, nil
+}
+
+func (p *parser) parseIdent() (string, error) {
+	t := p.peek()
+	if t.kind == tokQuotedIdent || t.kind == tokIdent && !isReserved(t.text) {
+		p.pos++
+		return t.text, nil
+	}
+	return "", p.errorf("expected identifier")
+}
+
+func (p *parser) parseInt() (int, error) {
+	t := p.peek()
+	if t.kind != tokNumber {
+		return 0, p.errorf("expected number")
+	}
+	n, err := strconv.Atoi(t.text)
+	if err != nil || n < 0 {
+		return 0, p.errorf("expected non-negative integer")
+	}
+	p.pos++
+	return n, nil
+}
+
+// Operator precedence, from loosest to tightest: OR, AND, NOT, comparisons,
+// + and -, * / and %, unary minus.
+
+func (p *parser) parseExpr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "OR", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "AND", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.accept("NOT") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "NOT", X: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range []string{"=", "<>", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(op) {
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &BinaryExpr{Op: op, Left: left, Right: right}, nil
+		}
+	}
+
+	if p.accept("IS") {
+		not := p.accept("NOT")
+		if err := p.expect("NULL"); err != nil {
+			return nil, err
+		}
+		return &IsNullExpr{X: left, Not: not}, nil
+	}
+
+	not := p.accept("NOT")
+	switch {
+	case p.accept("IN"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		in := &InExpr{X: left, Not: not}
+		for {
+			item, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			in.List = append(in.List, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return in, nil
+	case p.accept("LIKE"):
+		pattern, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &LikeExpr{X: left, Pattern: pattern, Not: not}, nil
+	case p.accept("BETWEEN"):
+		low, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &BetweenExpr{X: left, Low: low, High: high, Not: not}, nil
+	case not:
+		return nil, p.errorf("expected IN, LIKE or BETWEEN after NOT")
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (Expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if !op.is("+") && !op.is("-") {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op.text, Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseMultiplicative() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if !op.is("*") && !op.is("/") && !op.is("%") {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op.text, Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.accept("-") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "-", X: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokNumber:
+		p.pos++
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &Literal{Value: i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.text, t.pos)
+		}
+		return &Literal{Value: f}, nil
+	case t.kind == tokString:
+		p.pos++
+		return &Literal{Value: t.text}, nil
+	case t.kind == tokParam:
+		p.pos++
+		p.params++
+		return &Param{Index: p.params - 1}, nil
+	case t.is("NULL"):
+		p.pos++
+		return &Literal{}, nil
+	case t.is("TRUE"), t.is("FALSE"):
+		p.pos++
+		return &Literal{Value: strings.EqualFold(t.text, "TRUE")}, nil
+	case t.is("("):
+		p.pos++
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, p.errorf("expected expression")
+	}
+	if p.peek().is("(") && t.kind == tokIdent {
+		return p.parseCall(t)
+	}
+	if p.accept(".") {
+		column, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		return &ColumnRef{Table: name, Name: column}, nil
+	}
+	return &ColumnRef{Name: name}, nil
+}
+
+// parseCall parses the arguments of a call of the function named by t, whose
+// opening parenthesis is next.
+func (p *parser) parseCall(t token) (Expr, error) {
+	name := strings.ToUpper(t.text)
+	if !aggregates[name] {
+		return nil, fmt.Errorf("unknown function %s at position %d", t.text, t.pos)
+	}
+	p.pos++ // (
+
+	call := &FuncCall{Name: name}
+	if name == "COUNT" && p.accept("*") {
+		call.Star = true
+	} else {
+		call.Distinct = p.accept("DISTINCT")
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = []Expr{arg}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+var reserved = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "AND": true, "OR": true, "NOT": true,
+	"AS": true, "IS": true, "NULL": true, "IN": true, "LIKE": true, "BETWEEN": true,
+	"LIMIT": true, "OFFSET": true, "TRUE": true, "FALSE": true, "DISTINCT": true,
+	"JOIN": true, "INNER": true, "LEFT": true, "OUTER": true, "ON": true,
+	"GROUP": true, "BY": true, "HAVING": true, "ORDER": true, "ASC": true, "DESC": true,
+}
+
+func isReserved(word string) bool {
+	return reserved[strings.ToUpper(word)]
+}
@@ -0,0 +1,91 @@
+package query
+
+// Select is a parsed SELECT query.
+type Select struct {
+	Distinct  bool
+	Fields    []Field
+	From      string
+	Alias     string // alias of the FROM table, or ""
+	Joins     []Join
+	Where     Expr   // nil without WHERE
+	GroupBy   []Expr // expressions, output positions (GROUP BY 1) or output aliases
+	Having    Expr   // nil without HAVING
+	OrderBy   []OrderItem
+	Limit     int // -1 without LIMIT
+	Offset    int
+	NumParams int // number of ? placeholders
+}
+
+// Field is an entry of the SELECT list. A nil Expr stands for *, or for
+// Table.* if Table is set.
+type Field struct {
+	Expr  Expr
+	Alias string
+	Table string
+}
+
+// Join is a JOIN clause.
+type Join struct {
+	Table string
+	Alias string // or ""
+	Left  bool   // LEFT JOIN
+	On    Expr
+}
+
+// OrderItem is an entry of ORDER BY. Expr may also be an output position
+// (ORDER BY 2) or the alias of an output column.
+type OrderItem struct {
+	Expr Expr
+	Desc bool
+}
+
+// Name returns the name of the output column produced by f.
+func (f Field) Name() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	if ref, ok := f.Expr.(*ColumnRef); ok {
+		return ref.Name
+	}
+	return f.Expr.String()
+}
+
+// name returns the name that refers to the FROM table in the query.
+func (s *Select) name() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.From
+}
+
+func (j Join) name() string {
+	if j.Alias != "" {
+		return j.Alias
+	}
+	return j.Table
+}
+
+// Condition is a WHERE condition on its own, as parsed by ParseCondition.
+type Condition struct {
+	expr Expr
+}
+
+// Columns returns the names of the columns the condition refers to.
+func (c *Condition) Columns() []string {
+	var columns []string
+	walk(c.expr, func(e Expr) {
+		if ref, ok := e.(*ColumnRef); ok {
+			columns = append(columns, ref.Name)
+		}
+	})
+	return columns
+}
+
+// Match reports whether row satisfies the condition.
+func (c *Condition) Match(row map[string]interface{}) bool {
+	return truth(c.expr.Eval(row, nil)) == true
+}
+
+func (c *Condition) String() string {
+	return c.expr.String()
+}
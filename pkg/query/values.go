@@ -0,0 +1,132 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// truth converts v to a boolean the way MySQL does in a WHERE clause: numbers
+// (including numeric strings) are true when non-zero, other strings are
+// false, and NULL stays NULL.
+func truth(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case bool:
+		return v
+	}
+	if n, ok := toFloat(v); ok {
+		return n != 0
+	}
+	return false
+}
+
+// compare orders a and b, numerically if both are numbers or numeric strings
+// and as strings otherwise. ok is false if either is NULL.
+func compare(a, b interface{}) (c int, ok bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if fa, okA := toFloat(a); okA {
+		if fb, okB := toFloat(b); okB {
+			switch {
+			case fa < fb:
+				return -1, true
+			case fa > fb:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return strings.Compare(toString(a), toString(b)), true
+}
+
+func arithmetic(op string, a, b interface{}) interface{} {
+	if a == nil || b == nil {
+		return nil
+	}
+	ia, intA := toInt(a)
+	ib, intB := toInt(b)
+	if intA && intB && op != "/" {
+		switch op {
+		case "+":
+			return ia + ib
+		case "-":
+			return ia - ib
+		case "*":
+			return ia * ib
+		case "%":
+			if ib == 0 {
+				return nil
+			}
+			return ia % ib
+		}
+	}
+
+	fa, okA := toFloat(a)
+	fb, okB := toFloat(b)
+	if !okA || !okB {
+		return nil
+	}
+	switch op {
+	case "+":
+		return fa + fb
+	case "-":
+		return fa - fb
+	case "*":
+		return fa * fb
+	case "/":
+		if fb == 0 {
+			return nil
+		}
+		return fa / fb
+	case "%":
+		if fb == 0 {
+			return nil
+		}
+		return math.Mod(fa, fb)
+	}
+	return nil
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
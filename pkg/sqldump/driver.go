@@ -0,0 +1,140 @@
+// Package sqldump is a read-only database/sql driver that runs SELECT queries
+// directly against a SQL dump file, plain or gzip-compressed:
+//
+//	import _ "sqlparser/pkg/sqldump"
+//
+//	db, err := sql.Open("sqldump", "/path/dump.sql.gz")
+//	rows, err := db.Query("SELECT id, email FROM users WHERE country = ? LIMIT 10", "US")
+//
+// Queries support WHERE, joins, GROUP BY with aggregates, ORDER BY, LIMIT and
+// OFFSET; see package query. The positions of tables in the file are looked
+// up in an index built on first use, so only the parts of the dump holding
+// the queried tables are parsed, and rows are filtered as they are parsed.
+//
+// The data source name is the path of the dump, optionally followed by
+// parameters: "dump.sql?workers=4&on_error=fail". on_error is skip (the
+// default) or fail, which makes queries over malformed data return an error.
+package sqldump
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"sqlparser/pkg/parser"
+	"sqlparser/pkg/query"
+)
+
+func init() {
+	sql.Register("sqldump", &Driver{})
+}
+
+type Driver struct{}
+
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	c := &conn{path: name, workers: 1, policy: parser.ErrorPolicySkip}
+
+	if i := strings.LastIndexByte(name, '?'); i >= 0 {
+		c.path = name[:i]
+		params, err := url.ParseQuery(name[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("sqldump: invalid data source name: %v", err)
+		}
+		if v := params.Get("workers"); v != "" {
+			if c.workers, err = strconv.Atoi(v); err != nil || c.workers < 1 {
+				return nil, fmt.Errorf("sqldump: invalid workers %q", v)
+			}
+		}
+		if v := params.Get("on_error"); v != "" {
+			if c.policy, err = parser.ParseErrorPolicy(v); err != nil || c.policy == parser.ErrorPolicyQuarantine {
+				return nil, fmt.Errorf("sqldump: invalid on_error %q (want fail or skip)", v)
+			}
+		}
+	}
+	return c, nil
+}
+
+type conn struct {
+	path    string
+	workers int
+	policy  parser.ErrorPolicy
+}
+
+func (c *conn) Prepare(q string) (driver.Stmt, error) {
+	sel, err := query.Parse(q)
+	if err != nil {
+		return nil, fmt.Errorf("sqldump: %v", err)
+	}
+	return &stmt{conn: c, sel: sel}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, errors.New("sqldump: transactions are not supported")
+}
+
+func (c *conn) QueryContext(ctx context.Context, q string, args []driver.NamedValue) (driver.Rows, error) {
+	sel, err := query.Parse(q)
+	if err != nil {
+		return nil, fmt.Errorf("sqldump: %v", err)
+	}
+	return c.query(ctx, sel, namedArgs(args))
+}
+
+type stmt struct {
+	conn *conn
+	sel  *query.Select
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return s.sel.NumParams
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("sqldump: dumps are read-only")
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = queryValue(arg)
+	}
+	return s.conn.query(context.Background(), s.sel, values)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.query(ctx, s.sel, namedArgs(args))
+}
+
+func namedArgs(args []driver.NamedValue) []interface{} {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = queryValue(arg.Value)
+	}
+	return values
+}
+
+// queryValue converts a driver argument to a query value.
+func queryValue(v driver.Value) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return v
+	}
+}
@@ -0,0 +1,185 @@
+package sqldump
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const shopDump = "CREATE TABLE `users` (\n" +
+	"  `id` int NOT NULL,\n" +
+	"  `name` varchar(20) DEFAULT NULL,\n" +
+	"  PRIMARY KEY (`id`)\n" +
+	");\n" +
+	"INSERT INTO `users` VALUES (1,'ann'),(2,'bob'),(3,NULL);\n" +
+	"CREATE TABLE `orders` (\n" +
+	"  `id` int NOT NULL,\n" +
+	"  `user_id` int NOT NULL\n" +
+	");\n" +
+	"INSERT INTO `orders` VALUES (10,1),(11,2),(12,1);\n" +
+	"INSERT INTO `orders` VALUES (13,2;\n"
+
+func writeDump(t *testing.T, dump string) string {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	filename := filepath.Join(t.TempDir(), "dump.sql")
+	if err := os.WriteFile(filename, []byte(dump), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// queryStrings runs a query and returns its rows as strings, with NULL for
+// NULL values.
+func queryStrings(db *sql.DB, q string, args ...interface{}) ([]string, error) {
+	rows, err := db.QueryContext(context.Background(), q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var got []string
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = "NULL"
+			if v.Valid {
+				parts[i] = v.String
+			}
+		}
+		got = append(got, strings.Join(parts, ","))
+	}
+	return got, rows.Err()
+}
+
+func TestQuery(t *testing.T) {
+	filename := writeDump(t, shopDump)
+	tests := []struct {
+		name  string
+		dsn   string
+		query string
+		args  []interface{}
+		want  []string
+		err   string
+	}{
+		{name: "every row", query: "SELECT id, name FROM users", want: []string{"1,ann", "2,bob", "3,NULL"}},
+		{name: "parameters", query: "SELECT name FROM users WHERE id > ? LIMIT 1", args: []interface{}{1}, want: []string{"bob"}},
+		{name: "byte parameters", query: "SELECT id FROM users WHERE name = ?", args: []interface{}{[]byte("ann")}, want: []string{"1"}},
+		{
+			name:  "join and group by",
+			query: "SELECT u.name, COUNT(o.id) AS n FROM users u JOIN orders o ON o.user_id = u.id GROUP BY u.name ORDER BY u.name",
+			want:  []string{"ann,2", "bob,1"},
+		},
+		{name: "broken statements are skipped", dsn: "?workers=2", query: "SELECT id FROM orders", want: []string{"10", "11", "12"}},
+		{name: "broken statements fail", dsn: "?on_error=fail", query: "SELECT id FROM orders", err: "unterminated tuple"},
+		{name: "unknown table", query: "SELECT id FROM nowhere", err: `table "nowhere" not found`},
+		{name: "unknown column", query: "SELECT x FROM users", err: "x"},
+		{name: "invalid workers", dsn: "?workers=0", query: "SELECT id FROM users", err: `invalid workers "0"`},
+		{name: "quarantine", dsn: "?on_error=quarantine", query: "SELECT id FROM users", err: "want fail or skip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := sql.Open("sqldump", filename+tt.dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			got, err := queryStrings(db, tt.query, tt.args...)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryStopEarly(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("CREATE TABLE `n` (\n  `i` int\n);\n")
+	for i := 0; i < 2000; i++ {
+		b.WriteString("INSERT INTO `n` VALUES (1),(2),(3);\n")
+	}
+	db, err := sql.Open("sqldump", writeDump(t, b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT i FROM n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	// Closing stops the parser, and isn't reported as an error
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rows.Err(); err != nil {
+		t.Errorf("Err after Close = %v", err)
+	}
+}
+
+func TestTableInTwoDatabases(t *testing.T) {
+	const dump = "USE `a`;\nCREATE TABLE `t` (\n  `id` int\n);\nINSERT INTO `t` VALUES (1);\n" +
+		"USE `b`;\nCREATE TABLE `t` (\n  `id` int\n);\nINSERT INTO `t` VALUES (2);\n"
+	db, err := sql.Open("sqldump", writeDump(t, dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := queryStrings(db, "SELECT id FROM t"); err == nil || !strings.Contains(err.Error(), "more than one database") {
+		t.Errorf("got error %v, want one about several databases", err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	db, err := sql.Open("sqldump", writeDump(t, shopDump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Begin(); err == nil {
+		t.Error("Begin succeeded")
+	}
+	stmt, err := db.Prepare("SELECT id FROM users WHERE id = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec(1); err == nil {
+		t.Error("Exec succeeded")
+	}
+	var name string
+	if err := stmt.QueryRow(2).Scan(&name); err != nil || name != "2" {
+		t.Errorf("QueryRow = %q, %v", name, err)
+	}
+}
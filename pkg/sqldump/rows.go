@@ -0,0 +1,239 @@
+package sqldump
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+	"sqlparser/pkg/query"
+)
+
+// rows streams the result of a query from a parser goroutine.
+type rows struct {
+	columns []string
+	cancel  context.CancelFunc
+	batches chan [][]interface{}
+	done    chan struct{}
+	err     error // set before done is closed
+
+	batch   [][]interface{}
+	pos     int
+	stopped bool
+}
+
+// rowBatchSize is how many result rows are passed to the caller at a time.
+const rowBatchSize = 64
+
+func (c *conn) query(ctx context.Context, sel *query.Select, args []interface{}) (driver.Rows, error) {
+	tables, err := loadIndex(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("sqldump: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r := &rows{
+		cancel:  cancel,
+		batches: make(chan [][]interface{}, 1),
+		done:    make(chan struct{}),
+	}
+	src := &source{conn: c, ctx: ctx, tables: tables}
+
+	// The column list is only known once the table's schema has been parsed
+	ready := make(chan error, 1)
+	readySent := false
+	columns := func(names []string) error {
+		r.columns = names
+		readySent = true
+		ready <- nil
+		return nil
+	}
+
+	var out [][]interface{}
+	send := func() error {
+		if len(out) == 0 {
+			return nil
+		}
+		select {
+		case r.batches <- out:
+			out = nil
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	emit := func(values []interface{}) error {
+		out = append(out, values)
+		if len(out) >= rowBatchSize {
+			return send()
+		}
+		return nil
+	}
+
+	go func() {
+		defer close(r.done)
+		defer close(r.batches)
+
+		err := sel.Run(src, args, columns, emit)
+		if err == nil {
+			err = send()
+		}
+		if !readySent {
+			ready <- err
+			return
+		}
+		r.err = err
+	}()
+
+	if err := <-ready; err != nil {
+		r.Close()
+		return nil, fmt.Errorf("sqldump: %v", err)
+	}
+	return r, nil
+}
+
+// source reads the tables of a dump for query.Select.Run, parsing only the
+// part of the file that holds the table.
+type source struct {
+	conn   *conn
+	ctx    context.Context
+	tables map[string][]parser.TableInfo
+}
+
+func (s *source) Scan(name string, columns func(names, types []string) error, fn func(map[string]interface{}) error) error {
+	found := s.tables[name]
+	if len(found) == 0 {
+		return fmt.Errorf("table %q not found in %s", name, s.conn.path)
+	}
+	if len(found) > 1 {
+		return fmt.Errorf("table %q is in more than one database of %s", name, s.conn.path)
+	}
+	table := found[0]
+	input, err := parser.OpenInputAt(s.conn.path, table.StartOffset)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	schemaSeen := false
+	handler := parser.HandlerFuncs{
+		OnSchema: func(schema *models.Schema) error {
+			if schemaSeen {
+				return nil
+			}
+			schemaSeen = true
+			types := make([]string, len(schema.Columns))
+			for i, col := range schema.Columns {
+				types[i] = col.Type
+			}
+			return columns(schema.ColumnNames(), types)
+		},
+		OnRows: func(_ string, batch []models.Row) error {
+			for _, row := range batch {
+				if err := fn(row.Data); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	cfg := parser.Config{
+		File:           s.conn.path,
+		StartLine:      table.StartLine,
+		StartOffset:    table.StartOffset,
+		StartStatement: table.StartStatement,
+		StartDatabase:  table.StartDatabase,
+		EndLine:        table.LineTo,
+		Workers:        s.conn.workers,
+		Tables:         func(db, t string) bool { return db == table.Database && t == name },
+		Errors:         parser.NewErrorHandler(s.conn.policy, nil),
+	}
+	if _, err := parser.Stream(s.ctx, input, cfg, handler); err != nil {
+		return err
+	}
+	if !schemaSeen {
+		return fmt.Errorf("no rows found for table %q", name)
+	}
+	return nil
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	for r.pos >= len(r.batch) {
+		batch, ok := <-r.batches
+		if !ok {
+			<-r.done
+			if r.err != nil && !r.stopped {
+				return fmt.Errorf("sqldump: %v", r.err)
+			}
+			return io.EOF
+		}
+		r.batch, r.pos = batch, 0
+	}
+
+	values := r.batch[r.pos]
+	r.pos++
+	for i, v := range values {
+		dest[i] = v
+	}
+	return nil
+}
+
+// Close stops the parser once the caller is done with the rows.
+func (r *rows) Close() error {
+	if r.stopped {
+		return nil
+	}
+	r.stopped = true
+	r.cancel()
+	for range r.batches {
+		// Unblock the parser so it can shut down
+	}
+	<-r.done
+	return nil
+}
+
+// tableIndex caches the tables of a dump file until the file changes.
+type tableIndex struct {
+	size    int64
+	modTime time.Time
+	tables  map[string][]parser.TableInfo // by name, which several databases may share
+}
+
+var (
+	indexMu sync.Mutex
+	indexes = make(map[string]*tableIndex)
+)
+
+func loadIndex(path string) (map[string][]parser.TableInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	if idx, ok := indexes[path]; ok && idx.size == info.Size() && idx.modTime.Equal(info.ModTime()) {
+		return idx.tables, nil
+	}
+
+	tables, err := parser.ScanTables(path)
+	if err != nil {
+		return nil, err
+	}
+	idx := &tableIndex{size: info.Size(), modTime: info.ModTime(), tables: make(map[string][]parser.TableInfo)}
+	for _, table := range tables {
+		idx.tables[table.Name] = append(idx.tables[table.Name], table)
+	}
+	indexes[path] = idx
+	return idx.tables, nil
+}
@@ -0,0 +1,510 @@
+// Package tui implements the interactive table browser used to pick the
+// tables to export from a dump. It draws directly on the terminal with ANSI
+// escape sequences, so it needs stdin and stderr to be a terminal.
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+)
+
+// ErrCancelled is returned by SelectTables when the user quits without
+// choosing any table.
+var ErrCancelled = errors.New("table selection cancelled")
+
+// previewRows is the number of rows shown in the preview pane.
+const previewRows = 10
+
+// Available reports whether the browser can be shown.
+func Available() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// SelectTables lets the user search the tables of filename, preview their
+// rows and select the ones to export. Tables should come from
+// parser.ScanTableDetails so that row counts and columns can be shown.
+func SelectTables(filename string, tables []parser.TableInfo) ([]*parser.TableInfo, error) {
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("no tables found in the file")
+	}
+
+	in := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up terminal: %v", err)
+	}
+	defer term.Restore(in, state)
+
+	// Log output would be drawn over the screen, e.g. warnings about
+	// malformed statements while loading a preview
+	logger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(logger)
+
+	b := &browser{
+		filename: filename,
+		tables:   tables,
+		selected: make(map[int]bool),
+		previews: make(map[int]*preview),
+		out:      bufio.NewWriter(os.Stderr),
+	}
+	b.out.WriteString("\x1b[?1049h\x1b[?25l") // alternate screen, hide cursor
+	defer func() {
+		b.out.WriteString("\x1b[?25h\x1b[?1049l")
+		b.out.Flush()
+	}()
+
+	b.filter()
+	keys := bufio.NewReader(os.Stdin)
+	for {
+		b.render()
+		key, err := readKey(keys)
+		if err != nil {
+			return nil, err
+		}
+		if done, err := b.handle(key); done {
+			if err != nil {
+				return nil, err
+			}
+			return b.result(), nil
+		}
+	}
+}
+
+type browser struct {
+	filename string
+	tables   []parser.TableInfo
+	query    string
+	matches  []int // indexes into tables that match query, best first
+	cursor   int   // index into matches
+	top      int   // first visible entry of matches
+	selected map[int]bool
+	previews map[int]*preview
+	out      *bufio.Writer
+	width    int
+	height   int
+}
+
+type preview struct {
+	rows []models.Row
+	err  error
+}
+
+// filter recomputes the tables matching the search query.
+func (b *browser) filter() {
+	type match struct {
+		index int
+		score int
+	}
+	var matches []match
+	for i, table := range b.tables {
+		if score, ok := fuzzyScore(qualifiedName(table), b.query); ok {
+			matches = append(matches, match{i, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	b.matches = b.matches[:0]
+	for _, m := range matches {
+		b.matches = append(b.matches, m.index)
+	}
+	b.cursor, b.top = 0, 0
+}
+
+// handle applies a key press and reports whether the browser is done.
+func (b *browser) handle(key string) (bool, error) {
+	switch key {
+	case "enter":
+		if len(b.selected) == 0 && len(b.matches) == 0 {
+			return false, nil
+		}
+		return true, nil
+	case "esc", "ctrl-c":
+		return true, ErrCancelled
+	case "up":
+		b.move(-1)
+	case "down":
+		b.move(1)
+	case "pgup":
+		b.move(-b.listHeight())
+	case "pgdown":
+		b.move(b.listHeight())
+	case "home":
+		b.move(-len(b.matches))
+	case "end":
+		b.move(len(b.matches))
+	case " ", "tab":
+		if len(b.matches) > 0 {
+			b.toggle(b.matches[b.cursor])
+			if key == "tab" {
+				b.move(1)
+			}
+		}
+	case "ctrl-a":
+		// Select all matches, or clear them if all are selected already
+		all := true
+		for _, i := range b.matches {
+			all = all && b.selected[i]
+		}
+		for _, i := range b.matches {
+			if all {
+				delete(b.selected, i)
+			} else {
+				b.selected[i] = true
+			}
+		}
+	case "backspace":
+		if b.query != "" {
+			_, size := utf8.DecodeLastRuneInString(b.query)
+			b.query = b.query[:len(b.query)-size]
+			b.filter()
+		}
+	case "ctrl-u":
+		b.query = ""
+		b.filter()
+	default:
+		if utf8.RuneCountInString(key) == 1 && key >= " " {
+			b.query += key
+			b.filter()
+		}
+	}
+	return false, nil
+}
+
+func (b *browser) move(delta int) {
+	if len(b.matches) == 0 {
+		return
+	}
+	b.cursor += delta
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+	if b.cursor >= len(b.matches) {
+		b.cursor = len(b.matches) - 1
+	}
+}
+
+func (b *browser) toggle(i int) {
+	if b.selected[i] {
+		delete(b.selected, i)
+	} else {
+		b.selected[i] = true
+	}
+}
+
+// result returns the selected tables in file order, or the highlighted table
+// if none was selected.
+func (b *browser) result() []*parser.TableInfo {
+	if len(b.selected) == 0 {
+		return []*parser.TableInfo{&b.tables[b.matches[b.cursor]]}
+	}
+	var result []*parser.TableInfo
+	for i := range b.tables {
+		if b.selected[i] {
+			result = append(result, &b.tables[i])
+		}
+	}
+	return result
+}
+
+func (b *browser) listHeight() int {
+	return b.height - 4
+}
+
+func (b *browser) render() {
+	b.width, b.height = 80, 24
+	if w, h, err := term.GetSize(int(os.Stderr.Fd())); err == nil {
+		b.width, b.height = w, h
+	}
+
+	// Keep the cursor in view
+	if b.cursor < b.top {
+		b.top = b.cursor
+	}
+	if b.cursor >= b.top+b.listHeight() {
+		b.top = b.cursor - b.listHeight() + 1
+	}
+
+	listWidth := b.width * 2 / 5
+	if listWidth < 20 {
+		listWidth = b.width
+	}
+	left := b.listLines(listWidth)
+	var right []string
+	if listWidth < b.width {
+		right = b.detailLines(b.width - listWidth - 3)
+	}
+
+	out := b.out
+	out.WriteString("\x1b[H")
+	header := fmt.Sprintf("%s: %d tables, %d selected", b.filename, len(b.tables), len(b.selected))
+	writeLine(out, "\x1b[1m"+fit(header, b.width)+"\x1b[0m")
+	writeLine(out, fit("Search: "+b.query+"_", b.width))
+	writeLine(out, strings.Repeat("─", b.width))
+	for i := 0; i < b.listHeight(); i++ {
+		var l, r string
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		line := pad(l, listWidth)
+		if right != nil {
+			line += " │ " + r
+		}
+		writeLine(out, line)
+	}
+	help := "↑/↓ move  space select  tab select and move  ctrl-a select all  type to search  enter export  esc quit"
+	out.WriteString("\x1b[7m" + pad(fit(help, b.width), b.width) + "\x1b[0m")
+	out.Flush()
+}
+
+// listLines renders the visible part of the table list.
+func (b *browser) listLines(width int) []string {
+	var lines []string
+	for i := b.top; i < len(b.matches) && i < b.top+b.listHeight(); i++ {
+		table := b.tables[b.matches[i]]
+		mark := "[ ]"
+		if b.selected[b.matches[i]] {
+			mark = "[x]"
+		}
+		rows := formatCount(table.Rows)
+		name := fit(qualifiedName(table), width-len(mark)-len(rows)-4)
+		line := fmt.Sprintf(" %s %s", mark, pad(name, width-len(mark)-len(rows)-3)) + rows
+		if i == b.cursor {
+			// pad before adding escape sequences, which take no space
+			line = "\x1b[7m" + pad(line, width) + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+	if len(b.matches) == 0 {
+		lines = append(lines, " no tables match")
+	}
+	return lines
+}
+
+// detailLines renders the details and row preview of the highlighted table.
+func (b *browser) detailLines(width int) []string {
+	if len(b.matches) == 0 {
+		return nil
+	}
+	index := b.matches[b.cursor]
+	table := b.tables[index]
+
+	lines := []string{
+		fit("Table:   "+qualifiedName(table), width),
+		fit(fmt.Sprintf("Rows:    %d", table.Rows), width),
+		fit(fmt.Sprintf("Lines:   %d-%d", table.LineFrom, table.LineTo), width),
+	}
+	lines = append(lines, wrap("Columns: ", strings.Join(table.Columns, ", "), width)...)
+	lines = append(lines, "", "Preview:")
+
+	p, ok := b.previews[index]
+	if !ok {
+		rows, err := parser.PreviewTable(b.filename, table, previewRows)
+		p = &preview{rows: rows, err: err}
+		b.previews[index] = p
+	}
+	switch {
+	case p.err != nil:
+		lines = append(lines, fit("error: "+p.err.Error(), width))
+	case len(p.rows) == 0:
+		lines = append(lines, "no rows")
+	default:
+		lines = append(lines, previewGrid(table.Columns, p.rows, width)...)
+	}
+	return lines
+}
+
+// previewGrid lays out rows as a table with one column per table column, as
+// many as fit into width.
+func previewGrid(columns []string, rows []models.Row, width int) []string {
+	const maxColumnWidth = 20
+	if len(columns) == 0 {
+		for col := range rows[0].Data {
+			columns = append(columns, col)
+		}
+		sort.Strings(columns)
+	}
+
+	cells := make([][]string, len(rows)+1)
+	cells[0] = columns
+	for i, row := range rows {
+		for _, col := range columns {
+			value := "NULL"
+			if v := row.Data[col]; v != nil {
+				value = strings.ReplaceAll(fmt.Sprint(v), "\n", " ")
+			}
+			cells[i+1] = append(cells[i+1], value)
+		}
+	}
+
+	lines := make([]string, len(cells))
+	used := 0
+	for c := range columns {
+		w := 0
+		for _, row := range cells {
+			if n := utf8.RuneCountInString(row[c]); n > w {
+				w = n
+			}
+		}
+		if w > maxColumnWidth {
+			w = maxColumnWidth
+		}
+		if used+w > width {
+			break
+		}
+		for i, row := range cells {
+			lines[i] += pad(fit(row[c], w), w) + "  "
+		}
+		used += w + 2
+	}
+	lines[0] = "\x1b[1m" + lines[0] + "\x1b[0m"
+	return lines
+}
+
+func qualifiedName(table parser.TableInfo) string {
+	if table.Database != "" {
+		return table.Database + "." + table.Name
+	}
+	return table.Name
+}
+
+// readKey reads one key press and names the special keys.
+func readKey(r *bufio.Reader) (string, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	switch c {
+	case '\r', '\n':
+		return "enter", nil
+	case '\t':
+		return "tab", nil
+	case 0x7f, 0x08:
+		return "backspace", nil
+	case 0x01:
+		return "ctrl-a", nil
+	case 0x03:
+		return "ctrl-c", nil
+	case 0x0e:
+		return "down", nil // ctrl-n
+	case 0x10:
+		return "up", nil // ctrl-p
+	case 0x15:
+		return "ctrl-u", nil
+	case 0x1b:
+		// A lone escape, or the start of an escape sequence that arrived
+		// in the same read
+		if r.Buffered() == 0 {
+			return "esc", nil
+		}
+		return readEscape(r)
+	}
+	return string(c), nil
+}
+
+func readEscape(r *bufio.Reader) (string, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if c != '[' && c != 'O' {
+		return "esc", nil
+	}
+	seq := ""
+	for r.Buffered() > 0 {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		seq += string(c)
+		if c >= 0x40 && c <= 0x7e {
+			break
+		}
+	}
+	switch seq {
+	case "A":
+		return "up", nil
+	case "B":
+		return "down", nil
+	case "H", "1~":
+		return "home", nil
+	case "F", "4~":
+		return "end", nil
+	case "5~":
+		return "pgup", nil
+	case "6~":
+		return "pgdown", nil
+	}
+	return "", nil
+}
+
+// writeLine writes s and clears the rest of the terminal line.
+func writeLine(w *bufio.Writer, s string) {
+	w.WriteString(s + "\x1b[K\r\n")
+}
+
+// fit shortens s to at most width characters.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	if width == 1 {
+		return string(r[:1])
+	}
+	return string(r[:width-1]) + "…"
+}
+
+// pad extends s with spaces to width characters.
+func pad(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// wrap breaks text into lines of at most width characters, the first one
+// starting with label and the others indented to match.
+func wrap(label, text string, width int) []string {
+	indent := strings.Repeat(" ", len(label))
+	var lines []string
+	line := label
+	for _, word := range strings.Fields(text) {
+		if utf8.RuneCountInString(line)+utf8.RuneCountInString(word) > width && line != label && line != indent {
+			lines = append(lines, line)
+			line = indent
+		}
+		line += word + " "
+	}
+	return append(lines, fit(line, width))
+}
+
+func formatCount(n int) string {
+	switch {
+	case n >= 1e6:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1e4:
+		return fmt.Sprintf("%.0fk", float64(n)/1e3)
+	}
+	return fmt.Sprint(n)
+}
@@ -0,0 +1,149 @@
+package tui
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+)
+
+func TestBrowserKeys(t *testing.T) {
+	tables := []parser.TableInfo{
+		{Name: "users", Database: "shop"},
+		{Name: "orders", Database: "shop"},
+		{Name: "order_items", Database: "shop"},
+		{Name: "log"},
+	}
+	tests := []struct {
+		name string
+		keys []string
+		want []string // the tables returned, or nil if the browser isn't done
+		err  error
+	}{
+		{name: "highlighted table", keys: []string{"down", "enter"}, want: []string{"orders"}},
+		{name: "cursor stops at the end", keys: []string{"end", "down", "down", "enter"}, want: []string{"log"}},
+		{name: "search", keys: []string{"o", "i", "enter"}, want: []string{"order_items"}},
+		{name: "backspace", keys: []string{"l", "x", "backspace", "enter"}, want: []string{"log"}},
+		{name: "clear the query", keys: []string{"l", "o", "g", "ctrl-u", "enter"}, want: []string{"users"}},
+		{name: "selection in file order", keys: []string{"end", " ", "home", "tab", "tab", "enter"}, want: []string{"users", "orders", "log"}},
+		{name: "unselect", keys: []string{" ", " ", "down", " ", "enter"}, want: []string{"orders"}},
+		{name: "select all matches", keys: []string{"o", "r", "d", "ctrl-a", "ctrl-u", "enter"}, want: []string{"orders", "order_items"}},
+		{name: "select all twice clears", keys: []string{"ctrl-a", "ctrl-a", "end", "enter"}, want: []string{"log"}},
+		{name: "nothing to choose", keys: []string{"x", "y", "z", "enter"}},
+		{name: "cancel", keys: []string{" ", "esc"}, err: ErrCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &browser{tables: tables, selected: make(map[int]bool), height: 24}
+			b.filter()
+			var done bool
+			var err error
+			for _, key := range tt.keys {
+				if done, err = b.handle(key); done {
+					break
+				}
+			}
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if done != (tt.want != nil) {
+				t.Fatalf("done = %v", done)
+			}
+			if !done {
+				return
+			}
+			var got []string
+			for _, table := range b.result() {
+				got = append(got, table.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	input := "a\r\t\x7f\x01\x03\x0e\x10\x15é\x1b[A\x1b[B\x1b[H\x1b[4~\x1b[5~\x1b[6~\x1bOH\x1b"
+	want := []string{"a", "enter", "tab", "backspace", "ctrl-a", "ctrl-c", "down", "up", "ctrl-u", "é",
+		"up", "down", "home", "end", "pgup", "pgdown", "home", "esc"}
+	r := bufio.NewReader(strings.NewReader(input))
+	for _, w := range want {
+		got, err := readKey(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != w {
+			t.Errorf("got key %q, want %q", got, w)
+		}
+	}
+}
+
+func TestPreviewGrid(t *testing.T) {
+	rows := []models.Row{
+		{Data: map[string]interface{}{"id": "1", "name": "ann", "bio": "two\nlines"}},
+		{Data: map[string]interface{}{"id": "22", "name": nil, "bio": strings.Repeat("x", 30)}},
+	}
+	tests := []struct {
+		columns []string
+		width   int
+		want    []string
+	}{
+		{
+			columns: []string{"id", "name", "bio"},
+			width:   80,
+			want: []string{
+				"\x1b[1mid  name  bio                   \x1b[0m",
+				"1   ann   two lines             ",
+				"22  NULL  xxxxxxxxxxxxxxxxxxx…  ",
+			},
+		},
+		// Only the columns that fit
+		{
+			columns: []string{"id", "name", "bio"},
+			width:   10,
+			want:    []string{"\x1b[1mid  name  \x1b[0m", "1   ann   ", "22  NULL  "},
+		},
+		// Without a schema, the columns are sorted by name
+		{
+			width: 22,
+			want: []string{
+				"\x1b[1mbio                   \x1b[0m",
+				"two lines             ",
+				"xxxxxxxxxxxxxxxxxxx…  ",
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := previewGrid(tt.columns, rows, tt.width)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("previewGrid(%v, %d) =\n%q\nwant\n%q", tt.columns, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"users", 10, "users"},
+		{"users", 5, "users"},
+		{"users", 4, "use…"},
+		{"ünïcode", 3, "ün…"},
+		{"users", 1, "u"},
+		{"users", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := fit(tt.s, tt.width); got != tt.want {
+			t.Errorf("fit(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
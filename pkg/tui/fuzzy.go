@@ -0,0 +1,42 @@
+package tui
+
+import (
+	"strings"
+)
+
+// fuzzyScore matches query against name as a case-insensitive subsequence.
+// Higher scores are better matches: consecutive characters, characters at
+// the start of a word and short names score higher.
+func fuzzyScore(name, query string) (int, bool) {
+	if query == "" {
+		return 0, true
+	}
+	lowerName := strings.ToLower(name)
+	lowerQuery := strings.ToLower(query)
+
+	score := 0
+	qi := 0
+	prev := -2
+	for i := 0; i < len(lowerName) && qi < len(lowerQuery); i++ {
+		if lowerName[i] != lowerQuery[qi] {
+			continue
+		}
+		score += 1
+		if i == prev+1 {
+			score += 5 // consecutive
+		}
+		if i == 0 || isSeparator(lowerName[i-1]) {
+			score += 3 // start of a word
+		}
+		prev = i
+		qi++
+	}
+	if qi < len(lowerQuery) {
+		return 0, false
+	}
+	return score*100 - len(name), true
+}
+
+func isSeparator(c byte) bool {
+	return c == '_' || c == '.' || c == '-'
+}
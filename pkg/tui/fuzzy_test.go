@@ -0,0 +1,50 @@
+package tui
+
+import "testing"
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		name, query string
+		ok          bool
+	}{
+		{"users", "", true},
+		{"users", "usr", true},
+		{"users", "USR", true},
+		{"shop.order_items", "oi", true},
+		{"users", "sru", false},
+		{"users", "userss", false},
+	}
+	for _, tt := range tests {
+		if _, ok := fuzzyScore(tt.name, tt.query); ok != tt.ok {
+			t.Errorf("fuzzyScore(%q, %q) matched = %v, want %v", tt.name, tt.query, ok, tt.ok)
+		}
+	}
+}
+
+func TestFuzzyScoreOrder(t *testing.T) {
+	// Each name is a better match for the query than the next one
+	tests := []struct {
+		query string
+		names []string
+	}{
+		// Consecutive characters
+		{"user", []string{"user_log", "u_s_e_r"}},
+		// The start of a word
+		{"oi", []string{"order_items", "options"}},
+		// The shorter name
+		{"user", []string{"users", "users_archive"}},
+	}
+	for _, tt := range tests {
+		prev := 0
+		for i, name := range tt.names {
+			score, ok := fuzzyScore(name, tt.query)
+			if !ok {
+				t.Fatalf("fuzzyScore(%q, %q) didn't match", name, tt.query)
+			}
+			if i > 0 && score >= prev {
+				t.Errorf("%q scores %d for %q, no less than %q with %d", name, score, tt.query, tt.names[i-1], prev)
+			}
+			prev = score
+		}
+	}
+}
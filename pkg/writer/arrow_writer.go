@@ -0,0 +1,481 @@
+package writer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/klauspost/compress/zstd"
+
+	"sqlparser/pkg/models"
+)
+
+// Arrow's type ids, message types and enums, as numbered in its FlatBuffers
+// definitions.
+const (
+	arrowInt           = 2
+	arrowFloatingPoint = 3
+	arrowBinary        = 4
+	arrowUtf8          = 5
+	arrowBool          = 6
+	arrowDecimal       = 7
+	arrowDate          = 8
+	arrowTimestamp     = 10
+
+	arrowSchemaMessage      = 1
+	arrowRecordBatchMessage = 3
+
+	arrowMetadataV5   = 4
+	arrowSingle       = 1
+	arrowDouble       = 2
+	arrowDay          = 0
+	arrowMicrosecond  = 2
+	arrowZstd         = 1
+	arrowDecimalWidth = 128
+)
+
+var (
+	arrowMagic        = []byte("ARROW1")
+	arrowFileStart    = []byte("ARROW1\x00\x00") // padded to 8 bytes
+	arrowContinuation = []byte{0xff, 0xff, 0xff, 0xff}
+	arrowEndOfStream  = []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}
+)
+
+// ArrowWriter writes the rows of a single table in Arrow's IPC file format
+// (also known as Feather v2), or in its stream format. Every batch of rows
+// becomes a record batch. Column types come from the schema passed to
+// WriteSchema; without one, every column is a string.
+type ArrowWriter struct {
+	out      *bufio.Writer
+	offset   int64
+	stream   bool
+	compress func([]byte) []byte // nil for uncompressed buffers
+
+	table   string
+	schema  *models.Schema
+	columns []typedColumn
+	started bool
+	batches []arrowBlock
+}
+
+// arrowBlock is the location of a record batch in a file, for its footer.
+type arrowBlock struct {
+	offset     int64
+	metaLength int32
+	bodyLength int64
+}
+
+// arrowBuffer is the location of a buffer in the body of a record batch.
+type arrowBuffer struct {
+	offset int64
+	length int64
+}
+
+// NewArrowWriter creates a writer for the IPC file format, or the stream
+// format if stream is set, that compresses buffers with compression: zstd
+// or none (the default).
+func NewArrowWriter(output *bufio.Writer, stream bool, compression string) (*ArrowWriter, error) {
+	compress, err := arrowCodec(compression)
+	if err != nil {
+		return nil, err
+	}
+	return &ArrowWriter{out: output, stream: stream, compress: compress}, nil
+}
+
+func arrowCodec(compression string) (func([]byte) []byte, error) {
+	switch compression {
+	case "", "none":
+		return nil, nil
+	case "zstd":
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		return func(src []byte) []byte { return enc.EncodeAll(src, nil) }, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression for arrow: %s (want zstd or none)", compression)
+	}
+}
+
+// WriteSchema sets the columns and types of the table. It has to be called
+// before the first rows are written.
+func (w *ArrowWriter) WriteSchema(schema *models.Schema) error {
+	w.schema = schema
+	return nil
+}
+
+func (w *ArrowWriter) WriteTableStart(tableName string) error {
+	if w.table != "" && w.table != tableName {
+		return fmt.Errorf("an arrow file holds a single table, can't add %s to %s: write to a directory instead", tableName, w.table)
+	}
+	w.table = tableName
+	return nil
+}
+
+func (w *ArrowWriter) WriteRows(rows []models.Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if !w.started {
+		if w.schema != nil && len(w.schema.Columns) > 0 {
+			w.columns = typedColumns(w.schema)
+		} else {
+			w.columns = columnsFromRow(rows[0])
+		}
+		if err := w.start(); err != nil {
+			return err
+		}
+	}
+
+	var body []byte
+	var buffers []arrowBuffer
+	nodes := make([][2]int64, len(w.columns)) // length and null count
+	values := make([]interface{}, len(rows))
+	for i, col := range w.columns {
+		nulls := 0
+		for j, row := range rows {
+			value, err := col.value(row)
+			if err != nil {
+				return err
+			}
+			if value == nil {
+				nulls++
+			}
+			values[j] = value
+		}
+		nodes[i] = [2]int64{int64(len(rows)), int64(nulls)}
+
+		var validity []byte
+		if nulls > 0 {
+			validity = appendValidity(nil, values)
+		}
+		data, err := arrowColumnBuffers(col.typ, values)
+		if err != nil {
+			return fmt.Errorf("column %s: %v", col.name, err)
+		}
+		for _, buf := range append([][]byte{validity}, data...) {
+			body, buffers = w.appendBuffer(body, buffers, buf)
+		}
+	}
+
+	var b flatBuilder
+	b.vectorStart(16, len(buffers), 8)
+	for i := len(buffers) - 1; i >= 0; i-- {
+		b.putUint64(uint64(buffers[i].length))
+		b.putUint64(uint64(buffers[i].offset))
+	}
+	bufferVector := b.vectorEnd()
+	b.vectorStart(16, len(nodes), 8)
+	for i := len(nodes) - 1; i >= 0; i-- {
+		b.putUint64(uint64(nodes[i][1]))
+		b.putUint64(uint64(nodes[i][0]))
+	}
+	nodeVector := b.vectorEnd()
+	var compression uint32
+	if w.compress != nil {
+		b.tableStart(2)
+		b.fieldUint8(0, arrowZstd)
+		compression = b.tableEnd()
+	}
+	b.tableStart(4)
+	b.fieldInt64(0, int64(len(rows)))
+	b.fieldOffset(1, nodeVector)
+	b.fieldOffset(2, bufferVector)
+	if compression != 0 {
+		b.fieldOffset(3, compression)
+	}
+	batch := b.tableEnd()
+
+	block, err := w.writeMessage(&b, arrowRecordBatchMessage, batch, body)
+	if err != nil {
+		return err
+	}
+	w.batches = append(w.batches, block)
+	return nil
+}
+
+// appendBuffer appends buf to the body of a record batch, compressed if set,
+// and padded to 8 bytes.
+func (w *ArrowWriter) appendBuffer(body []byte, buffers []arrowBuffer, buf []byte) ([]byte, []arrowBuffer) {
+	offset := int64(len(body))
+	if len(buf) > 0 && w.compress != nil {
+		body = binary.LittleEndian.AppendUint64(body, uint64(len(buf)))
+		buf = w.compress(buf)
+	}
+	body = append(body, buf...)
+	buffers = append(buffers, arrowBuffer{offset: offset, length: int64(len(body)) - offset})
+	for len(body)%8 != 0 {
+		body = append(body, 0)
+	}
+	return body, buffers
+}
+
+// appendValidity appends a bitmap of the values that aren't null.
+func appendValidity(dst []byte, values []interface{}) []byte {
+	for i := 0; i < len(values); i += 8 {
+		var b byte
+		for j := 0; j < 8 && i+j < len(values); j++ {
+			if values[i+j] != nil {
+				b |= 1 << j
+			}
+		}
+		dst = append(dst, b)
+	}
+	return dst
+}
+
+// arrowColumnBuffers encodes converted values of type t as the buffers that
+// follow the validity bitmap of an Arrow array: offsets and data for strings
+// and binary, data for the rest. Null values are left zero.
+func arrowColumnBuffers(t columnType, values []interface{}) ([][]byte, error) {
+	switch t.kind {
+	case kindString, kindBytes:
+		offsets := make([]byte, 0, 4*(len(values)+1))
+		var data []byte
+		offsets = binary.LittleEndian.AppendUint32(offsets, 0)
+		for _, v := range values {
+			switch v := v.(type) {
+			case string:
+				data = append(data, v...)
+			case []byte:
+				data = append(data, v...)
+			}
+			if len(data) > math.MaxInt32 {
+				return nil, fmt.Errorf("more than 2 GiB of data in a batch: use a smaller -batch-size")
+			}
+			offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(data)))
+		}
+		return [][]byte{offsets, data}, nil
+	case kindBool:
+		data := make([]byte, (len(values)+7)/8)
+		for i, v := range values {
+			if b, _ := v.(bool); b {
+				data[i/8] |= 1 << (i % 8)
+			}
+		}
+		return [][]byte{data}, nil
+	case kindDecimal:
+		data := make([]byte, 0, 16*len(values))
+		for _, v := range values {
+			data = appendDecimal128(data, v)
+		}
+		return [][]byte{data}, nil
+	}
+
+	var data []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case int32:
+			data = binary.LittleEndian.AppendUint32(data, uint32(v))
+		case int64:
+			data = binary.LittleEndian.AppendUint64(data, uint64(v))
+		case float32:
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(v))
+		case float64:
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+		case nil:
+			switch t.kind {
+			case kindInt32, kindFloat32, kindDate:
+				data = append(data, 0, 0, 0, 0)
+			default:
+				data = append(data, 0, 0, 0, 0, 0, 0, 0, 0)
+			}
+		}
+	}
+	return [][]byte{data}, nil
+}
+
+// appendDecimal128 appends an unscaled decimal, an int64 or *big.Int, as a
+// 128-bit little-endian two's complement number.
+func appendDecimal128(dst []byte, v interface{}) []byte {
+	var n *big.Int
+	switch v := v.(type) {
+	case int64:
+		n = big.NewInt(v)
+	case *big.Int:
+		n = v
+	default:
+		return append(dst, make([]byte, 16)...)
+	}
+	be := appendTwosComplement(nil, n, 16)
+	for i := len(be) - 1; i >= 0; i-- {
+		dst = append(dst, be[i])
+	}
+	return dst
+}
+
+// start writes the beginning of the file and the schema message.
+func (w *ArrowWriter) start() error {
+	w.started = true
+	if !w.stream {
+		if err := w.write(arrowFileStart); err != nil {
+			return err
+		}
+	}
+	var b flatBuilder
+	schema := w.buildSchema(&b)
+	_, err := w.writeMessage(&b, arrowSchemaMessage, schema, nil)
+	return err
+}
+
+// buildSchema builds the Schema table describing the columns.
+func (w *ArrowWriter) buildSchema(b *flatBuilder) uint32 {
+	fields := make([]uint32, len(w.columns))
+	for i, col := range w.columns {
+		typeID, typ := buildArrowType(b, col.typ)
+		name := b.string(col.name)
+		children := b.offsets(nil)
+		b.tableStart(6)
+		b.fieldOffset(0, name)
+		b.fieldBool(1, col.nullable)
+		b.fieldUint8(2, typeID)
+		b.fieldOffset(3, typ)
+		b.fieldOffset(5, children)
+		fields[i] = b.tableEnd()
+	}
+	fieldVector := b.offsets(fields)
+	b.tableStart(4)
+	b.fieldInt16(0, 0) // little-endian
+	b.fieldOffset(1, fieldVector)
+	return b.tableEnd()
+}
+
+// buildArrowType builds the type table of a column, returning its type id.
+func buildArrowType(b *flatBuilder, t columnType) (uint8, uint32) {
+	switch t.kind {
+	case kindBytes:
+		b.tableStart(0)
+		return arrowBinary, b.tableEnd()
+	case kindBool:
+		b.tableStart(0)
+		return arrowBool, b.tableEnd()
+	case kindInt32, kindInt64:
+		b.tableStart(2)
+		if t.kind == kindInt32 {
+			b.fieldInt32(0, 32)
+		} else {
+			b.fieldInt32(0, 64)
+		}
+		b.fieldBool(1, true)
+		return arrowInt, b.tableEnd()
+	case kindFloat32, kindFloat64:
+		b.tableStart(1)
+		if t.kind == kindFloat32 {
+			b.fieldInt16(0, arrowSingle)
+		} else {
+			b.fieldInt16(0, arrowDouble)
+		}
+		return arrowFloatingPoint, b.tableEnd()
+	case kindDecimal:
+		b.tableStart(3)
+		b.fieldInt32(0, int32(t.precision))
+		b.fieldInt32(1, int32(t.scale))
+		b.fieldInt32(2, arrowDecimalWidth)
+		return arrowDecimal, b.tableEnd()
+	case kindDate:
+		b.tableStart(1)
+		b.fieldInt16(0, arrowDay)
+		return arrowDate, b.tableEnd()
+	case kindTimestamp:
+		// Without a time zone, as the values are local times
+		b.tableStart(2)
+		b.fieldInt16(0, arrowMicrosecond)
+		return arrowTimestamp, b.tableEnd()
+	default:
+		b.tableStart(0)
+		return arrowUtf8, b.tableEnd()
+	}
+}
+
+// writeMessage writes a message with header of type typ, built in b, and
+// body. It returns the location of the message.
+func (w *ArrowWriter) writeMessage(b *flatBuilder, typ uint8, header uint32, body []byte) (arrowBlock, error) {
+	b.tableStart(5)
+	b.fieldInt64(3, int64(len(body)))
+	b.fieldOffset(2, header)
+	b.fieldInt16(0, arrowMetadataV5)
+	b.fieldUint8(1, typ)
+	meta := b.finish(b.tableEnd())
+
+	// The continuation marker, the length and the metadata are padded to 8
+	// bytes
+	padding := -(8 + len(meta)) & 7
+	block := arrowBlock{offset: w.offset, metaLength: int32(8 + len(meta) + padding), bodyLength: int64(len(body))}
+	if err := w.write(arrowContinuation); err != nil {
+		return block, err
+	}
+	if err := w.write(binary.LittleEndian.AppendUint32(nil, uint32(len(meta)+padding))); err != nil {
+		return block, err
+	}
+	if err := w.write(append(meta, make([]byte, padding)...)); err != nil {
+		return block, err
+	}
+	return block, w.write(body)
+}
+
+func (w *ArrowWriter) write(p []byte) error {
+	n, err := w.out.Write(p)
+	w.offset += int64(n)
+	return err
+}
+
+func (w *ArrowWriter) WriteTableEnd() error {
+	return w.out.Flush()
+}
+
+// Close writes the end-of-stream marker and, in the file format, the footer.
+// A table without rows gets its columns from the schema alone.
+func (w *ArrowWriter) Close() error {
+	if !w.started {
+		if w.schema != nil {
+			w.columns = typedColumns(w.schema)
+		}
+		if err := w.start(); err != nil {
+			return err
+		}
+	}
+	if err := w.write(arrowEndOfStream); err != nil {
+		return err
+	}
+	if !w.stream {
+		footer := w.footer()
+		if err := w.write(footer); err != nil {
+			return err
+		}
+		if err := w.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer)))); err != nil {
+			return err
+		}
+		if err := w.write(arrowMagic); err != nil {
+			return err
+		}
+	}
+	return w.out.Flush()
+}
+
+// footer encodes the Footer table of the file format.
+func (w *ArrowWriter) footer() []byte {
+	var b flatBuilder
+	b.vectorStart(24, len(w.batches), 8)
+	for i := len(w.batches) - 1; i >= 0; i-- {
+		b.putUint64(uint64(w.batches[i].bodyLength))
+		b.putUint32(0)
+		b.putUint32(uint32(w.batches[i].metaLength))
+		b.putUint64(uint64(w.batches[i].offset))
+	}
+	batches := b.vectorEnd()
+	schema := w.buildSchema(&b)
+	b.tableStart(4)
+	b.fieldOffset(1, schema)
+	b.fieldOffset(3, batches)
+	b.fieldInt16(0, arrowMetadataV5)
+	return b.finish(b.tableEnd())
+}
+
+func (w *ArrowWriter) Type() models.OutputFormat {
+	if w.stream {
+		return models.FormatArrowStream
+	}
+	return models.FormatArrow
+}
@@ -0,0 +1,375 @@
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// typedArrowValues are typedValues as ArrowWriter stores them: decimals of
+// either width are 128-bit.
+var typedArrowValues = func() map[string][]interface{} {
+	values := make(map[string][]interface{}, len(typedValues))
+	for name, v := range typedValues {
+		values[name] = v
+	}
+	values["amount"] = []interface{}{"1234", "-1", nil}
+	return values
+}()
+
+// typedArrowFields are the fields of typedSchema as described by
+// describeArrowField.
+var typedArrowFields = []string{
+	"id int32",
+	"amount decimal(10,2) nullable",
+	"big decimal(30,4) nullable",
+	"day date[day] nullable",
+	"at timestamp[us] nullable",
+	"flag bool nullable",
+	"ratio float64 nullable",
+	"name utf8 nullable",
+	"raw binary nullable",
+}
+
+func TestArrowWriter(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		for _, compression := range []string{"none", "zstd"} {
+			t.Run(fmt.Sprintf("stream=%v/%s", stream, compression), func(t *testing.T) {
+				var buf bytes.Buffer
+				w, err := NewArrowWriter(bufio.NewWriter(&buf), stream, compression)
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeTable(t, w, typedSchema, typedRows)
+
+				fields, values, batches := readArrow(t, buf.Bytes(), stream)
+				if !reflect.DeepEqual(fields, typedArrowFields) {
+					t.Errorf("fields differ:\n got %q\nwant %q", fields, typedArrowFields)
+				}
+				if batches != 1 {
+					t.Errorf("got %d record batches, want 1", batches)
+				}
+				if !reflect.DeepEqual(values, typedArrowValues) {
+					t.Errorf("values differ:\n got %v\nwant %v", values, typedArrowValues)
+				}
+			})
+		}
+	}
+}
+
+func TestArrowSchema(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		var buf bytes.Buffer
+		w, err := NewArrowWriter(bufio.NewWriter(&buf), stream, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Without rows, the columns come from the schema alone
+		writeTable(t, w, typedSchema, nil)
+		fields, values, batches := readArrow(t, buf.Bytes(), stream)
+		if !reflect.DeepEqual(fields, typedArrowFields) {
+			t.Errorf("stream=%v: fields differ:\n got %q\nwant %q", stream, fields, typedArrowFields)
+		}
+		if batches != 0 || len(values) != 0 {
+			t.Errorf("stream=%v: got %d record batches, want none", stream, batches)
+		}
+	}
+}
+
+func TestArrowWriterSingleTable(t *testing.T) {
+	w, err := NewArrowWriter(bufio.NewWriter(&bytes.Buffer{}), false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteTableStart("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteTableStart("b"); err == nil {
+		t.Error("a second table was accepted")
+	}
+	if _, err := NewArrowWriter(bufio.NewWriter(&bytes.Buffer{}), false, "snappy"); err == nil {
+		t.Error("snappy compression was accepted")
+	}
+}
+
+// readArrow decodes a file or stream as written by ArrowWriter, returning
+// its fields as described by describeArrowField, the values of each column
+// and the number of record batches. Decimals are returned as the decimal
+// string of their unscaled value.
+func readArrow(t *testing.T, data []byte, stream bool) ([]string, map[string][]interface{}, int) {
+	t.Helper()
+	var footer flatTable
+	if !stream {
+		if !bytes.HasPrefix(data, arrowFileStart) || !bytes.HasSuffix(data, arrowMagic) {
+			t.Fatal("missing magic")
+		}
+		end := len(data) - len(arrowMagic) - 4
+		footerLen := int(binary.LittleEndian.Uint32(data[end:]))
+		footer = rootTable(data[end-footerLen : end])
+		if v := footer.int16(0); v != arrowMetadataV5 {
+			t.Errorf("footer version = %d", v)
+		}
+		data = data[len(arrowFileStart) : end-footerLen]
+		if !bytes.HasSuffix(data, arrowEndOfStream) {
+			t.Fatal("missing end-of-stream marker")
+		}
+	}
+
+	var fields []string
+	var types []flatTable
+	values := make(map[string][]interface{})
+	batches := 0
+	pos := 0
+	for {
+		if !bytes.Equal(data[pos:pos+4], arrowContinuation) {
+			t.Fatalf("no continuation marker at %d", pos)
+		}
+		metaLen := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if metaLen == 0 {
+			if pos+8 != len(data) {
+				t.Fatalf("%d bytes after the end-of-stream marker", len(data)-pos-8)
+			}
+			break
+		}
+		if (8+metaLen)%8 != 0 {
+			t.Fatalf("metadata of %d bytes isn't padded to 8", metaLen)
+		}
+		message := rootTable(data[pos+8 : pos+8+metaLen])
+		if v := message.int16(0); v != arrowMetadataV5 {
+			t.Errorf("message version = %d", v)
+		}
+		bodyLen := int(message.int64(3))
+		body := data[pos+8+metaLen : pos+8+metaLen+bodyLen]
+
+		switch message.uint8(1) {
+		case arrowSchemaMessage:
+			if fields != nil {
+				t.Fatal("second schema message")
+			}
+			fields, types = describeArrowSchema(message.table(2))
+			if !stream {
+				if footerFields, _ := describeArrowSchema(footer.table(1)); !reflect.DeepEqual(footerFields, fields) {
+					t.Errorf("footer schema %q differs from %q", footerFields, fields)
+				}
+			}
+		case arrowRecordBatchMessage:
+			if !stream {
+				start, n := footer.vector(3)
+				if batches >= n {
+					t.Fatalf("record batch %d is missing from the footer", batches)
+				}
+				block := footer.buf[start+24*batches:]
+				offset := int(binary.LittleEndian.Uint64(block))
+				meta := int(binary.LittleEndian.Uint32(block[8:]))
+				length := int(binary.LittleEndian.Uint64(block[16:]))
+				if offset != len(arrowFileStart)+pos || meta != 8+metaLen || length != bodyLen {
+					t.Errorf("footer block %d = %d, %d, %d, want %d, %d, %d", batches,
+						offset, meta, length, len(arrowFileStart)+pos, 8+metaLen, bodyLen)
+				}
+			}
+			decodeArrowBatch(t, message.table(2), body, fields, types, values)
+			batches++
+		default:
+			t.Fatalf("unexpected message type %d", message.uint8(1))
+		}
+		pos += 8 + metaLen + bodyLen
+	}
+	if !stream {
+		if _, n := footer.vector(3); n != batches {
+			t.Errorf("footer has %d record batches, read %d", n, batches)
+		}
+	}
+	return fields, values, batches
+}
+
+// describeArrowSchema returns the fields of a Schema table as "name type",
+// followed by "nullable" if they are, and the Field tables.
+func describeArrowSchema(schema flatTable) ([]string, []flatTable) {
+	var names []string
+	fields := schema.tables(1)
+	for _, field := range fields {
+		names = append(names, describeArrowField(field))
+	}
+	return names, fields
+}
+
+func describeArrowField(field flatTable) string {
+	typ := field.table(3)
+	var desc string
+	switch field.uint8(2) {
+	case arrowInt:
+		desc = fmt.Sprintf("int%d", typ.int32(0))
+		if typ.uint8(1) == 0 {
+			desc = "u" + desc
+		}
+	case arrowFloatingPoint:
+		desc = map[int16]string{arrowSingle: "float32", arrowDouble: "float64"}[typ.int16(0)]
+	case arrowBinary:
+		desc = "binary"
+	case arrowUtf8:
+		desc = "utf8"
+	case arrowBool:
+		desc = "bool"
+	case arrowDecimal:
+		desc = fmt.Sprintf("decimal(%d,%d)", typ.int32(0), typ.int32(1))
+		if typ.int32(2) != arrowDecimalWidth {
+			desc += fmt.Sprintf("[%d bits]", typ.int32(2))
+		}
+	case arrowDate:
+		desc = map[int16]string{arrowDay: "date[day]"}[typ.int16(0)]
+	case arrowTimestamp:
+		desc = map[int16]string{arrowMicrosecond: "timestamp[us]"}[typ.int16(0)]
+		if typ.has(1) {
+			desc += " " + typ.string(1)
+		}
+	default:
+		desc = fmt.Sprintf("type %d", field.uint8(2))
+	}
+	desc = field.string(0) + " " + desc
+	if field.uint8(1) != 0 {
+		desc += " nullable"
+	}
+	return desc
+}
+
+// decodeArrowBatch appends the values in a RecordBatch table and its body to
+// values.
+func decodeArrowBatch(t *testing.T, batch flatTable, body []byte, names []string, fields []flatTable, values map[string][]interface{}) {
+	t.Helper()
+	n := int(batch.int64(0))
+	nodes, nodeCount := batch.vector(1)
+	buffers, bufferCount := batch.vector(2)
+	if nodeCount != len(fields) {
+		t.Fatalf("%d nodes for %d fields", nodeCount, len(fields))
+	}
+	compressed := batch.has(3)
+	if compressed && batch.table(3).uint8(0) != arrowZstd {
+		t.Fatalf("unexpected codec %d", batch.table(3).uint8(0))
+	}
+
+	next := 0
+	buffer := func() []byte {
+		if next >= bufferCount {
+			t.Fatal("too few buffers")
+		}
+		p := buffers + 16*next
+		next++
+		offset := int(binary.LittleEndian.Uint64(batch.buf[p:]))
+		length := int(binary.LittleEndian.Uint64(batch.buf[p+8:]))
+		if offset%8 != 0 {
+			t.Errorf("buffer %d at %d isn't aligned to 8 bytes", next-1, offset)
+		}
+		buf := body[offset : offset+length]
+		if !compressed || len(buf) == 0 {
+			return buf
+		}
+		size := int(binary.LittleEndian.Uint64(buf))
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dec.Close()
+		out, err := dec.DecodeAll(buf[8:], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != size {
+			t.Fatalf("buffer of %d bytes, prefix says %d", len(out), size)
+		}
+		return out
+	}
+
+	for i, field := range fields {
+		name := strings.Fields(names[i])[0]
+		length := int(binary.LittleEndian.Uint64(batch.buf[nodes+16*i:]))
+		nulls := int(binary.LittleEndian.Uint64(batch.buf[nodes+16*i+8:]))
+		if length != n {
+			t.Fatalf("column %s has %d values in a batch of %d", name, length, n)
+		}
+		validity := buffer()
+		valid := func(j int) bool {
+			return len(validity) == 0 || validity[j/8]>>(j%8)&1 == 1
+		}
+		if (len(validity) == 0) != (nulls == 0) {
+			t.Errorf("column %s has %d nulls and a validity bitmap of %d bytes", name, nulls, len(validity))
+		}
+
+		out := make([]interface{}, n)
+		typ := field.table(3)
+		switch field.uint8(2) {
+		case arrowUtf8, arrowBinary:
+			offsets, data := buffer(), buffer()
+			for j := range out {
+				if valid(j) {
+					start := binary.LittleEndian.Uint32(offsets[4*j:])
+					end := binary.LittleEndian.Uint32(offsets[4*j+4:])
+					out[j] = string(data[start:end])
+				}
+			}
+		case arrowBool:
+			data := buffer()
+			for j := range out {
+				if valid(j) {
+					out[j] = data[j/8]>>(j%8)&1 == 1
+				}
+			}
+		case arrowDecimal:
+			data := buffer()
+			for j := range out {
+				if valid(j) {
+					le := data[16*j : 16*j+16]
+					be := make([]byte, 16)
+					for k := range le {
+						be[15-k] = le[k]
+					}
+					out[j] = twosComplement(be).String()
+				}
+			}
+		case arrowInt, arrowDate:
+			data := buffer()
+			wide := field.uint8(2) == arrowInt && typ.int32(0) == 64
+			for j := range out {
+				if !valid(j) {
+					continue
+				}
+				if wide {
+					out[j] = int64(binary.LittleEndian.Uint64(data[8*j:]))
+				} else {
+					out[j] = int32(binary.LittleEndian.Uint32(data[4*j:]))
+				}
+			}
+		case arrowTimestamp:
+			data := buffer()
+			for j := range out {
+				if valid(j) {
+					out[j] = int64(binary.LittleEndian.Uint64(data[8*j:]))
+				}
+			}
+		case arrowFloatingPoint:
+			data := buffer()
+			for j := range out {
+				if !valid(j) {
+					continue
+				}
+				if typ.int16(0) == arrowSingle {
+					out[j] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*j:]))
+				} else {
+					out[j] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*j:]))
+				}
+			}
+		default:
+			t.Fatalf("unexpected type %d", field.uint8(2))
+		}
+		values[name] = append(values[name], out...)
+	}
+	if next != bufferCount {
+		t.Errorf("read %d of %d buffers", next, bufferCount)
+	}
+}
@@ -0,0 +1,361 @@
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/big"
+	"os"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+
+	"sqlparser/pkg/models"
+)
+
+// avroBlockSize is the amount of encoded rows at which a block is written.
+const avroBlockSize = 1 << 20
+
+var avroMagic = []byte("Obj\x01")
+
+// AvroWriter writes the rows of a single table as an Avro object container
+// file. The Avro schema is a record with a field per column; nullable
+// columns are unions with null. Column types come from the schema passed to
+// WriteSchema; without one, every column is a string.
+type AvroWriter struct {
+	out      *bufio.Writer
+	codec    string
+	compress func([]byte) []byte
+	sync     [16]byte
+
+	// SchemaPath, if set, is a file the Avro schema is also written to,
+	// conventionally with the extension .avsc.
+	SchemaPath string
+
+	table   string
+	schema  *models.Schema
+	columns []typedColumn
+	started bool
+	block   []byte
+	rows    int64
+}
+
+// NewAvroWriter creates a writer that compresses blocks with compression:
+// deflate, snappy, zstd or none (the default).
+func NewAvroWriter(output *bufio.Writer, compression string) (*AvroWriter, error) {
+	codec, compress, err := avroCodec(compression)
+	if err != nil {
+		return nil, err
+	}
+	w := &AvroWriter{out: output, codec: codec, compress: compress}
+	if _, err := rand.Read(w.sync[:]); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func avroCodec(compression string) (string, func([]byte) []byte, error) {
+	switch compression {
+	case "", "none":
+		return "null", func(src []byte) []byte { return src }, nil
+	case "deflate":
+		return "deflate", func(src []byte) []byte {
+			var buf bytes.Buffer
+			zw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+			zw.Write(src)
+			zw.Close()
+			return buf.Bytes()
+		}, nil
+	case "snappy":
+		// Each block is followed by the CRC-32 of its uncompressed data
+		return "snappy", func(src []byte) []byte {
+			return binary.BigEndian.AppendUint32(snappy.Encode(nil, src), crc32.ChecksumIEEE(src))
+		}, nil
+	case "zstd":
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return "", nil, err
+		}
+		return "zstandard", func(src []byte) []byte { return enc.EncodeAll(src, nil) }, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported compression for avro: %s (want deflate, snappy, zstd or none)", compression)
+	}
+}
+
+// WriteSchema sets the columns and types of the table. It has to be called
+// before the first rows are written.
+func (w *AvroWriter) WriteSchema(schema *models.Schema) error {
+	w.schema = schema
+	return nil
+}
+
+func (w *AvroWriter) WriteTableStart(tableName string) error {
+	if w.table != "" && w.table != tableName {
+		return fmt.Errorf("an avro file holds a single table, can't add %s to %s: write to a directory instead", tableName, w.table)
+	}
+	w.table = tableName
+	return nil
+}
+
+func (w *AvroWriter) WriteRows(rows []models.Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if !w.started {
+		if w.schema != nil && len(w.schema.Columns) > 0 {
+			w.columns = typedColumns(w.schema)
+		} else {
+			w.columns = columnsFromRow(rows[0])
+		}
+		if err := w.start(); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range rows {
+		for _, col := range w.columns {
+			value, err := col.value(row)
+			if err != nil {
+				return err
+			}
+			if col.nullable {
+				// The index of the branch of the union
+				if value == nil {
+					w.block = binary.AppendVarint(w.block, 0)
+					continue
+				}
+				w.block = binary.AppendVarint(w.block, 1)
+			}
+			w.block = appendAvroValue(w.block, col.typ, value)
+		}
+		w.rows++
+		if len(w.block) >= avroBlockSize {
+			if err := w.flushBlock(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// appendAvroValue appends a value converted to type t in Avro's binary
+// encoding, in which ints and longs are zig-zag varints, as written by
+// binary.AppendVarint.
+func appendAvroValue(dst []byte, t columnType, value interface{}) []byte {
+	if n, ok := value.(int64); ok && t.kind == kindDecimal {
+		value = big.NewInt(n)
+	}
+	switch v := value.(type) {
+	case string:
+		return appendAvroBytes(dst, []byte(v))
+	case []byte:
+		return appendAvroBytes(dst, v)
+	case bool:
+		if v {
+			return append(dst, 1)
+		}
+		return append(dst, 0)
+	case int32:
+		return binary.AppendVarint(dst, int64(v))
+	case int64:
+		return binary.AppendVarint(dst, v)
+	case float32:
+		return binary.LittleEndian.AppendUint32(dst, math.Float32bits(v))
+	case float64:
+		return binary.LittleEndian.AppendUint64(dst, math.Float64bits(v))
+	case *big.Int:
+		return appendAvroDecimal(dst, v)
+	}
+	return dst
+}
+
+func appendAvroBytes(dst, b []byte) []byte {
+	dst = binary.AppendVarint(dst, int64(len(b)))
+	return append(dst, b...)
+}
+
+// appendAvroDecimal appends an unscaled decimal as bytes holding a big-endian
+// two's complement number.
+func appendAvroDecimal(dst []byte, n *big.Int) []byte {
+	size := (n.BitLen() + 8) / 8 // with room for the sign bit
+	dst = binary.AppendVarint(dst, int64(size))
+	return appendTwosComplement(dst, n, size)
+}
+
+func (w *AvroWriter) flushBlock() error {
+	if w.rows == 0 {
+		return nil
+	}
+	data := w.compress(w.block)
+	header := binary.AppendVarint(nil, w.rows)
+	header = binary.AppendVarint(header, int64(len(data)))
+	for _, p := range [][]byte{header, data, w.sync[:]} {
+		if _, err := w.out.Write(p); err != nil {
+			return err
+		}
+	}
+	w.block, w.rows = w.block[:0], 0
+	return nil
+}
+
+// start writes the header of the file, which holds the schema and codec.
+func (w *AvroWriter) start() error {
+	w.started = true
+	record, err := w.avroSchema()
+	if err != nil {
+		return err
+	}
+	schema, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	// The metadata is a map of two entries
+	header := append([]byte(nil), avroMagic...)
+	header = binary.AppendVarint(header, 2)
+	header = appendAvroBytes(header, []byte("avro.schema"))
+	header = appendAvroBytes(header, schema)
+	header = appendAvroBytes(header, []byte("avro.codec"))
+	header = appendAvroBytes(header, []byte(w.codec))
+	header = binary.AppendVarint(header, 0)
+	header = append(header, w.sync[:]...)
+	_, err = w.out.Write(header)
+	return err
+}
+
+// avroRecord is the Avro schema of a table.
+type avroRecord struct {
+	Type   string      `json:"type"`
+	Name   string      `json:"name"`
+	Fields []avroField `json:"fields"`
+}
+
+type avroField struct {
+	Name    string          `json:"name"`
+	Type    interface{}     `json:"type"`
+	Default json.RawMessage `json:"default,omitempty"`
+}
+
+// avroType is a primitive type annotated with a logical type.
+type avroType struct {
+	Type        string `json:"type"`
+	LogicalType string `json:"logicalType"`
+	Precision   int    `json:"precision,omitempty"`
+	Scale       int    `json:"scale,omitempty"`
+}
+
+// avroSchema returns the Avro schema of the columns. Names are made valid
+// Avro names by replacing the characters Avro doesn't allow, which is an
+// error if two columns end up with the same name.
+func (w *AvroWriter) avroSchema() (avroRecord, error) {
+	name := w.table
+	if name == "" {
+		name = "row"
+	}
+	record := avroRecord{Type: "record", Name: avroName(name), Fields: make([]avroField, len(w.columns))}
+	names := make(map[string]string)
+	for i, col := range w.columns {
+		field := avroField{Name: avroName(col.name), Type: avroColumnType(col.typ)}
+		if other, ok := names[field.Name]; ok {
+			return record, fmt.Errorf("columns %s and %s have the same avro name %s", other, col.name, field.Name)
+		}
+		names[field.Name] = col.name
+		if col.nullable {
+			field.Type = []interface{}{"null", field.Type}
+			field.Default = json.RawMessage("null")
+		}
+		record.Fields[i] = field
+	}
+	return record, nil
+}
+
+func avroColumnType(t columnType) interface{} {
+	switch t.kind {
+	case kindBytes:
+		return "bytes"
+	case kindBool:
+		return "boolean"
+	case kindInt32:
+		return "int"
+	case kindInt64:
+		return "long"
+	case kindFloat32:
+		return "float"
+	case kindFloat64:
+		return "double"
+	case kindDecimal:
+		return avroType{Type: "bytes", LogicalType: "decimal", Precision: t.precision, Scale: t.scale}
+	case kindDate:
+		return avroType{Type: "int", LogicalType: "date"}
+	case kindTimestamp:
+		// Without a time zone, as the values are local times
+		return avroType{Type: "long", LogicalType: "local-timestamp-micros"}
+	default:
+		return "string"
+	}
+}
+
+// avroName replaces the characters of s that aren't allowed in Avro names,
+// which are letters, digits and underscores, not starting with a digit.
+func avroName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 || b[0] >= '0' && b[0] <= '9' {
+		b = append([]byte{'_'}, b...)
+	}
+	return string(b)
+}
+
+func (w *AvroWriter) WriteTableEnd() error {
+	if err := w.flushBlock(); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+// Close writes the remaining rows, and the schema to SchemaPath if set. A
+// table without rows gets its columns from the schema alone.
+func (w *AvroWriter) Close() error {
+	if !w.started {
+		if w.schema != nil {
+			w.columns = typedColumns(w.schema)
+		}
+		if err := w.start(); err != nil {
+			return err
+		}
+	}
+	if err := w.flushBlock(); err != nil {
+		return err
+	}
+	if err := w.out.Flush(); err != nil {
+		return err
+	}
+	if w.SchemaPath == "" {
+		return nil
+	}
+	record, err := w.avroSchema()
+	if err != nil {
+		return err
+	}
+	schema, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(w.SchemaPath, append(schema, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing avro schema: %v", err)
+	}
+	return nil
+}
+
+func (w *AvroWriter) Type() models.OutputFormat {
+	return models.FormatAvro
+}
@@ -0,0 +1,296 @@
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+
+	"sqlparser/pkg/models"
+)
+
+// typedAvroSchema is the Avro schema of typedSchema.
+const typedAvroSchema = `{"type":"record","name":"t","fields":[` +
+	`{"name":"id","type":"int"},` +
+	`{"name":"amount","type":["null",{"type":"bytes","logicalType":"decimal","precision":10,"scale":2}],"default":null},` +
+	`{"name":"big","type":["null",{"type":"bytes","logicalType":"decimal","precision":30,"scale":4}],"default":null},` +
+	`{"name":"day","type":["null",{"type":"int","logicalType":"date"}],"default":null},` +
+	`{"name":"at","type":["null",{"type":"long","logicalType":"local-timestamp-micros"}],"default":null},` +
+	`{"name":"flag","type":["null","boolean"],"default":null},` +
+	`{"name":"ratio","type":["null","double"],"default":null},` +
+	`{"name":"name","type":["null","string"],"default":null},` +
+	`{"name":"raw","type":["null","bytes"],"default":null}]}`
+
+func TestAvroWriter(t *testing.T) {
+	for _, tt := range []struct{ compression, codec string }{
+		{"none", "null"},
+		{"deflate", "deflate"},
+		{"snappy", "snappy"},
+		{"zstd", "zstandard"},
+	} {
+		t.Run(tt.compression, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewAvroWriter(bufio.NewWriter(&buf), tt.compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeTable(t, w, typedSchema, typedRows)
+
+			meta, values, blocks := readAvro(t, buf.Bytes())
+			if codec := meta["avro.codec"]; codec != tt.codec {
+				t.Errorf("codec = %q, want %q", codec, tt.codec)
+			}
+			if schema := meta["avro.schema"]; schema != typedAvroSchema {
+				t.Errorf("schema differs:\n got %s\nwant %s", schema, typedAvroSchema)
+			}
+			if blocks != 1 {
+				t.Errorf("got %d blocks, want 1", blocks)
+			}
+			// Decimals are unscaled, as in Arrow
+			if !reflect.DeepEqual(values, typedArrowValues) {
+				t.Errorf("values differ:\n got %v\nwant %v", values, typedArrowValues)
+			}
+		})
+	}
+}
+
+func TestAvroSchema(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewAvroWriter(bufio.NewWriter(&buf), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SchemaPath = filepath.Join(t.TempDir(), "t.avsc")
+	// Without rows, the columns come from the schema alone
+	writeTable(t, w, typedSchema, nil)
+
+	meta, values, blocks := readAvro(t, buf.Bytes())
+	if schema := meta["avro.schema"]; schema != typedAvroSchema {
+		t.Errorf("schema differs:\n got %s\nwant %s", schema, typedAvroSchema)
+	}
+	if blocks != 0 || len(values) != 0 {
+		t.Errorf("got %d blocks, want none", blocks)
+	}
+
+	data, err := os.ReadFile(w.SchemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		t.Fatal(err)
+	}
+	if compact.String() != typedAvroSchema {
+		t.Errorf("%s differs:\n got %s\nwant %s", w.SchemaPath, compact.String(), typedAvroSchema)
+	}
+}
+
+func TestAvroNames(t *testing.T) {
+	tests := []struct {
+		columns []string
+		want    string
+		err     string
+	}{
+		{columns: []string{"a b", "1st", "ü"}, want: "a_b _1st __"},
+		{columns: []string{"a-b", "a_b"}, err: "columns a-b and a_b have the same avro name a_b"},
+	}
+	for _, tt := range tests {
+		schema := &models.Schema{TableName: "my-table"}
+		for _, name := range tt.columns {
+			schema.Columns = append(schema.Columns, models.Column{Name: name, Type: "text"})
+		}
+		w, err := NewAvroWriter(bufio.NewWriter(io.Discard), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.WriteSchema(schema)
+		w.WriteTableStart(schema.TableName)
+		w.columns = typedColumns(schema)
+		record, err := w.avroSchema()
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%q: got error %v, want %s", tt.columns, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tt.columns, err)
+		}
+		var names []string
+		for _, f := range record.Fields {
+			names = append(names, f.Name)
+		}
+		if got := strings.Join(names, " "); got != tt.want || record.Name != "my_table" {
+			t.Errorf("%q: got %s %s, want my_table %s", tt.columns, record.Name, got, tt.want)
+		}
+	}
+}
+
+// readAvro decodes an object container file as written by AvroWriter,
+// returning its metadata, the values of each field and the number of
+// blocks. Decimals are returned as the decimal string of their unscaled
+// value.
+func readAvro(t *testing.T, data []byte) (map[string]string, map[string][]interface{}, int) {
+	t.Helper()
+	if !bytes.HasPrefix(data, avroMagic) {
+		t.Fatal("missing magic")
+	}
+	r := &avroReader{t: t, b: data, pos: len(avroMagic)}
+	meta := make(map[string]string)
+	for {
+		n := r.long()
+		if n == 0 {
+			break
+		}
+		for i := int64(0); i < n; i++ {
+			key := string(r.bytes())
+			meta[key] = string(r.bytes())
+		}
+	}
+	sync := r.b[r.pos : r.pos+16]
+	r.pos += 16
+
+	var record struct {
+		Fields []struct {
+			Name string
+			Type json.RawMessage
+		}
+	}
+	if err := json.Unmarshal([]byte(meta["avro.schema"]), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string][]interface{})
+	blocks := 0
+	for r.pos < len(r.b) {
+		rows := r.long()
+		size := int(r.long())
+		block := decompressAvro(t, meta["avro.codec"], r.b[r.pos:r.pos+size])
+		r.pos += size
+		if !bytes.Equal(r.b[r.pos:r.pos+16], sync) {
+			t.Fatalf("block %d isn't followed by the sync marker", blocks)
+		}
+		r.pos += 16
+
+		br := &avroReader{t: t, b: block}
+		for i := int64(0); i < rows; i++ {
+			for _, f := range record.Fields {
+				values[f.Name] = append(values[f.Name], br.value(f.Type))
+			}
+		}
+		if br.pos != len(block) {
+			t.Fatalf("decoded %d of %d bytes of block %d", br.pos, len(block), blocks)
+		}
+		blocks++
+	}
+	return meta, values, blocks
+}
+
+func decompressAvro(t *testing.T, codec string, data []byte) []byte {
+	t.Helper()
+	var block []byte
+	var err error
+	switch codec {
+	case "null":
+		block = data
+	case "deflate":
+		block, err = io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	case "snappy":
+		block, err = snappy.Decode(nil, data[:len(data)-4])
+		if err == nil && crc32.ChecksumIEEE(block) != binary.BigEndian.Uint32(data[len(data)-4:]) {
+			t.Fatal("snappy block has the wrong checksum")
+		}
+	case "zstandard":
+		var zr *zstd.Decoder
+		if zr, err = zstd.NewReader(nil); err == nil {
+			block, err = zr.DecodeAll(data, nil)
+		}
+	default:
+		t.Fatalf("unknown codec %s", codec)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// avroReader decodes Avro's binary encoding.
+type avroReader struct {
+	t   *testing.T
+	b   []byte
+	pos int
+}
+
+func (r *avroReader) long() int64 {
+	v, n := binary.Varint(r.b[r.pos:])
+	if n <= 0 {
+		r.t.Fatalf("invalid varint at %d", r.pos)
+	}
+	r.pos += n
+	return v
+}
+
+func (r *avroReader) bytes() []byte {
+	n := int(r.long())
+	r.pos += n
+	return r.b[r.pos-n : r.pos]
+}
+
+// value decodes a value of the JSON schema typ.
+func (r *avroReader) value(typ json.RawMessage) interface{} {
+	var union []json.RawMessage
+	if json.Unmarshal(typ, &union) == nil {
+		branch := r.long()
+		if branch < 0 || int(branch) >= len(union) {
+			r.t.Fatalf("union branch %d of %s", branch, typ)
+		}
+		return r.value(union[branch])
+	}
+	var annotated struct {
+		Type        string
+		LogicalType string
+	}
+	if json.Unmarshal(typ, &annotated) == nil {
+		if annotated.LogicalType == "decimal" {
+			return twosComplement(r.bytes()).String()
+		}
+		typ, _ = json.Marshal(annotated.Type)
+	}
+	var name string
+	if err := json.Unmarshal(typ, &name); err != nil {
+		r.t.Fatalf("unexpected type %s", typ)
+	}
+	switch name {
+	case "null":
+		return nil
+	case "boolean":
+		r.pos++
+		return r.b[r.pos-1] == 1
+	case "int":
+		return int32(r.long())
+	case "long":
+		return r.long()
+	case "float":
+		r.pos += 4
+		return math.Float32frombits(binary.LittleEndian.Uint32(r.b[r.pos-4:]))
+	case "double":
+		r.pos += 8
+		return math.Float64frombits(binary.LittleEndian.Uint64(r.b[r.pos-8:]))
+	case "string", "bytes":
+		return string(r.bytes())
+	}
+	r.t.Fatalf("unexpected type %s", name)
+	return nil
+}
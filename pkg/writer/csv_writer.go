@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"encoding/csv"
 	"fmt"
+	"sort"
 
 	"sqlparser/pkg/models"
 )
@@ -13,6 +14,8 @@ type CSVWriter struct {
 	buffer    *bufio.Writer
 	columns   []string
 	tableName string
+	schema    *models.Schema
+	continued string
 }
 
 func NewCSVWriter(output *bufio.Writer) *CSVWriter {
@@ -22,11 +25,47 @@ func NewCSVWriter(output *bufio.Writer) *CSVWriter {
 	}
 }
 
+// WriteSchema sets the order of the columns of the table, which is otherwise
+// alphabetical.
+func (w *CSVWriter) WriteSchema(schema *models.Schema) error {
+	w.schema = schema
+	return nil
+}
+
+func (w *CSVWriter) ContinueTable(tableName string) {
+	w.continued = tableName
+}
+
 func (w *CSVWriter) WriteTableStart(tableName string) error {
 	w.tableName = tableName
+	if w.continued == tableName {
+		// The table line and header are already written
+		return nil
+	}
 	return w.writer.Write([]string{"Table:", tableName})
 }
 
+// headerColumns returns the columns of the schema followed by the other
+// columns of row, sorted.
+func (w *CSVWriter) headerColumns(row map[string]interface{}) []string {
+	var columns []string
+	known := make(map[string]bool)
+	if w.schema != nil {
+		for _, col := range w.schema.Columns {
+			columns = append(columns, col.Name)
+			known[col.Name] = true
+		}
+	}
+	var extra []string
+	for col := range row {
+		if !known[col] {
+			extra = append(extra, col)
+		}
+	}
+	sort.Strings(extra)
+	return append(columns, extra...)
+}
+
 func (w *CSVWriter) WriteRows(rows []models.Row) error {
 	if len(rows) == 0 {
 		return nil
@@ -34,12 +73,11 @@ func (w *CSVWriter) WriteRows(rows []models.Row) error {
 
 	// Write headers if this is the first batch
 	if w.columns == nil {
-		w.columns = make([]string, 0, len(rows[0].Data))
-		for col := range rows[0].Data {
-			w.columns = append(w.columns, col)
-		}
-		if err := w.writer.Write(append([]string{"Row"}, w.columns...)); err != nil {
-			return err
+		w.columns = w.headerColumns(rows[0].Data)
+		if w.continued != w.tableName {
+			if err := w.writer.Write(append([]string{"Row"}, w.columns...)); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -65,6 +103,7 @@ func (w *CSVWriter) WriteRows(rows []models.Row) error {
 
 func (w *CSVWriter) WriteTableEnd() error {
 	w.columns = nil
+	w.continued = ""
 	if err := w.writer.Write([]string{}); err != nil {
 		return err
 	}
@@ -74,6 +113,9 @@ func (w *CSVWriter) WriteTableEnd() error {
 
 func (w *CSVWriter) Close() error {
 	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		return err
+	}
 	return w.buffer.Flush()
 }
 
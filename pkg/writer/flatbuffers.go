@@ -0,0 +1,181 @@
+package writer
+
+import (
+	"encoding/binary"
+)
+
+// flatBuilder builds a FlatBuffer, in which Arrow encodes its IPC metadata.
+// The buffer is built back to front, so objects have to be finished before
+// the tables and vectors that refer to them are started. Objects are
+// referred to by their offset from the end of the buffer.
+type flatBuilder struct {
+	buf      []byte // data written so far is buf[head:]
+	head     int
+	minAlign int
+
+	fields    []uint32 // offsets of the fields of the table being built, 0 if absent
+	objectEnd uint32
+	vectorLen int
+}
+
+func (b *flatBuilder) offset() uint32 {
+	return uint32(len(b.buf) - b.head)
+}
+
+// prep pads the buffer so that a value of size bytes is aligned after
+// additional bytes are written.
+func (b *flatBuilder) prep(size, additional int) {
+	if size > b.minAlign {
+		b.minAlign = size
+	}
+	padding := -(len(b.buf) - b.head + additional) & (size - 1)
+	b.grow(padding + additional + size)
+	for i := 0; i < padding; i++ {
+		b.head--
+		b.buf[b.head] = 0
+	}
+}
+
+func (b *flatBuilder) grow(n int) {
+	if b.head >= n {
+		return
+	}
+	size := 2*len(b.buf) + n
+	buf := make([]byte, size)
+	copy(buf[size-(len(b.buf)-b.head):], b.buf[b.head:])
+	b.head += size - len(b.buf)
+	b.buf = buf
+}
+
+func (b *flatBuilder) putUint8(v uint8) {
+	b.head--
+	b.buf[b.head] = v
+}
+
+func (b *flatBuilder) putUint16(v uint16) {
+	b.head -= 2
+	binary.LittleEndian.PutUint16(b.buf[b.head:], v)
+}
+
+func (b *flatBuilder) putUint32(v uint32) {
+	b.head -= 4
+	binary.LittleEndian.PutUint32(b.buf[b.head:], v)
+}
+
+func (b *flatBuilder) putUint64(v uint64) {
+	b.head -= 8
+	binary.LittleEndian.PutUint64(b.buf[b.head:], v)
+}
+
+// putOffset writes a reference to the object at off.
+func (b *flatBuilder) putOffset(off uint32) {
+	b.prep(4, 0)
+	b.putUint32(b.offset() - off + 4)
+}
+
+// tableStart starts a table of n fields, which are set by the field methods
+// in any order and finished by tableEnd.
+func (b *flatBuilder) tableStart(n int) {
+	b.fields = make([]uint32, n)
+	b.objectEnd = b.offset()
+}
+
+func (b *flatBuilder) fieldUint8(i int, v uint8) {
+	b.prep(1, 0)
+	b.putUint8(v)
+	b.fields[i] = b.offset()
+}
+
+func (b *flatBuilder) fieldBool(i int, v bool) {
+	if v {
+		b.fieldUint8(i, 1)
+	} else {
+		b.fieldUint8(i, 0)
+	}
+}
+
+func (b *flatBuilder) fieldInt16(i int, v int16) {
+	b.prep(2, 0)
+	b.putUint16(uint16(v))
+	b.fields[i] = b.offset()
+}
+
+func (b *flatBuilder) fieldInt32(i int, v int32) {
+	b.prep(4, 0)
+	b.putUint32(uint32(v))
+	b.fields[i] = b.offset()
+}
+
+func (b *flatBuilder) fieldInt64(i int, v int64) {
+	b.prep(8, 0)
+	b.putUint64(uint64(v))
+	b.fields[i] = b.offset()
+}
+
+func (b *flatBuilder) fieldOffset(i int, off uint32) {
+	b.putOffset(off)
+	b.fields[i] = b.offset()
+}
+
+// tableEnd writes the vtable of the table and returns its offset.
+func (b *flatBuilder) tableEnd() uint32 {
+	b.prep(4, 0)
+	b.putUint32(0) // to the vtable, filled in below
+	table := b.offset()
+
+	b.prep(2, 2*(len(b.fields)+2))
+	for i := len(b.fields) - 1; i >= 0; i-- {
+		if b.fields[i] == 0 {
+			b.putUint16(0)
+		} else {
+			b.putUint16(uint16(table - b.fields[i]))
+		}
+	}
+	b.putUint16(uint16(table - b.objectEnd))
+	b.putUint16(uint16(2 * (len(b.fields) + 2)))
+	vtable := b.offset()
+
+	binary.LittleEndian.PutUint32(b.buf[len(b.buf)-int(table):], vtable-table)
+	b.fields = nil
+	return table
+}
+
+// vectorStart starts a vector of n elements of size bytes, aligned to align
+// bytes. The elements are written last to first and the vector is finished
+// by vectorEnd.
+func (b *flatBuilder) vectorStart(size, n, align int) {
+	b.prep(4, size*n)
+	b.prep(align, size*n)
+	b.vectorLen = n
+}
+
+func (b *flatBuilder) vectorEnd() uint32 {
+	b.prep(4, 0)
+	b.putUint32(uint32(b.vectorLen))
+	return b.offset()
+}
+
+// offsets writes a vector of references to objects.
+func (b *flatBuilder) offsets(offs []uint32) uint32 {
+	b.vectorStart(4, len(offs), 4)
+	for i := len(offs) - 1; i >= 0; i-- {
+		b.putOffset(offs[i])
+	}
+	return b.vectorEnd()
+}
+
+func (b *flatBuilder) string(s string) uint32 {
+	b.prep(4, len(s)+1)
+	b.putUint8(0)
+	b.head -= len(s)
+	copy(b.buf[b.head:], s)
+	b.putUint32(uint32(len(s)))
+	return b.offset()
+}
+
+// finish writes the reference to the root table and returns the buffer.
+func (b *flatBuilder) finish(root uint32) []byte {
+	b.prep(b.minAlign, 4)
+	b.putOffset(root)
+	return b.buf[b.head:]
+}
@@ -0,0 +1,205 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestFlatBuilderGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(b *flatBuilder) uint32
+		want  []byte
+	}{
+		{
+			name: "scalar field",
+			build: func(b *flatBuilder) uint32 {
+				b.tableStart(2)
+				b.fieldInt32(1, 7)
+				return b.tableEnd()
+			},
+			want: []byte{
+				0x0c, 0, 0, 0, // root table at 12
+				0x08, 0, 0x08, 0, 0, 0, 0x04, 0, // vtable: size 8, table size 8, field 0 absent, field 1 at 4
+				0x08, 0, 0, 0, // table: vtable 8 bytes before
+				0x07, 0, 0, 0,
+			},
+		},
+		{
+			name: "string field",
+			build: func(b *flatBuilder) uint32 {
+				s := b.string("ab")
+				b.tableStart(1)
+				b.fieldOffset(0, s)
+				return b.tableEnd()
+			},
+			want: []byte{
+				0x0c, 0, 0, 0, // root table at 12
+				0, 0, // padding
+				0x06, 0, 0x08, 0, 0x04, 0, // vtable: size 6, table size 8, field 0 at 4
+				0x06, 0, 0, 0, // table: vtable 6 bytes before
+				0x04, 0, 0, 0, // string 4 bytes on
+				0x02, 0, 0, 0, 'a', 'b', 0, 0,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b flatBuilder
+			if got := b.finish(tt.build(&b)); !bytes.Equal(got, tt.want) {
+				t.Errorf("got % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlatBuilderRoundTrip(t *testing.T) {
+	var b flatBuilder
+	children := make([]uint32, 3)
+	for i := range children {
+		name := b.string(string(rune('a' + i)))
+		b.tableStart(2)
+		b.fieldOffset(0, name)
+		b.fieldInt64(1, -int64(i))
+		children[i] = b.tableEnd()
+	}
+	vector := b.offsets(children)
+	b.vectorStart(16, 2, 8)
+	b.putUint64(4)
+	b.putUint64(3)
+	b.putUint64(2)
+	b.putUint64(1)
+	structs := b.vectorEnd()
+	b.tableStart(8)
+	b.fieldUint8(0, 200)
+	b.fieldBool(1, true)
+	b.fieldInt16(2, -2)
+	b.fieldInt32(3, -3)
+	b.fieldInt64(4, -1<<40)
+	b.fieldOffset(5, vector)
+	b.fieldOffset(6, structs)
+	root := rootTable(b.finish(b.tableEnd()))
+
+	if got := root.uint8(0); got != 200 {
+		t.Errorf("uint8 = %d", got)
+	}
+	if got := root.uint8(1); got != 1 {
+		t.Errorf("bool = %d", got)
+	}
+	if got := root.int16(2); got != -2 {
+		t.Errorf("int16 = %d", got)
+	}
+	if got := root.int32(3); got != -3 {
+		t.Errorf("int32 = %d", got)
+	}
+	if got := root.int64(4); got != -1<<40 {
+		t.Errorf("int64 = %d", got)
+	}
+	if root.has(7) {
+		t.Error("field 7 is set")
+	}
+	for i, child := range root.tables(5) {
+		if name, n := child.string(0), child.int64(1); name != string(rune('a'+i)) || n != -int64(i) {
+			t.Errorf("child %d = %q, %d", i, name, n)
+		}
+	}
+	start, n := root.vector(6)
+	if start%8 != 0 {
+		t.Errorf("struct vector at %d isn't aligned to 8 bytes", start)
+	}
+	for i := 0; i < 2*n; i++ {
+		if v := binary.LittleEndian.Uint64(root.buf[start+8*i:]); v != uint64(i+1) {
+			t.Errorf("struct vector value %d = %d", i, v)
+		}
+	}
+}
+
+// flatTable is a table in a FlatBuffer, for reading what flatBuilder built.
+type flatTable struct {
+	buf []byte
+	pos int
+}
+
+func rootTable(buf []byte) flatTable {
+	return flatTable{buf: buf, pos: int(binary.LittleEndian.Uint32(buf))}
+}
+
+// field returns the position of field i, or 0 if it isn't set.
+func (t flatTable) field(i int) int {
+	vtable := t.pos - int(int32(binary.LittleEndian.Uint32(t.buf[t.pos:])))
+	size := int(binary.LittleEndian.Uint16(t.buf[vtable:]))
+	if 4+2*i >= size {
+		return 0
+	}
+	off := int(binary.LittleEndian.Uint16(t.buf[vtable+4+2*i:]))
+	if off == 0 {
+		return 0
+	}
+	return t.pos + off
+}
+
+func (t flatTable) has(i int) bool { return t.field(i) != 0 }
+
+func (t flatTable) uint8(i int) uint8 {
+	if p := t.field(i); p != 0 {
+		return t.buf[p]
+	}
+	return 0
+}
+
+func (t flatTable) int16(i int) int16 {
+	if p := t.field(i); p != 0 {
+		return int16(binary.LittleEndian.Uint16(t.buf[p:]))
+	}
+	return 0
+}
+
+func (t flatTable) int32(i int) int32 {
+	if p := t.field(i); p != 0 {
+		return int32(binary.LittleEndian.Uint32(t.buf[p:]))
+	}
+	return 0
+}
+
+func (t flatTable) int64(i int) int64 {
+	if p := t.field(i); p != 0 {
+		return int64(binary.LittleEndian.Uint64(t.buf[p:]))
+	}
+	return 0
+}
+
+// deref follows the offset at p.
+func (t flatTable) deref(p int) int {
+	return p + int(binary.LittleEndian.Uint32(t.buf[p:]))
+}
+
+func (t flatTable) table(i int) flatTable {
+	return flatTable{buf: t.buf, pos: t.deref(t.field(i))}
+}
+
+func (t flatTable) string(i int) string {
+	p := t.deref(t.field(i))
+	n := int(binary.LittleEndian.Uint32(t.buf[p:]))
+	return string(t.buf[p+4 : p+4+n])
+}
+
+// vector returns the position of the first element of vector field i and
+// the number of elements.
+func (t flatTable) vector(i int) (start, n int) {
+	p := t.field(i)
+	if p == 0 {
+		return 0, 0
+	}
+	p = t.deref(p)
+	return p + 4, int(binary.LittleEndian.Uint32(t.buf[p:]))
+}
+
+func (t flatTable) tables(i int) []flatTable {
+	start, n := t.vector(i)
+	tables := make([]flatTable, n)
+	for j := range tables {
+		tables[j] = flatTable{buf: t.buf, pos: t.deref(start + 4*j)}
+	}
+	return tables
+}
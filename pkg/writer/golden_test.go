@@ -0,0 +1,80 @@
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestGoldenFiles pins the files written for typedSchema and typedRows to
+// the ones in testdata, so that any change to the output is deliberate. The
+// checked-in files are also decoded, to make sure they hold the rows.
+func TestGoldenFiles(t *testing.T) {
+	tests := []struct {
+		file   string
+		writer func(out *bufio.Writer) (Writer, error)
+		decode func(t *testing.T, data []byte) map[string][]interface{}
+		want   map[string][]interface{}
+	}{
+		{
+			file:   "typed.parquet",
+			writer: func(out *bufio.Writer) (Writer, error) { return NewParquetWriter(out, "none") },
+			decode: func(t *testing.T, data []byte) map[string][]interface{} {
+				_, values := readParquet(t, data)
+				return values
+			},
+			want: typedValues,
+		},
+		{
+			file:   "typed.arrow",
+			writer: func(out *bufio.Writer) (Writer, error) { return NewArrowWriter(out, false, "none") },
+			decode: func(t *testing.T, data []byte) map[string][]interface{} {
+				_, values, _ := readArrow(t, data, false)
+				return values
+			},
+			want: typedArrowValues,
+		},
+		{
+			file:   "typed.arrows",
+			writer: func(out *bufio.Writer) (Writer, error) { return NewArrowWriter(out, true, "none") },
+			decode: func(t *testing.T, data []byte) map[string][]interface{} {
+				_, values, _ := readArrow(t, data, true)
+				return values
+			},
+			want: typedArrowValues,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := tt.writer(bufio.NewWriter(&buf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeTable(t, w, typedSchema, typedRows)
+
+			path := filepath.Join("testdata", tt.file)
+			if *update {
+				if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			golden, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), golden) {
+				t.Errorf("output differs from %s; run with -update if the change is intended", path)
+			}
+			if values := tt.decode(t, golden); !reflect.DeepEqual(values, tt.want) {
+				t.Errorf("%s holds\n%v\nwant\n%v", path, values, tt.want)
+			}
+		})
+	}
+}
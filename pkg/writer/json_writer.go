@@ -80,7 +80,7 @@ func (w *JSONWriter) Close() error {
 			return err
 		}
 	}
-	if _, err := w.writer.Write([]byte("\n")); err != nil {
+	if _, err := w.writer.Write([]byte("]\n")); err != nil {
 		return err
 	}
 	return w.writer.Flush()
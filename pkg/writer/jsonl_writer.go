@@ -24,17 +24,12 @@ func (w *JSONLWriter) WriteRows(rows []models.Row) error {
 		return nil
 	}
 
-	for i, row := range rows {
-		if i > 0 {
-			if _, err := w.writer.Write([]byte("\n")); err != nil {
-				return err
-			}
-		}
+	for _, row := range rows {
 		data, err := json.Marshal(row)
 		if err != nil {
 			return err
 		}
-		if _, err = w.writer.Write(data); err != nil {
+		if _, err = w.writer.Write(append(data, '\n')); err != nil {
 			return err
 		}
 	}
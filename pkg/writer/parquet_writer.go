@@ -0,0 +1,517 @@
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+
+	"sqlparser/pkg/models"
+)
+
+const (
+	// parquetRowGroupSize is the amount of encoded data buffered before it is
+	// written out as a row group.
+	parquetRowGroupSize = 64 << 20
+	// parquetPageSize is the size at which a column's values are cut into a
+	// data page.
+	parquetPageSize = 1 << 20
+)
+
+// Parquet physical types, converted types, codecs and encodings, as numbered
+// in the Parquet format's Thrift definitions.
+const (
+	parquetBoolean           = 0
+	parquetInt32             = 1
+	parquetInt64             = 2
+	parquetFloat             = 4
+	parquetDouble            = 5
+	parquetByteArray         = 6
+	parquetFixedLenByteArray = 7
+
+	parquetUTF8    = 0
+	parquetDecimal = 5
+	parquetDate    = 6
+
+	parquetUncompressed = 0
+	parquetSnappy       = 1
+	parquetGzip         = 2
+	parquetZstd         = 6
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetRequired = 0
+	parquetOptional = 1
+	parquetDataPage = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// ParquetWriter writes the rows of a single table as a Parquet file. Rows
+// are buffered into row groups of about parquetRowGroupSize, with every
+// column PLAIN-encoded. Column types come from the schema passed to
+// WriteSchema; without one, every column is a nullable string. Columns that
+// aren't nullable are stored as required, and the others as optional.
+type ParquetWriter struct {
+	out      *bufio.Writer
+	offset   int64
+	codec    int32
+	compress func([]byte) []byte
+
+	table     string
+	schema    *models.Schema
+	columns   []*parquetColumn
+	rowGroups []parquetRowGroup
+	numRows   int64
+	groupRows int64
+	groupSize int
+	started   bool
+}
+
+// parquetColumn buffers the values of a column for the current row group.
+type parquetColumn struct {
+	typedColumn
+	physical   int32
+	typeLength int32 // of FIXED_LEN_BYTE_ARRAY
+	converted  int32 // -1 for none
+
+	// The page being filled
+	values     []byte
+	bools      []bool
+	defLevels  []byte
+	pageValues int
+
+	// The pages of the current column chunk
+	pages            []byte
+	chunkValues      int64
+	uncompressedSize int64
+}
+
+type parquetRowGroup struct {
+	columns []parquetChunk
+	size    int64
+	rows    int64
+}
+
+type parquetChunk struct {
+	offset           int64
+	values           int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+// NewParquetWriter creates a writer that compresses pages with compression:
+// snappy (the default), zstd, gzip or none.
+func NewParquetWriter(output *bufio.Writer, compression string) (*ParquetWriter, error) {
+	codec, compress, err := parquetCodec(compression)
+	if err != nil {
+		return nil, err
+	}
+	return &ParquetWriter{out: output, codec: codec, compress: compress}, nil
+}
+
+func parquetCodec(compression string) (int32, func([]byte) []byte, error) {
+	switch compression {
+	case "", "snappy":
+		return parquetSnappy, func(src []byte) []byte { return snappy.Encode(nil, src) }, nil
+	case "zstd":
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return 0, nil, err
+		}
+		return parquetZstd, func(src []byte) []byte { return enc.EncodeAll(src, nil) }, nil
+	case "gzip":
+		return parquetGzip, func(src []byte) []byte {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			zw.Write(src)
+			zw.Close()
+			return buf.Bytes()
+		}, nil
+	case "none":
+		return parquetUncompressed, func(src []byte) []byte { return src }, nil
+	default:
+		return 0, nil, fmt.Errorf("unsupported compression for parquet: %s (want snappy, zstd, gzip or none)", compression)
+	}
+}
+
+// WriteSchema sets the columns and types of the table. It has to be called
+// before the first rows are written.
+func (w *ParquetWriter) WriteSchema(schema *models.Schema) error {
+	w.schema = schema
+	return nil
+}
+
+func (w *ParquetWriter) WriteTableStart(tableName string) error {
+	if w.table != "" && w.table != tableName {
+		return fmt.Errorf("a parquet file holds a single table, can't add %s to %s: write to a directory instead", tableName, w.table)
+	}
+	w.table = tableName
+	return nil
+}
+
+func (w *ParquetWriter) WriteRows(rows []models.Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if w.columns == nil {
+		w.setColumns(rows[0])
+	}
+
+	for _, row := range rows {
+		for _, col := range w.columns {
+			value, err := col.value(row)
+			if err != nil {
+				return err
+			}
+			w.groupSize += col.add(value)
+			if len(col.values) >= parquetPageSize || len(col.bools) >= 8*parquetPageSize {
+				col.cutPage(w.codec, w.compress)
+			}
+		}
+		w.groupRows++
+	}
+	if w.groupSize >= parquetRowGroupSize {
+		return w.flushRowGroup()
+	}
+	return nil
+}
+
+func (w *ParquetWriter) setColumns(row models.Row) {
+	var columns []typedColumn
+	if w.schema != nil && len(w.schema.Columns) > 0 {
+		columns = typedColumns(w.schema)
+	} else {
+		columns = columnsFromRow(row)
+	}
+	w.columns = make([]*parquetColumn, len(columns))
+	for i, col := range columns {
+		w.columns[i] = newParquetColumn(col)
+	}
+}
+
+func newParquetColumn(col typedColumn) *parquetColumn {
+	c := &parquetColumn{typedColumn: col, converted: -1}
+	switch col.typ.kind {
+	case kindBool:
+		c.physical = parquetBoolean
+	case kindInt32:
+		c.physical = parquetInt32
+	case kindInt64, kindTimestamp:
+		c.physical = parquetInt64
+	case kindFloat32:
+		c.physical = parquetFloat
+	case kindFloat64:
+		c.physical = parquetDouble
+	case kindDate:
+		c.physical, c.converted = parquetInt32, parquetDate
+	case kindDecimal:
+		c.converted = parquetDecimal
+		if col.typ.precision <= 18 {
+			c.physical = parquetInt64
+		} else {
+			c.physical, c.typeLength = parquetFixedLenByteArray, int32(decimalBytes(col.typ.precision))
+		}
+	case kindBytes:
+		c.physical = parquetByteArray
+	default:
+		c.physical, c.converted = parquetByteArray, parquetUTF8
+	}
+	return c
+}
+
+// decimalBytes returns the number of bytes needed to hold decimals of
+// precision digits in two's complement.
+func decimalBytes(precision int) int {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil)
+	return (max.BitLen() + 1 + 7) / 8
+}
+
+// add appends a converted value to the page, returning the number of bytes
+// it takes up. Only optional columns have definition levels.
+func (c *parquetColumn) add(value interface{}) int {
+	c.pageValues++
+	if value == nil {
+		c.defLevels = append(c.defLevels, 0)
+		return 1
+	}
+	if c.nullable {
+		c.defLevels = append(c.defLevels, 1)
+	}
+	n := len(c.values)
+	switch v := value.(type) {
+	case bool:
+		c.bools = append(c.bools, v)
+		return 1
+	case int32:
+		c.values = binary.LittleEndian.AppendUint32(c.values, uint32(v))
+	case int64:
+		c.values = binary.LittleEndian.AppendUint64(c.values, uint64(v))
+	case float32:
+		c.values = binary.LittleEndian.AppendUint32(c.values, math.Float32bits(v))
+	case float64:
+		c.values = binary.LittleEndian.AppendUint64(c.values, math.Float64bits(v))
+	case *big.Int:
+		c.values = appendTwosComplement(c.values, v, int(c.typeLength))
+	case string:
+		c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(v)))
+		c.values = append(c.values, v...)
+	case []byte:
+		c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(v)))
+		c.values = append(c.values, v...)
+	}
+	return len(c.values) - n + 1
+}
+
+// appendTwosComplement appends n as a big-endian two's complement number of
+// size bytes.
+func appendTwosComplement(dst []byte, n *big.Int, size int) []byte {
+	if n.Sign() < 0 {
+		n = new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), uint(8*size)), n)
+	}
+	b := n.Bytes()
+	for i := len(b); i < size; i++ {
+		dst = append(dst, 0)
+	}
+	return append(dst, b...)
+}
+
+// cutPage encodes the buffered values as a data page of the column chunk.
+func (c *parquetColumn) cutPage(codec int32, compress func([]byte) []byte) {
+	if c.pageValues == 0 {
+		return
+	}
+
+	var page []byte
+	if c.nullable {
+		levels := encodeLevels(c.defLevels)
+		page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+		page = append(page, levels...)
+	}
+	if c.physical == parquetBoolean {
+		page = appendBitPacked(page, c.bools)
+	} else {
+		page = append(page, c.values...)
+	}
+	compressed := compress(page)
+
+	var h thriftEncoder
+	h.structBegin()
+	h.i32(1, parquetDataPage)
+	h.i32(2, int32(len(page)))
+	h.i32(3, int32(len(compressed)))
+	h.structField(5)
+	h.i32(1, int32(c.pageValues))
+	h.i32(2, parquetPlain)
+	h.i32(3, parquetRLE)
+	h.i32(4, parquetRLE)
+	h.structEnd()
+	h.structEnd()
+
+	c.pages = append(c.pages, h.buf...)
+	c.pages = append(c.pages, compressed...)
+	c.uncompressedSize += int64(len(h.buf) + len(page))
+	c.chunkValues += int64(c.pageValues)
+
+	c.values, c.bools, c.defLevels = c.values[:0], c.bools[:0], c.defLevels[:0]
+	c.pageValues = 0
+}
+
+// encodeLevels encodes definition levels of bit width 1 as runs of the
+// RLE/bit-packing hybrid encoding.
+func encodeLevels(levels []byte) []byte {
+	var out []byte
+	for i := 0; i < len(levels); {
+		j := i + 1
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		out = append(out, levels[i])
+		i = j
+	}
+	return out
+}
+
+// appendBitPacked appends booleans packed into bits, least significant first.
+func appendBitPacked(dst []byte, values []bool) []byte {
+	for i := 0; i < len(values); i += 8 {
+		var b byte
+		for j := 0; j < 8 && i+j < len(values); j++ {
+			if values[i+j] {
+				b |= 1 << j
+			}
+		}
+		dst = append(dst, b)
+	}
+	return dst
+}
+
+// flushRowGroup writes the buffered rows as a row group.
+func (w *ParquetWriter) flushRowGroup() error {
+	if w.groupRows == 0 {
+		return nil
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	group := parquetRowGroup{rows: w.groupRows}
+	for _, col := range w.columns {
+		col.cutPage(w.codec, w.compress)
+		chunk := parquetChunk{
+			offset:           w.offset,
+			values:           col.chunkValues,
+			uncompressedSize: col.uncompressedSize,
+			compressedSize:   int64(len(col.pages)),
+		}
+		if err := w.write(col.pages); err != nil {
+			return err
+		}
+		group.columns = append(group.columns, chunk)
+		group.size += chunk.uncompressedSize
+		col.pages, col.chunkValues, col.uncompressedSize = col.pages[:0], 0, 0
+	}
+	w.rowGroups = append(w.rowGroups, group)
+	w.numRows += w.groupRows
+	w.groupRows, w.groupSize = 0, 0
+	return w.out.Flush()
+}
+
+func (w *ParquetWriter) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	return w.write(parquetMagic)
+}
+
+func (w *ParquetWriter) write(p []byte) error {
+	n, err := w.out.Write(p)
+	w.offset += int64(n)
+	return err
+}
+
+func (w *ParquetWriter) WriteTableEnd() error {
+	return w.flushRowGroup()
+}
+
+// Close writes the remaining rows and the file metadata. A table without rows
+// gets its columns from the schema alone.
+func (w *ParquetWriter) Close() error {
+	if w.columns == nil && w.schema != nil {
+		w.setColumns(models.Row{})
+	}
+	if err := w.flushRowGroup(); err != nil {
+		return err
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+	footer := w.footer()
+	if err := w.write(footer); err != nil {
+		return err
+	}
+	if err := w.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer)))); err != nil {
+		return err
+	}
+	if err := w.write(parquetMagic); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+// footer encodes the FileMetaData of the file.
+func (w *ParquetWriter) footer() []byte {
+	var e thriftEncoder
+	e.structBegin()
+	e.i32(1, 1)
+
+	e.list(2, thriftStruct, len(w.columns)+1)
+	e.structBegin()
+	name := w.table
+	if name == "" {
+		name = "schema"
+	}
+	e.string(4, name)
+	e.i32(5, int32(len(w.columns)))
+	e.structEnd()
+	for _, col := range w.columns {
+		e.structBegin()
+		e.i32(1, col.physical)
+		if col.typeLength > 0 {
+			e.i32(2, col.typeLength)
+		}
+		if col.nullable {
+			e.i32(3, parquetOptional)
+		} else {
+			e.i32(3, parquetRequired)
+		}
+		e.string(4, col.name)
+		if col.converted >= 0 {
+			e.i32(6, col.converted)
+		}
+		if col.typ.kind == kindDecimal {
+			e.i32(7, int32(col.typ.scale))
+			e.i32(8, int32(col.typ.precision))
+		}
+		if col.typ.kind == kindTimestamp {
+			// TIMESTAMP(isAdjustedToUTC=false, unit=MICROS)
+			e.structField(10)
+			e.structField(8)
+			e.bool(1, false)
+			e.structField(2)
+			e.structField(2)
+			e.structEnd()
+			e.structEnd()
+			e.structEnd()
+			e.structEnd()
+		}
+		e.structEnd()
+	}
+
+	e.i64(3, w.numRows)
+
+	e.list(4, thriftStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		e.structBegin()
+		e.list(1, thriftStruct, len(group.columns))
+		for i, chunk := range group.columns {
+			col := w.columns[i]
+			e.structBegin()
+			e.i64(2, chunk.offset)
+			e.structField(3)
+			e.i32(1, col.physical)
+			e.list(2, thriftI32, 2)
+			e.listI32(parquetPlain)
+			e.listI32(parquetRLE)
+			e.list(3, thriftBinary, 1)
+			e.bytes([]byte(col.name))
+			e.i32(4, w.codec)
+			e.i64(5, chunk.values)
+			e.i64(6, chunk.uncompressedSize)
+			e.i64(7, chunk.compressedSize)
+			e.i64(9, chunk.offset)
+			e.structEnd()
+			e.structEnd()
+		}
+		e.i64(2, group.size)
+		e.i64(3, group.rows)
+		e.structEnd()
+	}
+
+	e.string(6, "sqlparser")
+	e.structEnd()
+	return e.buf
+}
+
+func (w *ParquetWriter) Type() models.OutputFormat {
+	return models.FormatParquet
+}
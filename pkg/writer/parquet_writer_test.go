@@ -0,0 +1,381 @@
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+
+	"sqlparser/pkg/models"
+)
+
+// typedSchema has a column of every type kind the typed writers support.
+var typedSchema = &models.Schema{
+	TableName: "t",
+	Columns: []models.Column{
+		{Name: "id", Type: "int(11)"},
+		{Name: "amount", Type: "decimal(10,2)", Nullable: true},
+		{Name: "big", Type: "decimal(30,4)", Nullable: true},
+		{Name: "day", Type: "date", Nullable: true},
+		{Name: "at", Type: "datetime", Nullable: true},
+		{Name: "flag", Type: "boolean", Nullable: true},
+		{Name: "ratio", Type: "double", Nullable: true},
+		{Name: "name", Type: "varchar(10)", Nullable: true},
+		{Name: "raw", Type: "blob", Nullable: true},
+	},
+}
+
+var typedRows = []models.Row{
+	{RowNumber: 1, Data: map[string]interface{}{
+		"id": "1", "amount": "12.34", "big": "-1.5", "day": "2024-01-02", "at": "2024-01-02 03:04:05.000006",
+		"flag": "1", "ratio": "0.5", "name": "a", "raw": "x",
+	}},
+	{RowNumber: 2, Data: map[string]interface{}{
+		"id": "2", "amount": "-0.01", "big": "-12345678901234567890.1234", "day": "0000-00-00", "at": nil,
+		"flag": "0", "ratio": nil, "name": nil, "raw": "",
+	}},
+	{RowNumber: 3, Data: map[string]interface{}{
+		"id": "3", "amount": nil, "big": nil, "day": nil, "at": "1969-12-31 23:59:59",
+		"flag": nil, "ratio": "-2", "name": "ü", "raw": nil,
+	}},
+}
+
+// typedValues are typedRows as the typed writers store them.
+var typedValues = map[string][]interface{}{
+	"id":     {int32(1), int32(2), int32(3)},
+	"amount": {int64(1234), int64(-1), nil},
+	"big":    {"-15000", "-123456789012345678901234", nil},
+	"day":    {int32(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC).Unix() / 86400), nil, nil},
+	"at":     {time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC).UnixMicro(), nil, int64(-1000000)},
+	"flag":   {true, false, nil},
+	"ratio":  {0.5, nil, -2.0},
+	"name":   {"a", nil, "ü"},
+	"raw":    {"x", "", nil},
+}
+
+func TestParquetWriter(t *testing.T) {
+	for _, compression := range []string{"none", "snappy", "gzip", "zstd"} {
+		t.Run(compression, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewParquetWriter(bufio.NewWriter(&buf), compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeTable(t, w, typedSchema, typedRows)
+
+			meta, values := readParquet(t, buf.Bytes())
+			if rows := meta[3]; rows != int64(len(typedRows)) {
+				t.Errorf("num_rows = %v, want %d", rows, len(typedRows))
+			}
+			if !reflect.DeepEqual(values, typedValues) {
+				t.Errorf("values differ:\n got %v\nwant %v", values, typedValues)
+			}
+		})
+	}
+}
+
+func TestParquetSchema(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewParquetWriter(bufio.NewWriter(&buf), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without rows, the columns come from the schema alone
+	writeTable(t, w, typedSchema, nil)
+	meta, _ := readParquet(t, buf.Bytes())
+
+	tests := []struct {
+		column string
+		want   string
+	}{
+		{"id", "map[1:1 3:0 4:id]"},
+		{"amount", "map[1:2 3:1 4:amount 6:5 7:2 8:10]"},
+		{"big", "map[1:7 2:13 3:1 4:big 6:5 7:4 8:30]"},
+		{"day", "map[1:1 3:1 4:day 6:6]"},
+		{"at", "map[1:2 3:1 4:at 10:map[8:map[1:false 2:map[2:map[]]]]]"},
+		{"flag", "map[1:0 3:1 4:flag]"},
+		{"ratio", "map[1:5 3:1 4:ratio]"},
+		{"name", "map[1:6 3:1 4:name 6:0]"},
+		{"raw", "map[1:6 3:1 4:raw]"},
+	}
+	elements := meta[2].([]interface{})
+	if len(elements) != len(tests)+1 {
+		t.Fatalf("got %d schema elements, want %d", len(elements), len(tests)+1)
+	}
+	if root := fmt.Sprint(elements[0]); root != "map[4:t 5:9]" {
+		t.Errorf("root = %s", root)
+	}
+	for i, tt := range tests {
+		if got := fmt.Sprint(elements[i+1]); got != tt.want {
+			t.Errorf("column %s = %s, want %s", tt.column, got, tt.want)
+		}
+	}
+	if rows := meta[3]; rows != int64(0) {
+		t.Errorf("num_rows = %v, want 0", rows)
+	}
+}
+
+// TestParquetGolden checks a minimal file against bytes decoded by hand
+// following parquet.thrift and the Thrift compact protocol.
+func TestParquetGolden(t *testing.T) {
+	schema := &models.Schema{TableName: "t", Columns: []models.Column{
+		{Name: "id", Type: "int(11)"},
+		{Name: "s", Type: "varchar(5)", Nullable: true},
+	}}
+	rows := []models.Row{
+		{RowNumber: 1, Data: map[string]interface{}{"id": "1", "s": "a"}},
+		{RowNumber: 2, Data: map[string]interface{}{"id": "2", "s": nil}},
+	}
+	want := []byte{
+		'P', 'A', 'R', '1',
+		// PageHeader of id: DATA_PAGE, 8 bytes uncompressed and compressed,
+		// DataPageHeader: 2 values, PLAIN, RLE levels
+		0x15, 0x00, 0x15, 0x10, 0x15, 0x10, 0x2c, 0x15, 0x04, 0x15, 0x00, 0x15, 0x06, 0x15, 0x06, 0x00, 0x00,
+		// required, so no definition levels
+		0x01, 0, 0, 0, 0x02, 0, 0, 0,
+		// PageHeader of s: 13 bytes
+		0x15, 0x00, 0x15, 0x1a, 0x15, 0x1a, 0x2c, 0x15, 0x04, 0x15, 0x00, 0x15, 0x06, 0x15, 0x06, 0x00, 0x00,
+		// 4 bytes of definition levels: a run of one 1, a run of one 0
+		0x04, 0, 0, 0, 0x02, 0x01, 0x02, 0x00,
+		0x01, 0, 0, 0, 'a',
+		// FileMetaData: version 1
+		0x15, 0x02,
+		// schema: 3 elements, the root t with 2 children, id INT32 REQUIRED,
+		// s BYTE_ARRAY OPTIONAL UTF8
+		0x19, 0x3c,
+		0x48, 0x01, 't', 0x15, 0x04, 0x00,
+		0x15, 0x02, 0x25, 0x00, 0x18, 0x02, 'i', 'd', 0x00,
+		0x15, 0x0c, 0x25, 0x02, 0x18, 0x01, 's', 0x25, 0x00, 0x00,
+		// num_rows 2
+		0x16, 0x04,
+		// row_groups: 1 RowGroup with 2 ColumnChunks
+		0x19, 0x1c, 0x19, 0x2c,
+		// id at 4: INT32, PLAIN and RLE, path id, UNCOMPRESSED, 2 values,
+		// 25 bytes uncompressed and compressed, data page at 4
+		0x26, 0x08, 0x1c, 0x15, 0x02, 0x19, 0x25, 0x00, 0x06, 0x19, 0x18, 0x02, 'i', 'd',
+		0x15, 0x00, 0x16, 0x04, 0x16, 0x32, 0x16, 0x32, 0x26, 0x08, 0x00, 0x00,
+		// s at 29, 30 bytes
+		0x26, 0x3a, 0x1c, 0x15, 0x0c, 0x19, 0x25, 0x00, 0x06, 0x19, 0x18, 0x01, 's',
+		0x15, 0x00, 0x16, 0x04, 0x16, 0x3c, 0x16, 0x3c, 0x26, 0x3a, 0x00, 0x00,
+		// total_byte_size 55, num_rows 2
+		0x16, 0x6e, 0x16, 0x04, 0x00,
+		// created_by
+		0x28, 0x09, 's', 'q', 'l', 'p', 'a', 'r', 's', 'e', 'r',
+		0x00,
+		// footer length 103
+		0x67, 0, 0, 0,
+		'P', 'A', 'R', '1',
+	}
+
+	var buf bytes.Buffer
+	w, err := NewParquetWriter(bufio.NewWriter(&buf), "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTable(t, w, schema, rows)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got\n% x\nwant\n% x", buf.Bytes(), want)
+	}
+}
+
+// TestParquetNullInRequiredColumn checks that NOT NULL columns reject nulls
+// rather than writing a file readers would fail on.
+func TestParquetNullInRequiredColumn(t *testing.T) {
+	w, err := NewParquetWriter(bufio.NewWriter(io.Discard), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteSchema(typedSchema); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteTableStart("t"); err != nil {
+		t.Fatal(err)
+	}
+	err = w.WriteRows([]models.Row{{RowNumber: 4, Data: map[string]interface{}{"id": nil}}})
+	if err == nil || err.Error() != "row 4, column id: NULL in a NOT NULL column" {
+		t.Errorf("got error %v", err)
+	}
+}
+
+// writeTable writes a table with a SchemaWriter and closes it.
+func writeTable(t *testing.T, w Writer, schema *models.Schema, rows []models.Row) {
+	t.Helper()
+	if err := w.(SchemaWriter).WriteSchema(schema); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteTableStart(schema.TableName); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRows(rows); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteTableEnd(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readParquet decodes a Parquet file as written by ParquetWriter, returning
+// its FileMetaData and the values of each column. Fixed-length decimals are
+// returned as the decimal string of their unscaled value.
+func readParquet(t *testing.T, data []byte) (thriftFields, map[string][]interface{}) {
+	t.Helper()
+	if !bytes.HasPrefix(data, parquetMagic) || !bytes.HasSuffix(data, parquetMagic) {
+		t.Fatal("missing magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{b: data[len(data)-8-footerLen : len(data)-8]}
+	meta := footer.readStruct()
+	if footer.pos != footerLen {
+		t.Fatalf("footer is %d bytes, read %d", footerLen, footer.pos)
+	}
+
+	elements := meta[2].([]interface{})[1:]
+	values := make(map[string][]interface{})
+	groups, _ := meta[4].([]interface{})
+	for _, g := range groups {
+		for i, c := range g.(thriftFields)[1].([]interface{}) {
+			element := elements[i].(thriftFields)
+			name := element[4].(string)
+			chunk := c.(thriftFields)[3].(thriftFields)
+			offset, size := chunk[9].(int64), chunk[7].(int64)
+			pages := &thriftReader{b: data[offset : offset+size]}
+			for pages.pos < len(pages.b) {
+				header := pages.readStruct()
+				compressed := pages.b[pages.pos : pages.pos+int(header[3].(int64))]
+				pages.pos += len(compressed)
+				page := decompressParquet(t, chunk[4].(int64), compressed)
+				if len(page) != int(header[2].(int64)) {
+					t.Fatalf("page of %s is %d bytes, header says %d", name, len(page), header[2])
+				}
+				n := int(header[5].(thriftFields)[1].(int64))
+				values[name] = append(values[name], decodeParquetPage(t, element, page, n)...)
+			}
+		}
+	}
+	return meta, values
+}
+
+func decompressParquet(t *testing.T, codec int64, data []byte) []byte {
+	t.Helper()
+	var page []byte
+	var err error
+	switch codec {
+	case parquetUncompressed:
+		page = data
+	case parquetSnappy:
+		page, err = snappy.Decode(nil, data)
+	case parquetGzip:
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			page, err = io.ReadAll(zr)
+		}
+	case parquetZstd:
+		var zr *zstd.Decoder
+		if zr, err = zstd.NewReader(nil); err == nil {
+			page, err = zr.DecodeAll(data, nil)
+		}
+	default:
+		t.Fatalf("unknown codec %d", codec)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+// decodeParquetPage decodes a PLAIN data page of n values, with RLE
+// definition levels if the column is optional.
+func decodeParquetPage(t *testing.T, element thriftFields, page []byte, n int) []interface{} {
+	t.Helper()
+	if element[3].(int64) == parquetRequired {
+		defined := make([]bool, n)
+		for i := range defined {
+			defined[i] = true
+		}
+		return decodeParquetValues(t, element, page, defined)
+	}
+
+	levelsLen := int(binary.LittleEndian.Uint32(page))
+	levels := &thriftReader{b: page[4 : 4+levelsLen]}
+	var defined []bool
+	for levels.pos < len(levels.b) {
+		run := levels.uvarint()
+		if run&1 != 0 {
+			t.Fatal("bit-packed definition levels")
+		}
+		level := levels.byte()
+		for i := uint64(0); i < run>>1; i++ {
+			defined = append(defined, level == 1)
+		}
+	}
+	if len(defined) != n {
+		t.Fatalf("%d definition levels for %d values", len(defined), n)
+	}
+	return decodeParquetValues(t, element, page[4+levelsLen:], defined)
+}
+
+// decodeParquetValues decodes the PLAIN values of a page for the defined
+// entries.
+func decodeParquetValues(t *testing.T, element thriftFields, data []byte, defined []bool) []interface{} {
+	t.Helper()
+	pos, bit := 0, 0
+	out := make([]interface{}, len(defined))
+	for i := range out {
+		if !defined[i] {
+			continue
+		}
+		switch element[1].(int64) {
+		case parquetBoolean:
+			out[i] = data[bit/8]>>(bit%8)&1 == 1
+			bit++
+		case parquetInt32:
+			out[i] = int32(binary.LittleEndian.Uint32(data[pos:]))
+			pos += 4
+		case parquetInt64:
+			out[i] = int64(binary.LittleEndian.Uint64(data[pos:]))
+			pos += 8
+		case parquetDouble:
+			out[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[pos:]))
+			pos += 8
+		case parquetByteArray:
+			size := int(binary.LittleEndian.Uint32(data[pos:]))
+			out[i] = string(data[pos+4 : pos+4+size])
+			pos += 4 + size
+		case parquetFixedLenByteArray:
+			size := int(element[2].(int64))
+			out[i] = twosComplement(data[pos : pos+size]).String()
+			pos += size
+		default:
+			t.Fatalf("unexpected physical type %d", element[1])
+		}
+	}
+	if bit > 0 {
+		pos = (bit + 7) / 8
+	}
+	if pos != len(data) {
+		t.Fatalf("decoded %d of %d value bytes", pos, len(data))
+	}
+	return out
+}
+
+// twosComplement decodes a big-endian two's complement number.
+func twosComplement(b []byte) *big.Int {
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+	return n
+}
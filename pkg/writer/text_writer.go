@@ -8,14 +8,22 @@ import (
 )
 
 type TextWriter struct {
-	writer *bufio.Writer
+	writer    *bufio.Writer
+	continued string
 }
 
 func NewTextWriter(output *bufio.Writer) *TextWriter {
 	return &TextWriter{writer: output}
 }
 
+func (w *TextWriter) ContinueTable(tableName string) {
+	w.continued = tableName
+}
+
 func (w *TextWriter) WriteTableStart(tableName string) error {
+	if w.continued == tableName {
+		return nil
+	}
 	if _, err := fmt.Fprintf(w.writer, "\nTable: %s\n", tableName); err != nil {
 		return err
 	}
@@ -43,6 +51,7 @@ func (w *TextWriter) WriteRows(rows []models.Row) error {
 }
 
 func (w *TextWriter) WriteTableEnd() error {
+	w.continued = ""
 	if _, err := w.writer.WriteString("\n"); err != nil {
 		return err
 	}
@@ -0,0 +1,102 @@
+package writer
+
+import (
+	"encoding/binary"
+)
+
+// Types of the Thrift compact protocol, in which Parquet encodes its page
+// headers and file metadata.
+const (
+	thriftBoolTrue  = 1
+	thriftBoolFalse = 2
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftStruct    = 12
+)
+
+// thriftEncoder writes Thrift structs in the compact protocol. Fields have
+// to be written in increasing order of their ids within each struct.
+type thriftEncoder struct {
+	buf     []byte
+	lastIDs []int16 // id of the last field written, per open struct
+	lastID  int16
+}
+
+func (e *thriftEncoder) field(id int16, typ byte) {
+	if delta := id - e.lastID; delta > 0 && delta <= 15 {
+		e.buf = append(e.buf, byte(delta)<<4|typ)
+	} else {
+		e.buf = append(e.buf, typ)
+		e.buf = binary.AppendVarint(e.buf, int64(id))
+	}
+	e.lastID = id
+}
+
+func (e *thriftEncoder) i32(id int16, v int32) {
+	e.field(id, thriftI32)
+	e.buf = binary.AppendVarint(e.buf, int64(v))
+}
+
+func (e *thriftEncoder) i64(id int16, v int64) {
+	e.field(id, thriftI64)
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *thriftEncoder) bool(id int16, v bool) {
+	if v {
+		e.field(id, thriftBoolTrue)
+	} else {
+		e.field(id, thriftBoolFalse)
+	}
+}
+
+func (e *thriftEncoder) binary(id int16, v []byte) {
+	e.field(id, thriftBinary)
+	e.bytes(v)
+}
+
+func (e *thriftEncoder) string(id int16, v string) {
+	e.binary(id, []byte(v))
+}
+
+func (e *thriftEncoder) bytes(v []byte) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// list starts a list field of n elements of type elem, which are written
+// next without field headers.
+func (e *thriftEncoder) list(id int16, elem byte, n int) {
+	e.field(id, thriftList)
+	if n < 15 {
+		e.buf = append(e.buf, byte(n)<<4|elem)
+	} else {
+		e.buf = append(e.buf, 0xf0|elem)
+		e.buf = binary.AppendUvarint(e.buf, uint64(n))
+	}
+}
+
+// listI32 writes a list element of type i32.
+func (e *thriftEncoder) listI32(v int32) {
+	e.buf = binary.AppendVarint(e.buf, int64(v))
+}
+
+// structField starts a struct field; structBegin starts a struct that is a
+// list element. Both are ended by structEnd.
+func (e *thriftEncoder) structField(id int16) {
+	e.field(id, thriftStruct)
+	e.structBegin()
+}
+
+func (e *thriftEncoder) structBegin() {
+	e.lastIDs = append(e.lastIDs, e.lastID)
+	e.lastID = 0
+}
+
+func (e *thriftEncoder) structEnd() {
+	e.buf = append(e.buf, 0)
+	e.lastID = e.lastIDs[len(e.lastIDs)-1]
+	e.lastIDs = e.lastIDs[:len(e.lastIDs)-1]
+}
@@ -0,0 +1,192 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+func TestThriftEncoder(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(e *thriftEncoder)
+		want  []byte
+	}{
+		{
+			name:  "i32 with short delta",
+			write: func(e *thriftEncoder) { e.i32(1, 1) },
+			want:  []byte{0x15, 0x02},
+		},
+		{
+			name:  "negative i32",
+			write: func(e *thriftEncoder) { e.i32(1, -3) },
+			want:  []byte{0x15, 0x05},
+		},
+		{
+			name:  "i64 with long delta",
+			write: func(e *thriftEncoder) { e.i64(20, -1) },
+			want:  []byte{0x06, 0x28, 0x01},
+		},
+		{
+			name: "bools",
+			write: func(e *thriftEncoder) {
+				e.bool(1, true)
+				e.bool(2, false)
+			},
+			want: []byte{0x11, 0x12},
+		},
+		{
+			name:  "string",
+			write: func(e *thriftEncoder) { e.string(3, "ab") },
+			want:  []byte{0x38, 0x02, 'a', 'b'},
+		},
+		{
+			name: "short list",
+			write: func(e *thriftEncoder) {
+				e.list(1, thriftI32, 2)
+				e.listI32(0)
+				e.listI32(3)
+			},
+			want: []byte{0x19, 0x25, 0x00, 0x06},
+		},
+		{
+			name: "long list",
+			write: func(e *thriftEncoder) {
+				e.list(1, thriftI32, 15)
+				for i := 0; i < 15; i++ {
+					e.listI32(1)
+				}
+			},
+			want: append([]byte{0x19, 0xf5, 0x0f}, bytes.Repeat([]byte{0x02}, 15)...),
+		},
+		{
+			name: "nested struct restores field ids",
+			write: func(e *thriftEncoder) {
+				e.i32(1, 1)
+				e.structField(2)
+				e.i32(1, 2)
+				e.structEnd()
+				e.i32(3, 3)
+			},
+			want: []byte{0x15, 0x02, 0x1c, 0x15, 0x04, 0x00, 0x15, 0x06},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e thriftEncoder
+			tt.write(&e)
+			if !bytes.Equal(e.buf, tt.want) {
+				t.Errorf("got % x, want % x", e.buf, tt.want)
+			}
+		})
+	}
+}
+
+func TestThriftRoundTrip(t *testing.T) {
+	var e thriftEncoder
+	e.structBegin()
+	e.i32(1, -7)
+	e.string(2, "name")
+	e.list(3, thriftStruct, 2)
+	for i := int64(0); i < 2; i++ {
+		e.structBegin()
+		e.i64(1, 1<<40+i)
+		e.bool(2, i == 0)
+		e.structEnd()
+	}
+	e.structField(40)
+	e.structEnd()
+	e.structEnd()
+
+	r := &thriftReader{b: e.buf}
+	got := r.readStruct()
+	if r.pos != len(e.buf) {
+		t.Fatalf("read %d of %d bytes", r.pos, len(e.buf))
+	}
+	want := "map[1:-7 2:name 3:[map[1:1099511627776 2:true] map[1:1099511627777 2:false]] 40:map[]]"
+	if s := fmt.Sprint(got); s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+}
+
+// thriftFields is a decoded Thrift struct: field values by id. Integers are
+// int64, binaries string, lists []interface{} and structs thriftFields.
+type thriftFields map[int16]interface{}
+
+// thriftReader decodes the Thrift compact protocol, for checking what the
+// encoder wrote.
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) byte() byte {
+	c := r.b[r.pos]
+	r.pos++
+	return c
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		panic("invalid varint")
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	v, n := binary.Varint(r.b[r.pos:])
+	if n <= 0 {
+		panic("invalid varint")
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftBoolTrue:
+		return true
+	case thriftBoolFalse:
+		return false
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := int(r.uvarint())
+		r.pos += n
+		return string(r.b[r.pos-n : r.pos])
+	case thriftList:
+		h := r.byte()
+		n, elem := int(h>>4), h&0x0f
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = r.value(elem)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("unknown thrift type %d", typ))
+}
+
+func (r *thriftReader) readStruct() thriftFields {
+	s := thriftFields{}
+	var id int16
+	for {
+		h := r.byte()
+		if h == 0 {
+			return s
+		}
+		if delta := int16(h >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.varint())
+		}
+		s[id] = r.value(h & 0x0f)
+	}
+}
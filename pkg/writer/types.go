@@ -0,0 +1,297 @@
+package writer
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sqlparser/pkg/models"
+)
+
+// typeKind is the kind of values a column holds in the typed output formats.
+type typeKind int
+
+const (
+	kindString typeKind = iota
+	kindBytes
+	kindBool
+	kindInt32
+	kindInt64
+	kindFloat32
+	kindFloat64
+	kindDecimal   // int64 unscaled values up to precision 18, *big.Int above
+	kindDate      // int32 days since 1970-01-01
+	kindTimestamp // int64 microseconds since 1970-01-01 00:00:00, without time zone
+)
+
+// maxDecimalPrecision is the highest precision of a DECIMAL column that is
+// written as a decimal; wider ones are written as strings.
+const maxDecimalPrecision = 38
+
+// columnType is the type of a column in the typed output formats.
+type columnType struct {
+	kind      typeKind
+	precision int // of kindDecimal
+	scale     int // of kindDecimal
+}
+
+// typedColumn is a column of a table written in a typed output format.
+type typedColumn struct {
+	name     string
+	typ      columnType
+	nullable bool
+}
+
+// typedColumns returns the columns of schema with their types. Columns
+// without a declared type, as in schemas inferred from INSERT statements,
+// are strings. Date and timestamp columns are always nullable, as zero dates
+// are written as nulls.
+func typedColumns(schema *models.Schema) []typedColumn {
+	columns := make([]typedColumn, len(schema.Columns))
+	for i, col := range schema.Columns {
+		typ := sqlColumnType(col.Type)
+		nullable := col.Nullable || typ.kind == kindDate || typ.kind == kindTimestamp
+		columns[i] = typedColumn{name: col.Name, typ: typ, nullable: nullable}
+	}
+	return columns
+}
+
+// value returns the value of the column in row, converted to its type. A
+// null in a column that isn't nullable is an error.
+func (c typedColumn) value(row models.Row) (interface{}, error) {
+	value, err := c.typ.convert(row.Data[c.name])
+	if err != nil {
+		return nil, fmt.Errorf("row %d, column %s: %v", row.RowNumber, c.name, err)
+	}
+	if value == nil && !c.nullable {
+		return nil, fmt.Errorf("row %d, column %s: NULL in a NOT NULL column", row.RowNumber, c.name)
+	}
+	return value, nil
+}
+
+// columnsFromRow returns string columns for the fields of a row, in sorted
+// order, for tables written without a schema.
+func columnsFromRow(row models.Row) []typedColumn {
+	names := make([]string, 0, len(row.Data))
+	for name := range row.Data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	columns := make([]typedColumn, len(names))
+	for i, name := range names {
+		columns[i] = typedColumn{name: name, nullable: true}
+	}
+	return columns
+}
+
+// sqlColumnType maps a column type from CREATE TABLE, such as "int(11)
+// unsigned" or "decimal(10,2)", to the type its values are written as.
+func sqlColumnType(sqlType string) columnType {
+	t := strings.ToLower(strings.TrimSpace(sqlType))
+	base, args := t, ""
+	if i := strings.IndexByte(t, '('); i >= 0 {
+		base = strings.TrimSpace(t[:i])
+		if j := strings.IndexByte(t[i:], ')'); j > 0 {
+			args = t[i+1 : i+j]
+		}
+	} else if i := strings.IndexByte(t, ' '); i >= 0 {
+		base = t[:i]
+	}
+	unsigned := strings.Contains(t, "unsigned")
+
+	switch base {
+	case "tinyint", "smallint", "mediumint", "int2", "smallserial", "year":
+		return columnType{kind: kindInt32}
+	case "int", "integer", "int4", "serial":
+		if unsigned {
+			return columnType{kind: kindInt64}
+		}
+		return columnType{kind: kindInt32}
+	case "bigint", "int8", "bigserial":
+		if unsigned {
+			return columnType{kind: kindDecimal, precision: 20}
+		}
+		return columnType{kind: kindInt64}
+	case "bool", "boolean":
+		return columnType{kind: kindBool}
+	case "float", "float4":
+		return columnType{kind: kindFloat32}
+	case "double", "float8", "real":
+		return columnType{kind: kindFloat64}
+	case "decimal", "numeric", "dec", "fixed":
+		precision, scale := 10, 0
+		if args == "" && base == "numeric" {
+			// PostgreSQL's NUMERIC without precision has no fixed scale
+			return columnType{kind: kindString}
+		}
+		if args != "" {
+			parts := strings.Split(args, ",")
+			precision, _ = strconv.Atoi(strings.TrimSpace(parts[0]))
+			if len(parts) > 1 {
+				scale, _ = strconv.Atoi(strings.TrimSpace(parts[1]))
+			}
+		}
+		if precision < 1 || precision > maxDecimalPrecision || scale < 0 || scale > precision {
+			return columnType{kind: kindString}
+		}
+		return columnType{kind: kindDecimal, precision: precision, scale: scale}
+	case "date":
+		return columnType{kind: kindDate}
+	case "datetime", "timestamp", "timestamptz":
+		return columnType{kind: kindTimestamp}
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob", "bytea":
+		return columnType{kind: kindBytes}
+	default:
+		return columnType{kind: kindString}
+	}
+}
+
+// timestampLayouts are the formats DATETIME and TIMESTAMP values are parsed
+// in, as written by MySQL, PostgreSQL and SQLite.
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02",
+}
+
+// convert turns a value of a row into the Go type that represents kind t:
+// string, []byte, bool, int32, int64, float32, float64, *big.Int or nil.
+// Zero dates, which MySQL allows, become nil.
+func (t columnType) convert(value interface{}) (interface{}, error) {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	switch t.kind {
+	case kindString:
+		return s, nil
+	case kindBytes:
+		return []byte(s), nil
+	case kindBool:
+		switch strings.ToLower(s) {
+		case "1", "t", "true", "y", "yes":
+			return true, nil
+		case "0", "f", "false", "n", "no":
+			return false, nil
+		}
+	case kindInt32:
+		if n, err := strconv.ParseInt(s, 10, 32); err == nil {
+			return int32(n), nil
+		}
+	case kindInt64:
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+	case kindFloat32:
+		if f, err := strconv.ParseFloat(s, 32); err == nil {
+			return float32(f), nil
+		}
+	case kindFloat64:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+	case kindDecimal:
+		if n, ok := parseDecimal(s, t.precision, t.scale); ok {
+			if t.precision <= 18 {
+				return n.Int64(), nil
+			}
+			return n, nil
+		}
+	case kindDate:
+		if strings.HasPrefix(s, "0000-00-00") {
+			return nil, nil
+		}
+		if d, err := time.Parse("2006-01-02", s); err == nil {
+			return int32(d.Unix() / 86400), nil
+		}
+	case kindTimestamp:
+		if strings.HasPrefix(s, "0000-00-00") {
+			return nil, nil
+		}
+		for _, layout := range timestampLayouts {
+			if ts, err := time.Parse(layout, s); err == nil {
+				return ts.UnixMicro(), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid %s value %q", t, s)
+}
+
+// parseDecimal parses a decimal number into its unscaled value at scale,
+// reporting false if it isn't a number or has more than precision digits.
+// Digits beyond scale are rounded half away from zero.
+func parseDecimal(s string, precision, scale int) (*big.Int, bool) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return nil, false
+	}
+	round := false
+	if len(frac) > scale {
+		round = frac[scale] >= '5'
+		frac = frac[:scale]
+	} else {
+		frac += strings.Repeat("0", scale-len(frac))
+	}
+	digits := whole + frac
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return nil, false
+		}
+	}
+	if len(strings.TrimLeft(digits, "0")) > precision {
+		return nil, false
+	}
+	n, ok := new(big.Int).SetString("0"+digits, 10)
+	if !ok {
+		return nil, false
+	}
+	if round {
+		n.Add(n, big.NewInt(1))
+	}
+	if neg {
+		n.Neg(n)
+	}
+	return n, true
+}
+
+func (t columnType) String() string {
+	switch t.kind {
+	case kindBytes:
+		return "binary"
+	case kindBool:
+		return "boolean"
+	case kindInt32:
+		return "32-bit integer"
+	case kindInt64:
+		return "64-bit integer"
+	case kindFloat32:
+		return "float"
+	case kindFloat64:
+		return "double"
+	case kindDecimal:
+		return fmt.Sprintf("decimal(%d,%d)", t.precision, t.scale)
+	case kindDate:
+		return "date"
+	case kindTimestamp:
+		return "timestamp"
+	default:
+		return "string"
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"sqlparser/pkg/models"
 )
@@ -20,10 +21,46 @@ type Writer interface {
 	Type() models.OutputFormat
 }
 
+// SchemaWriter is implemented by writers whose format declares the columns
+// of a table and their types up front. WriteSchema is called before the
+// table is started, with the schema from CREATE TABLE or one inferred from
+// the INSERT statements.
+type SchemaWriter interface {
+	WriteSchema(schema *models.Schema) error
+}
+
+// ContinuingWriter is implemented by the writers of appendable formats that
+// write something at the start of a table.
+type ContinuingWriter interface {
+	// ContinueTable makes the writer continue a table whose start and earlier
+	// rows are already in its output, so that WriteTableStart writes nothing
+	// for it.
+	ContinueTable(tableName string)
+}
+
+// resumedFile is the file of a table an interrupted export wrote size bytes
+// of.
+type resumedFile struct {
+	path string
+	size int64
+}
+
+// Compression is the codec used by the formats that compress their output,
+// such as parquet, arrow and avro. Empty selects the format's default.
+var Compression string
+
 type MultiWriter struct {
 	format  models.OutputFormat
 	writers map[string]Writer
+	files   map[string]*os.File
+	paths   map[string]string
+	schemas map[string]*models.Schema
+	resumed map[string]resumedFile
 	baseDir string
+
+	// SchemaFiles writes the schema of each avro file next to it, with the
+	// extension .avsc.
+	SchemaFiles bool
 }
 
 func CreateWriter(format models.OutputFormat, output io.Writer) (Writer, error) {
@@ -38,19 +75,52 @@ func CreateWriter(format models.OutputFormat, output io.Writer) (Writer, error)
 		return NewCSVWriter(bufferedWriter), nil
 	case models.FormatText:
 		return NewTextWriter(bufferedWriter), nil
+	case models.FormatParquet:
+		return NewParquetWriter(bufferedWriter, Compression)
+	case models.FormatArrow, models.FormatArrowStream:
+		return NewArrowWriter(bufferedWriter, format == models.FormatArrowStream, Compression)
+	case models.FormatAvro:
+		return NewAvroWriter(bufferedWriter, Compression)
 	default:
 		return nil, fmt.Errorf("unsupported format: %s", format)
 	}
 }
 
+// CheckCompression reports an error if compression is set but not supported
+// by format.
+func CheckCompression(format models.OutputFormat, compression string) error {
+	switch format {
+	case models.FormatParquet:
+		_, _, err := parquetCodec(compression)
+		return err
+	case models.FormatArrow, models.FormatArrowStream:
+		_, err := arrowCodec(compression)
+		return err
+	case models.FormatAvro:
+		_, _, err := avroCodec(compression)
+		return err
+	default:
+		if compression != "" {
+			return fmt.Errorf("%s output isn't compressed", format)
+		}
+		return nil
+	}
+}
+
 func CreateMultiWriter(format models.OutputFormat, inputPath string) (*MultiWriter, error) {
 	// Use the input file name (without extension) as the base directory
-	baseDir := filepath.Base(inputPath)
+	baseDir := strings.TrimSuffix(filepath.Base(inputPath), ".gz")
 	if filepath.Ext(baseDir) != "" {
 		baseDir = baseDir[:len(baseDir)-len(filepath.Ext(baseDir))]
 	}
 
-	// Create the directory
+	return CreateMultiWriterIn(format, baseDir)
+}
+
+// CreateMultiWriterIn is like CreateMultiWriter but writes the table files to
+// dir.
+func CreateMultiWriterIn(format models.OutputFormat, dir string) (*MultiWriter, error) {
+	baseDir := dir
 	if err := os.MkdirAll(baseDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create output directory: %v", err)
 	}
@@ -59,15 +129,53 @@ func CreateMultiWriter(format models.OutputFormat, inputPath string) (*MultiWrit
 		format:  format,
 		baseDir: baseDir,
 		writers: make(map[string]Writer),
+		files:   make(map[string]*os.File),
+		paths:   make(map[string]string),
+		schemas: make(map[string]*models.Schema),
+		resumed: make(map[string]resumedFile),
 	}, nil
 }
 
+// Continue makes the writer continue the file of tableName at path, which an
+// interrupted export wrote size bytes of, instead of creating a new one. The
+// format has to be appendable.
+func (mw *MultiWriter) Continue(tableName, path string, size int64) {
+	mw.resumed[tableName] = resumedFile{path: path, size: size}
+}
+
+// OpenAppend opens a file an interrupted export wrote size bytes of for
+// appending, dropping anything written after them.
+func OpenAppend(path string, size int64) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
 func (mw *MultiWriter) WriteTableStart(tableName string) error {
-	// Create a new file for this table
+	// Create a new file for this table, or continue the one of an
+	// interrupted export
 	filename := filepath.Join(mw.baseDir, tableName+"."+mw.format.Extension())
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("failed to create file for table %s: %v", tableName, err)
+	var file *os.File
+	var err error
+	resumed, continued := mw.resumed[tableName]
+	if continued {
+		delete(mw.resumed, tableName)
+		filename = resumed.path
+		file, err = OpenAppend(filename, resumed.size)
+		if err != nil {
+			return fmt.Errorf("failed to continue file for table %s: %v", tableName, err)
+		}
+	} else {
+		file, err = os.Create(filename)
+		if err != nil {
+			return fmt.Errorf("failed to create file for table %s: %v", tableName, err)
+		}
 	}
 
 	// Create a writer for this table
@@ -77,10 +185,31 @@ func (mw *MultiWriter) WriteTableStart(tableName string) error {
 		return err
 	}
 
+	if aw, ok := writer.(*AvroWriter); ok && mw.SchemaFiles {
+		aw.SchemaPath = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".avsc"
+	}
 	mw.writers[tableName] = writer
+	mw.files[tableName] = file
+	mw.paths[tableName] = filename
+	if sw, ok := writer.(SchemaWriter); ok {
+		if schema := mw.schemas[tableName]; schema != nil {
+			if err := sw.WriteSchema(schema); err != nil {
+				return err
+			}
+		}
+	}
+	if cw, ok := writer.(ContinuingWriter); ok && continued {
+		cw.ContinueTable(tableName)
+	}
 	return writer.WriteTableStart(tableName)
 }
 
+// WriteSchema keeps the schema of a table for the writer of its file.
+func (mw *MultiWriter) WriteSchema(schema *models.Schema) error {
+	mw.schemas[schema.TableName] = schema
+	return nil
+}
+
 func (mw *MultiWriter) WriteRows(rows []models.Row) error {
 	if len(rows) == 0 {
 		return nil
@@ -112,10 +241,21 @@ func (mw *MultiWriter) Close() error {
 		if err := writer.Close(); err != nil {
 			lastErr = fmt.Errorf("failed to close writer for table %s: %v", tableName, err)
 		}
+		if err := mw.files[tableName].Close(); err != nil {
+			lastErr = fmt.Errorf("failed to close file for table %s: %v", tableName, err)
+		}
 	}
+	mw.writers = make(map[string]Writer)
+	mw.files = make(map[string]*os.File)
 	return lastErr
 }
 
+// Path returns the file the rows of tableName were written to, or "" if the
+// table wasn't started. It stays available after Close.
+func (mw *MultiWriter) Path(tableName string) string {
+	return mw.paths[tableName]
+}
+
 func (mw *MultiWriter) Type() models.OutputFormat {
 	return mw.format
 }
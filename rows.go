@@ -0,0 +1,139 @@
+package sqlparser
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Rows is an iterator over the rows of a dump, in input order:
+//
+//	rows := p.Rows(ctx, r, "users")
+//	defer rows.Close()
+//	for rows.Next() {
+//		row := rows.Row()
+//		// ...
+//	}
+//	if err := rows.Err(); err != nil {
+//		// ...
+//	}
+//
+// The consumer sets the pace: parsing pauses while a batch waits to be read.
+// Memory use is bounded by that batch, the one being filled, and up to four
+// statements per worker that were read and parsed ahead of delivery.
+type Rows struct {
+	cancel  context.CancelFunc
+	batches chan rowBatch
+	done    chan struct{}
+	err     error // set before done is closed
+
+	batch   []Row
+	table   string
+	pos     int
+	row     Row
+	closed  bool
+	stopped bool // closed by the consumer before the end of the input
+}
+
+type rowBatch struct {
+	table string
+	rows  []Row
+}
+
+// Rows starts parsing the dump read from r and returns an iterator over its
+// rows. If tables are given, only rows of those tables are returned;
+// otherwise the tables selected with WithTables are used. The iterator must
+// be closed, or read until Next returns false, to release its goroutines.
+func (p *Parser) Rows(ctx context.Context, r io.Reader, tables ...string) *Rows {
+	if len(tables) > 0 {
+		filtered := *p
+		WithTables(tables...)(&filtered)
+		p = &filtered
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	rs := &Rows{
+		cancel:  cancel,
+		batches: make(chan rowBatch, 1),
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(rs.done)
+		defer close(rs.batches)
+		rs.err = p.Parse(ctx, r, HandlerFuncs{
+			OnRows: func(table string, rows []Row) error {
+				select {
+				case rs.batches <- rowBatch{table: table, rows: rows}:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			},
+		})
+	}()
+	return rs
+}
+
+// Next advances to the next row. It returns false when the input is
+// exhausted or parsing failed; Err tells the two apart.
+func (rs *Rows) Next() bool {
+	if rs.closed {
+		return false
+	}
+	for rs.pos >= len(rs.batch) {
+		b, ok := <-rs.batches
+		if !ok {
+			rs.release()
+			return false
+		}
+		rs.batch, rs.table, rs.pos = b.rows, b.table, 0
+	}
+	rs.row = rs.batch[rs.pos]
+	rs.pos++
+	return true
+}
+
+// Row returns the current row.
+func (rs *Rows) Row() Row {
+	return rs.row
+}
+
+// Table returns the table of the current row.
+func (rs *Rows) Table() string {
+	return rs.table
+}
+
+// Err returns the error that ended the iteration, if any. Stopping early with
+// Close is not an error.
+func (rs *Rows) Err() error {
+	select {
+	case <-rs.done:
+	default:
+		return nil
+	}
+	if rs.stopped && errors.Is(rs.err, context.Canceled) {
+		return nil
+	}
+	return rs.err
+}
+
+// Close stops parsing and releases the iterator. It is safe to call more than
+// once.
+func (rs *Rows) Close() error {
+	if !rs.closed {
+		rs.stopped = true
+		rs.release()
+	}
+	return nil
+}
+
+func (rs *Rows) release() {
+	rs.closed = true
+	rs.cancel()
+	for range rs.batches {
+		// Unblock the parser so it can shut down
+	}
+	<-rs.done
+	rs.batch = nil
+}
@@ -0,0 +1,107 @@
+package sqlparser
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRows(t *testing.T) {
+	dump, all := interleavedDump(50)
+	var onlyB []string
+	for _, row := range all {
+		if row[0] == 'b' {
+			onlyB = append(onlyB, row)
+		}
+	}
+	tests := []struct {
+		name   string
+		opts   []Option
+		tables []string
+		want   []string
+	}{
+		{name: "every table", opts: []Option{WithWorkers(4), WithBatchSize(2)}, want: all},
+		{name: "tables argument", opts: []Option{WithWorkers(4)}, tables: []string{"b"}, want: onlyB},
+		{name: "tables of the parser", opts: []Option{WithTables("b")}, want: onlyB},
+		{name: "tables argument over those of the parser", opts: []Option{WithTables("a")}, tables: []string{"b"}, want: onlyB},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := New(tt.opts...).Rows(context.Background(), strings.NewReader(dump), tt.tables...)
+			defer rows.Close()
+			var got []string
+			for rows.Next() {
+				got = append(got, rows.Table()+rows.Row().Data["id"].(string))
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %d rows %v..., want %d", len(got), got[:min(len(got), 6)], len(tt.want))
+			}
+			if rows.Next() {
+				t.Error("Next returned true after the end")
+			}
+		})
+	}
+}
+
+func TestRowsClose(t *testing.T) {
+	dump, _ := interleavedDump(1000)
+	rows := New(WithWorkers(4), WithBatchSize(1)).Rows(context.Background(), strings.NewReader(dump))
+	for i := 0; i < 5; i++ {
+		if !rows.Next() {
+			t.Fatalf("Next returned false at row %d: %v", i, rows.Err())
+		}
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Stopping early isn't an error, and closing again does nothing
+	if err := rows.Err(); err != nil {
+		t.Errorf("Err after Close = %v", err)
+	}
+	if rows.Next() {
+		t.Error("Next returned true after Close")
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRowsErr(t *testing.T) {
+	t.Run("parse error", func(t *testing.T) {
+		dump := "INSERT INTO `t` (`id`) VALUES (1),(2);\nINSERT INTO `t` (`id`) VALUES (3;\n"
+		rows := New(WithErrorPolicy(ErrorPolicyFail, nil)).Rows(context.Background(), strings.NewReader(dump))
+		defer rows.Close()
+		n := 0
+		for rows.Next() {
+			n++
+		}
+		var perr *ParseError
+		if !errors.As(rows.Err(), &perr) || perr.Line != 2 {
+			t.Fatalf("got %v, want a parse error on line 2", rows.Err())
+		}
+		if n != 2 {
+			t.Errorf("got %d rows before the error, want 2", n)
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		dump, _ := interleavedDump(1000)
+		ctx, cancel := context.WithCancel(context.Background())
+		rows := New(WithBatchSize(1)).Rows(ctx, strings.NewReader(dump))
+		defer rows.Close()
+		if !rows.Next() {
+			t.Fatal(rows.Err())
+		}
+		cancel()
+		for rows.Next() {
+		}
+		if !errors.Is(rows.Err(), context.Canceled) {
+			t.Errorf("got %v, want context.Canceled", rows.Err())
+		}
+	})
+}
@@ -0,0 +1,135 @@
+// Package sqlparser parses SQL dumps into tables, schemas and rows for use as a
+// library. It streams its input, so dumps of any size can be processed with
+// bounded memory:
+//
+//	p := sqlparser.New(sqlparser.WithWorkers(4), sqlparser.WithTables("users"))
+//	err := p.Parse(ctx, r, sqlparser.HandlerFuncs{
+//		OnRows: func(table string, rows []sqlparser.Row) error {
+//			// ...
+//			return nil
+//		},
+//	})
+package sqlparser
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"sqlparser/pkg/models"
+	"sqlparser/pkg/parser"
+)
+
+type (
+	Row          = models.Row
+	Schema       = models.Schema
+	Column       = models.Column
+	Handler      = parser.Handler
+	HandlerFuncs = parser.HandlerFuncs
+	ParseError   = parser.ParseError
+	ErrorPolicy  = parser.ErrorPolicy
+)
+
+const (
+	ErrorPolicyFail       = parser.ErrorPolicyFail
+	ErrorPolicySkip       = parser.ErrorPolicySkip
+	ErrorPolicyQuarantine = parser.ErrorPolicyQuarantine
+)
+
+// Parser parses SQL dumps. It holds only configuration, so a single Parser
+// can be used for any number of concurrent Parse calls.
+type Parser struct {
+	workers   int
+	batchSize int
+	tables    map[string]bool
+	policy    ErrorPolicy
+	rejects   io.Writer
+}
+
+type Option func(*Parser)
+
+// New creates a Parser. By default it uses one worker, the batch size from
+// the BATCH_SIZE environment variable, parses every table and skips
+// statements that fail to parse.
+func New(opts ...Option) *Parser {
+	p := &Parser{workers: 1, policy: ErrorPolicySkip}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// WithWorkers sets the number of statements parsed concurrently.
+func WithWorkers(n int) Option {
+	return func(p *Parser) {
+		p.workers = n
+	}
+}
+
+// WithBatchSize sets the maximum number of rows passed to each Rows call.
+func WithBatchSize(n int) Option {
+	return func(p *Parser) {
+		p.batchSize = n
+	}
+}
+
+// WithTables limits parsing to the named tables.
+func WithTables(names ...string) Option {
+	return func(p *Parser) {
+		p.tables = make(map[string]bool, len(names))
+		for _, name := range names {
+			p.tables[name] = true
+		}
+	}
+}
+
+// WithErrorPolicy sets what happens to statements and rows that fail to
+// parse. rejects receives them under ErrorPolicyQuarantine.
+func WithErrorPolicy(policy ErrorPolicy, rejects io.Writer) Option {
+	return func(p *Parser) {
+		p.policy = policy
+		p.rejects = rejects
+	}
+}
+
+// Parse reads a dump from r and passes the schemas and rows it contains to h,
+// in input order. It returns when the input is exhausted, h or the error
+// policy fails, or ctx is cancelled, in which case ctx.Err() is returned.
+func (p *Parser) Parse(ctx context.Context, r io.Reader, h Handler) error {
+	return p.parse(ctx, "", r, h)
+}
+
+// ParseFile is like Parse but reads the dump from the named file, which may be
+// gzip-compressed. The file name is used in the positions of ParseErrors.
+func (p *Parser) ParseFile(ctx context.Context, filename string, h Handler) error {
+	file, err := parser.OpenInput(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return p.parse(ctx, filename, file, h)
+}
+
+func (p *Parser) parse(ctx context.Context, filename string, r io.Reader, h Handler) error {
+	if p.policy == ErrorPolicyQuarantine && p.rejects == nil {
+		return fmt.Errorf("quarantine policy requires a rejects writer")
+	}
+
+	errs := parser.NewErrorHandler(p.policy, p.rejects)
+	cfg := parser.Config{
+		File:      filename,
+		Workers:   p.workers,
+		BatchSize: p.batchSize,
+		Errors:    errs,
+	}
+	if p.tables != nil {
+		cfg.Tables = func(_, name string) bool { return p.tables[name] }
+	}
+
+	_, err := parser.Stream(ctx, r, cfg, h)
+	if ferr := errs.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
@@ -0,0 +1,194 @@
+package sqlparser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// interleavedDump has the INSERT statements of a and b alternate, with three
+// rows each, so that every statement starts a table again.
+func interleavedDump(statements int) (dump string, want []string) {
+	var b strings.Builder
+	b.WriteString("CREATE TABLE `a` (\n  `id` int\n);\nCREATE TABLE `b` (\n  `id` int\n);\n")
+	for i := 0; i < statements; i++ {
+		table := "ab"[i%2 : i%2+1]
+		fmt.Fprintf(&b, "INSERT INTO `%s` VALUES (%d),(%d),(%d);\n", table, 3*i, 3*i+1, 3*i+2)
+		for j := 0; j < 3; j++ {
+			want = append(want, fmt.Sprintf("%s%d", table, 3*i+j))
+		}
+	}
+	return b.String(), want
+}
+
+// recorder is a Handler that records the calls it gets.
+type recorder struct {
+	events []string
+	rows   []string
+}
+
+func (r *recorder) handler() HandlerFuncs {
+	return HandlerFuncs{
+		OnSchema: func(schema *Schema) error {
+			r.events = append(r.events, "schema "+schema.TableName)
+			return nil
+		},
+		OnTableStart: func(table string) error {
+			r.events = append(r.events, "start "+table)
+			return nil
+		},
+		OnRows: func(table string, rows []Row) error {
+			for _, row := range rows {
+				r.rows = append(r.rows, table+row.Data["id"].(string))
+			}
+			return nil
+		},
+		OnTableEnd: func(table string) error {
+			r.events = append(r.events, "end "+table)
+			return nil
+		},
+	}
+}
+
+func TestParseOrder(t *testing.T) {
+	dump, want := interleavedDump(200)
+	for _, workers := range []int{1, 2, 8} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			var r recorder
+			p := New(WithWorkers(workers), WithBatchSize(2))
+			if err := p.Parse(context.Background(), strings.NewReader(dump), r.handler()); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(r.rows, want) {
+				t.Errorf("rows out of order: got %v...", r.rows[:10])
+			}
+			if len(r.events) != 2+2*200 {
+				t.Fatalf("got %d events, want %d", len(r.events), 2+2*200)
+			}
+			if got := strings.Join(r.events[:6], ", "); got != "schema a, schema b, start a, end a, start b, end b" {
+				t.Errorf("events start with %s", got)
+			}
+		})
+	}
+}
+
+func TestParseTables(t *testing.T) {
+	dump, _ := interleavedDump(4)
+	var r recorder
+	if err := New(WithTables("b")).Parse(context.Background(), strings.NewReader(dump), r.handler()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b3", "b4", "b5", "b9", "b10", "b11"}; !reflect.DeepEqual(r.rows, want) {
+		t.Errorf("got rows %v, want %v", r.rows, want)
+	}
+	// With a filtered out, the statements of b follow each other
+	if want := []string{"schema b", "start b", "end b"}; !reflect.DeepEqual(r.events, want) {
+		t.Errorf("got events %v, want %v", r.events, want)
+	}
+}
+
+func TestParseCancel(t *testing.T) {
+	dump, _ := interleavedDump(1000)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var r recorder
+	h := r.handler()
+	h.OnRows = func(table string, rows []Row) error {
+		r.rows = append(r.rows, table)
+		cancel()
+		return nil
+	}
+	err := New(WithWorkers(4)).Parse(ctx, strings.NewReader(dump), h)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if len(r.rows) == 0 || len(r.rows) > 100 {
+		t.Errorf("got %d Rows calls after cancelling", len(r.rows))
+	}
+	// The open table is still ended
+	if last := r.events[len(r.events)-1]; !strings.HasPrefix(last, "end ") {
+		t.Errorf("last event is %q, want the end of a table", last)
+	}
+}
+
+func TestParseHandlerError(t *testing.T) {
+	dump, _ := interleavedDump(10)
+	stop := errors.New("stop")
+	var r recorder
+	h := r.handler()
+	h.OnRows = func(string, []Row) error { return stop }
+	err := New(WithWorkers(4)).Parse(context.Background(), strings.NewReader(dump), h)
+	if err != stop {
+		t.Fatalf("got %v, want the handler's error", err)
+	}
+	// No calls after the handler failed
+	if want := []string{"schema a", "schema b", "start a"}; !reflect.DeepEqual(r.events, want) {
+		t.Errorf("got events %v, want %v", r.events, want)
+	}
+}
+
+func TestParseErrorPolicies(t *testing.T) {
+	const dump = "INSERT INTO `t` (`id`) VALUES (1);\nINSERT INTO `t` (`id`) VALUES (2;\nINSERT INTO `t` (`id`) VALUES (3);\n"
+	tests := []struct {
+		policy  ErrorPolicy
+		rows    []string
+		err     bool
+		rejects string
+	}{
+		{policy: ErrorPolicyFail, rows: []string{"t1"}, err: true},
+		{policy: ErrorPolicySkip, rows: []string{"t1", "t3"}},
+		{policy: ErrorPolicyQuarantine, rows: []string{"t1", "t3"}, rejects: "INSERT INTO `t` (`id`) VALUES (2;\n"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			var rejects bytes.Buffer
+			var r recorder
+			err := New(WithErrorPolicy(tt.policy, &rejects)).Parse(context.Background(), strings.NewReader(dump), r.handler())
+			var perr *ParseError
+			if tt.err != errors.As(err, &perr) {
+				t.Errorf("got error %v", err)
+			}
+			if !reflect.DeepEqual(r.rows, tt.rows) {
+				t.Errorf("got rows %v, want %v", r.rows, tt.rows)
+			}
+			// The rejects file has a comment line with the error before each statement
+			if _, statement, _ := strings.Cut(rejects.String(), "\n"); statement != tt.rejects {
+				t.Errorf("rejects file holds %q, want %q after the comment", statement, tt.rejects)
+			}
+		})
+	}
+
+	if err := New(WithErrorPolicy(ErrorPolicyQuarantine, nil)).Parse(context.Background(), strings.NewReader(dump), HandlerFuncs{}); err == nil {
+		t.Error("quarantine without a rejects writer succeeded")
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	dump, want := interleavedDump(3)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(dump + "INSERT INTO `a` VALUES (1;\n"))
+	zw.Close()
+	filename := filepath.Join(t.TempDir(), "dump.sql.gz")
+	if err := os.WriteFile(filename, gz.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var r recorder
+	err := New(WithErrorPolicy(ErrorPolicyFail, nil)).ParseFile(context.Background(), filename, r.handler())
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.File != filename || perr.Line != 10 {
+		t.Fatalf("got %v, want a parse error on line 10 of %s", err, filename)
+	}
+	if !reflect.DeepEqual(r.rows, want) {
+		t.Errorf("got rows %v, want %v", r.rows, want)
+	}
+}